@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"mate/audit"
+	"mate/auth"
+	"mate/config"
+	"mate/idempotency"
+	"mate/importer"
+	"mate/ingest"
+	"mate/lifecycle"
+	"mate/models"
+	"mate/privacy"
+	"mate/ratelimit"
+	"mate/reprocess"
+	"mate/statement"
+	"mate/users"
+	"mate/utils"
+	"mate/vault"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// adminCommands run against the database with the same packages as the server. Each
+// gets the arguments after its name and returns an error to print; errUsage prints
+// the usage instead.
+var adminCommands = map[string]func(ctx context.Context, args []string) error{
+	"users create":   createUser,
+	"users disable":  disableUser,
+	"users enable":   enableUser,
+	"apikeys issue":  issueAPIKey,
+	"apikeys revoke": revokeAPIKey,
+	"migrate":        migrate,
+	"reparse":        reparse,
+	"imports retry":  retryImport,
+	"reconcile":      reconcile,
+	"export":         exportUser,
+}
+
+var errUsage = errors.New("usage")
+
+// runAdmin connects to MongoDB and runs an admin command
+func runAdmin(command func(ctx context.Context, args []string) error, args []string) int {
+	if err := config.InitConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	time.Local = config.Current.Server.Location
+	config.ConnectToDB()
+	defer config.Disconnect(context.Background())
+	if err := vault.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load encryption keys:", err)
+		return 1
+	}
+	// Imports retried here count against the same daily parser quotas as the server's
+	ratelimit.Init()
+
+	err := command(context.Background(), args)
+	if errors.Is(err, errUsage) {
+		if err != errUsage {
+			fmt.Fprintf(os.Stderr, "%v\n\n", err)
+		}
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// createUser never takes the password as an argument, where it would end up in the
+// shell history and the process list
+func createUser(ctx context.Context, args []string) error {
+	fromStdin := false
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case arg == "--password-stdin":
+			fromStdin = true
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("%w: unknown flag %s", errUsage, arg)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) != 1 {
+		return errUsage
+	}
+	email := positional[0]
+	if err := utils.ValidateEmail(email); err != nil {
+		return err
+	}
+
+	var password string
+	var err error
+	if fromStdin {
+		password, err = readPassword(os.Stdin)
+	} else {
+		password, err = newPassword(email)
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := users.Create(ctx, email, password)
+	if err != nil {
+		return err
+	}
+	record(*user, audit.ActionUserRegistered)
+
+	fmt.Printf("user_id: %s\napi_key: %s\n", user.UserID, user.ApiKey)
+	if !fromStdin {
+		fmt.Printf("password: %s\n", password)
+	}
+	return nil
+}
+
+func disableUser(ctx context.Context, args []string) error {
+	return withUser(ctx, args, func(user models.User) error {
+		if err := users.Disable(ctx, user); err != nil {
+			return err
+		}
+		record(user, audit.ActionUserDisabled)
+		fmt.Printf("disabled %s\n", user.Email)
+		return nil
+	})
+}
+
+func enableUser(ctx context.Context, args []string) error {
+	return withUser(ctx, args, func(user models.User) error {
+		if err := users.Enable(ctx, user); err != nil {
+			return err
+		}
+		record(user, audit.ActionUserEnabled)
+		fmt.Printf("enabled %s\n", user.Email)
+		return nil
+	})
+}
+
+func issueAPIKey(ctx context.Context, args []string) error {
+	return withUser(ctx, args, func(user models.User) error {
+		apiKey, err := users.RotateAPIKey(ctx, user)
+		if err != nil {
+			return err
+		}
+		record(user, audit.ActionAPIKeyRotated)
+		fmt.Printf("api_key: %s\n", apiKey)
+		return nil
+	})
+}
+
+func revokeAPIKey(ctx context.Context, args []string) error {
+	return withUser(ctx, args, func(user models.User) error {
+		if err := users.RevokeAPIKey(ctx, user); err != nil {
+			return err
+		}
+		record(user, audit.ActionAPIKeyRevoked)
+		fmt.Printf("revoked the API key of %s\n", user.Email)
+		return nil
+	})
+}
+
+func migrate(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	// The stores chosen by these create their own indexes
+	auth.InitLoginGuard()
+	idempotency.Init()
+
+	if err := ensureIndexes(); err != nil {
+		return err
+	}
+	if err := migrateData(); err != nil {
+		return err
+	}
+	fmt.Println("migrations complete")
+	return nil
+}
+
+// reparse re-runs the parsers over the selected SMS transactions and prints the
+// fields that change
+func reparse(ctx context.Context, args []string) error {
+	var opts reprocess.Options
+	var rest []string
+	for _, arg := range args {
+		if arg == "--dry-run" {
+			opts.DryRun = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	flags, positional, err := splitArgs(rest, "--user", "--origin", "--from", "--to", "--parser-version")
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errUsage
+	}
+
+	if email := flags["--user"]; email != "" {
+		user, err := users.FindByEmail(ctx, email)
+		if err != nil {
+			return fmt.Errorf("%s: %w", email, err)
+		}
+		opts.UserID = user.ID.String()
+	}
+	if opts.Origin = flags["--origin"]; opts.Origin != "" && !ingest.IsKnownSender(opts.Origin) {
+		return fmt.Errorf("unknown origin %q", opts.Origin)
+	}
+	// Dates are transaction dates, both ends included
+	if opts.From = flags["--from"]; opts.From != "" {
+		if _, err := time.Parse("2006-01-02", opts.From); err != nil {
+			return fmt.Errorf("invalid --from date %q, want YYYY-MM-DD", opts.From)
+		}
+	}
+	if opts.To = flags["--to"]; opts.To != "" {
+		if _, err := time.Parse("2006-01-02", opts.To); err != nil {
+			return fmt.Errorf("invalid --to date %q, want YYYY-MM-DD", opts.To)
+		}
+	}
+	opts.ParserVersion = flags["--parser-version"]
+
+	report, err := reprocess.Run(ctx, opts, printReparse)
+	if err != nil {
+		return err
+	}
+	// Let webhooks for the updated transactions go out
+	if err := lifecycle.Wait(ctx); err != nil {
+		return err
+	}
+
+	verb := "updated"
+	if opts.DryRun {
+		verb = "would update"
+	}
+	fmt.Printf("matched %d, %s %d, unchanged %d, failed %d\n", report.Matched, verb, report.Updated, report.Unchanged, report.Failed)
+	return nil
+}
+
+func printReparse(result reprocess.Result) {
+	if result.Err == nil && len(result.Changes) == 0 {
+		return
+	}
+	tx := result.Transaction
+	fmt.Printf("%s %s %s\n", tx.ID.Hex(), tx.Origin, tx.Timestamp.Format("2006-01-02 15:04"))
+	if result.Err != nil {
+		fmt.Printf("  error: %v\n", result.Err)
+		return
+	}
+	for _, change := range result.Changes {
+		note := ""
+		if change.Kept {
+			note = " (kept, edited by hand)"
+		}
+		fmt.Printf("  %s: %v -> %v%s\n", change.Field, change.From, change.To, note)
+	}
+}
+
+// retryImport replays the failed items of an import job and waits for it to finish
+func retryImport(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	jobID, err := primitive.ObjectIDFromHex(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid job ID %q", args[0])
+	}
+	if _, err := importer.LoadJob(jobID); err != nil {
+		return fmt.Errorf("import job %s: %w", args[0], err)
+	}
+
+	count, err := importer.RetryFailed(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	importer.Start(jobID)
+	if err := lifecycle.Wait(ctx); err != nil {
+		return err
+	}
+
+	job, err := importer.LoadJob(jobID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("retried %d items; job %s: imported %d, duplicates %d, failed %d\n", count, job.Status, job.Imported, job.Duplicates, job.Failed)
+	return nil
+}
+
+// reconcile imports a PDF bank statement for a user, like POST /v1/statements
+func reconcile(ctx context.Context, args []string) error {
+	flags, positional, err := splitArgs(args, "--layout")
+	if err != nil {
+		return err
+	}
+	if len(positional) != 3 {
+		return errUsage
+	}
+	email, origin, path := positional[0], positional[1], positional[2]
+	if !ingest.IsKnownSender(origin) {
+		return fmt.Errorf("unknown origin %q", origin)
+	}
+
+	pdf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines, err := statement.ExtractText(pdf)
+	if err != nil {
+		return fmt.Errorf("reading statement: %w", err)
+	}
+	layout := statement.DetectLayout(flags["--layout"], lines)
+	if layout == nil {
+		return errors.New("unsupported statement layout")
+	}
+	rows := layout.Parse(lines)
+	if len(rows) == 0 {
+		return errors.New("no transactions found in statement")
+	}
+
+	return withUser(ctx, []string{email}, func(user models.User) error {
+		report := statement.Import(ctx, user, origin, layout.Name(), rows)
+		fmt.Printf("%s: %d rows, inserted %d, reconciled %d, duplicates %d, failed %d\n",
+			report.Layout, report.Rows, report.Inserted, report.Reconciled, report.Duplicates, report.Failed)
+		for _, failure := range report.Failures {
+			fmt.Printf("  %s %.2f: %s\n", failure.Row.Date.Format("2006-01-02"), failure.Row.Amount, failure.Reason)
+		}
+		return nil
+	})
+}
+
+// exportUser builds a data export like POST /v1/account/exports and copies the
+// archive to --out when given
+func exportUser(ctx context.Context, args []string) error {
+	flags, positional, err := splitArgs(args, "--out")
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errUsage
+	}
+
+	return withUser(ctx, positional, func(user models.User) error {
+		job, err := privacy.CreateExport(user)
+		if err != nil {
+			return err
+		}
+		record(user, audit.ActionDataExportRequested)
+		privacy.StartExport(job.ID)
+		if err := lifecycle.Wait(ctx); err != nil {
+			return err
+		}
+
+		job, err = privacy.OpenExport(user, job.ID)
+		if err != nil {
+			return fmt.Errorf("export failed: %w", err)
+		}
+		if flags["--out"] == "" {
+			fmt.Println(job.Path)
+			return nil
+		}
+		if err := copyFile(job.Path, flags["--out"]); err != nil {
+			return err
+		}
+		fmt.Println(flags["--out"])
+		return nil
+	})
+}
+
+// withUser runs fn with the user whose email is the only argument
+func withUser(ctx context.Context, args []string, fn func(user models.User) error) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	user, err := users.FindByEmail(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return fn(*user)
+}
+
+// record audits an operator action on user's account
+func record(user models.User, action string) {
+	audit.Record(nil, audit.Entry{
+		Owner:      user.ID.String(),
+		Action:     action,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+}
+
+// splitArgs separates "--name value" flags, for the names given, from positional
+// arguments
+func splitArgs(args []string, names ...string) (map[string]string, []string, error) {
+	flags := map[string]string{}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[:2] != "--" {
+			positional = append(positional, arg)
+			continue
+		}
+		known := false
+		for _, name := range names {
+			known = known || arg == name
+		}
+		if !known || i+1 == len(args) {
+			return nil, nil, fmt.Errorf("%w: unknown flag %s", errUsage, arg)
+		}
+		flags[arg] = args[i+1]
+		i++
+	}
+	return flags, positional, nil
+}
+
+// readPassword reads a password from the first line of r
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on standard input")
+	}
+	return password, nil
+}
+
+// newPassword generates a password that meets the strength rules
+func newPassword(email string) (string, error) {
+	for {
+		bytes := make([]byte, 15)
+		if _, err := rand.Read(bytes); err != nil {
+			return "", err
+		}
+		password := base64.RawURLEncoding.EncodeToString(bytes)
+		if utils.ValidatePassword(password, email) == nil {
+			return password, nil
+		}
+	}
+}
+
+func copyFile(from, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
@@ -0,0 +1,140 @@
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Code is a stable, machine readable error identifier. Messages may change,
+// codes may not: clients branch on them.
+type Code string
+
+// General codes
+const (
+	CodeInvalidInput      Code = "INVALID_INPUT"
+	CodeInvalidID         Code = "INVALID_ID"
+	CodeInvalidFile       Code = "INVALID_FILE"
+	CodeUnsupportedFormat Code = "UNSUPPORTED_FORMAT"
+	CodeNotFound          Code = "NOT_FOUND"
+	CodeMethodNotAllowed  Code = "METHOD_NOT_ALLOWED"
+	CodePayloadTooLarge   Code = "PAYLOAD_TOO_LARGE"
+	CodeRateLimited       Code = "RATE_LIMITED"
+	CodeQuotaExceeded     Code = "QUOTA_EXCEEDED"
+	CodeConflict          Code = "CONFLICT"
+	CodeIdempotencyReused Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyBusy   Code = "IDEMPOTENCY_KEY_IN_USE"
+	CodeInternal          Code = "INTERNAL_ERROR"
+	CodeUnavailable       Code = "SERVICE_UNAVAILABLE"
+)
+
+// Authentication and authorization codes
+const (
+	CodeAuthRequired         Code = "AUTH_REQUIRED"
+	CodeInvalidAPIKey        Code = "INVALID_API_KEY"
+	CodeInvalidCredentials   Code = "INVALID_CREDENTIALS"
+	CodeSessionExpired       Code = "SESSION_EXPIRED"
+	CodeNoSession            Code = "NO_SESSION"
+	CodeInvalidToken         Code = "INVALID_TOKEN"
+	CodeWeakPassword         Code = "WEAK_PASSWORD"
+	CodeInvalidTwoFactor     Code = "INVALID_TWO_FACTOR_CODE"
+	CodeTwoFactorEnabled     Code = "TWO_FACTOR_ALREADY_ENABLED"
+	CodeTwoFactorNotEnrolled Code = "TWO_FACTOR_NOT_ENROLLED"
+	CodeInsufficientRole     Code = "INSUFFICIENT_ROLE"
+	CodeAccountDisabled      Code = "ACCOUNT_DISABLED"
+	CodeEmailTaken           Code = "EMAIL_TAKEN"
+	CodeEmailVerified        Code = "EMAIL_ALREADY_VERIFIED"
+	CodeEmailNotVerified     Code = "EMAIL_NOT_VERIFIED"
+)
+
+// Resource codes
+const (
+	CodeUserNotFound        Code = "USER_NOT_FOUND"
+	CodeTransactionNotFound Code = "TRANSACTION_NOT_FOUND"
+	CodeWebhookNotFound     Code = "WEBHOOK_NOT_FOUND"
+	CodeDeliveryNotFound    Code = "DELIVERY_NOT_FOUND"
+	CodeImportNotFound      Code = "IMPORT_NOT_FOUND"
+	CodeExportNotFound      Code = "EXPORT_NOT_FOUND"
+	CodeSessionNotFound     Code = "SESSION_NOT_FOUND"
+	CodeWorkspaceNotFound   Code = "WORKSPACE_NOT_FOUND"
+	CodeMemberNotFound      Code = "MEMBER_NOT_FOUND"
+	CodeInvalidInvite       Code = "INVALID_INVITE"
+	CodeAlreadyMember       Code = "ALREADY_MEMBER"
+	CodeOwnerCannotLeave    Code = "OWNER_CANNOT_LEAVE"
+	CodeImportCompleted     Code = "IMPORT_ALREADY_COMPLETED"
+	CodeExportNotReady      Code = "EXPORT_NOT_READY"
+	CodeDeletionPending     Code = "DELETION_PENDING"
+	CodeNoDeletionPending   Code = "NO_DELETION_PENDING"
+)
+
+// Ingestion codes
+const (
+	CodeInvalidSender        Code = "INVALID_SENDER"
+	CodeParseFailed          Code = "PARSE_FAILED"
+	CodeDuplicateTransaction Code = "DUPLICATE_TRANSACTION"
+	CodeUpstreamLLM          Code = "UPSTREAM_LLM_ERROR"
+	CodeEmptyStatement       Code = "EMPTY_STATEMENT"
+)
+
+// Error is an error with the status, code and message sent to the client. Err is
+// the underlying cause; it is logged but never sent. A non-zero RetryAfter is sent
+// as the Retry-After header.
+type Error struct {
+	Status     int
+	Code       Code
+	Message    string
+	Err        error
+	RetryAfter time.Duration
+}
+
+// New returns an error answered with status, code and message
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Internal is a server side failure; cause is logged and message is sent
+func Internal(cause error, message string) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: cause}
+}
+
+// BadRequest is a 400 with the generic INVALID_INPUT code
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidInput, message)
+}
+
+// InvalidID is a 400 for a malformed id in the path or body
+func InvalidID(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidID, message)
+}
+
+// Wrap returns a copy of e with cause attached, for sentinel errors
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.Err = cause
+	return &wrapped
+}
+
+// After returns a copy of e that tells the client to retry after wait
+func (e *Error) After(wait time.Duration) *Error {
+	delayed := *e
+	delayed.RetryAfter = wait
+	return &delayed
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap exposes the cause to errors.Is and errors.As
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches copies made by Wrap against the sentinel they came from
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Status == e.Status && t.Message == e.Message
+}
@@ -0,0 +1,100 @@
+package apierror
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mate/logging"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// problemJSON is the RFC 7807 media type, sent when the client asks for it in Accept
+const problemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body, extended with the error code and request ID
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Handler is the app's fiber ErrorHandler, handlers return errors and this writes them.
+// Errors other than *Error are answered as INTERNAL_ERROR without leaking their text.
+func Handler(c *fiber.Ctx, err error) error {
+	apiErr := From(err)
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		logging.FromCtx(c).Error(apiErr.Message, "error_code", apiErr.Code, "error", apiErr.Err)
+	}
+
+	if apiErr.RetryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+
+	if wantsProblem(c) {
+		c.Set(fiber.HeaderContentType, problemJSON)
+		body, marshalErr := c.App().Config().JSONEncoder(Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(apiErr.Status),
+			Status:    apiErr.Status,
+			Detail:    apiErr.Message,
+			Instance:  c.Path(),
+			Code:      apiErr.Code,
+			RequestID: logging.RequestIDFrom(c),
+		})
+		if marshalErr != nil {
+			return marshalErr
+		}
+		return c.Status(apiErr.Status).Send(body)
+	}
+
+	return c.Status(apiErr.Status).JSON(models.Response{
+		Success: false,
+		Error:   apiErr.Message,
+		Code:    string(apiErr.Code),
+	})
+}
+
+// From converts any error to an *Error, mapping fiber's own errors (unknown routes,
+// oversized bodies, ...) by status
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		switch fiberErr.Code {
+		case fiber.StatusNotFound:
+			return New(fiberErr.Code, CodeNotFound, "Not found")
+		case fiber.StatusMethodNotAllowed:
+			return New(fiberErr.Code, CodeMethodNotAllowed, "Method not allowed")
+		case fiber.StatusRequestEntityTooLarge:
+			return New(fiberErr.Code, CodePayloadTooLarge, "Request body too large")
+		case fiber.StatusTooManyRequests:
+			return New(fiberErr.Code, CodeRateLimited, "Too many requests")
+		case fiber.StatusServiceUnavailable:
+			return New(fiberErr.Code, CodeUnavailable, "Service unavailable")
+		}
+		if fiberErr.Code < fiber.StatusInternalServerError {
+			return New(fiberErr.Code, CodeInvalidInput, fiberErr.Message)
+		}
+		return Internal(err, "Internal server error")
+	}
+
+	return Internal(err, "Internal server error")
+}
+
+func wantsProblem(c *fiber.Ctx) bool {
+	return strings.Contains(c.Get(fiber.HeaderAccept), problemJSON)
+}
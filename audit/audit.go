@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"mate/config"
+	"mate/logging"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var logger = logging.Logger("audit")
+
+const collection = "audit_logs"
+
+// redacted replaces the values of encrypted fields in diffs
+const redacted = "[redacted]"
+
+// Actions recorded in the audit log
+const (
+	ActionLogin                  = "auth.login"
+	ActionLoginFailed            = "auth.login_failed"
+	ActionLogout                 = "auth.logout"
+	ActionLogoutAll              = "auth.logout_all"
+	ActionPasswordReset          = "auth.password_reset"
+	ActionTwoFactorEnabled       = "auth.2fa_enabled"
+	ActionTwoFactorDisabled      = "auth.2fa_disabled"
+	ActionRecoveryCodesRotated   = "auth.recovery_codes_rotated"
+	ActionUserRegistered         = "user.registered"
+	ActionEmailVerified          = "user.email_verified"
+	ActionAPIKeyRotated          = "user.api_key_rotated"
+	ActionAPIKeyRevoked          = "user.api_key_revoked"
+	ActionUserDisabled           = "user.disabled"
+	ActionUserEnabled            = "user.enabled"
+	ActionDataExportRequested    = "user.data_export_requested"
+	ActionDeletionRequested      = "user.deletion_requested"
+	ActionDeletionCancelled      = "user.deletion_cancelled"
+	ActionAccountDeleted         = "user.deleted"
+	ActionTransactionCreated     = "transaction.created"
+	ActionTransactionUpdated     = "transaction.updated"
+	ActionTransactionReparsed    = "transaction.reparsed"
+	ActionWorkspaceMemberAdded   = "workspace.member_added"
+	ActionWorkspaceMemberUpdated = "workspace.member_updated"
+	ActionWorkspaceMemberRemoved = "workspace.member_removed"
+)
+
+// Target types
+const (
+	TargetUser        = "user"
+	TargetSession     = "session"
+	TargetTransaction = "transaction"
+	TargetWorkspace   = "workspace"
+)
+
+// Entry describes one event. Owner is the user whose data was affected, in the
+// same ObjectID string form the other collections use for user IDs.
+type Entry struct {
+	Actor      *models.User
+	Owner      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Diff       map[string]models.AuditChange
+}
+
+// Record appends an entry for the current request. Entries are not edited afterwards,
+// with one exception: when an account is deleted, its entries keep their actions and
+// times but lose the email, IP, user agent and diffs. Otherwise they only age out with
+// the retention TTL.
+func Record(c *fiber.Ctx, entry Entry) {
+	record := models.AuditLog{
+		OwnerID:    entry.Owner,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Diff:       entry.Diff,
+		CreatedAt:  time.Now(),
+	}
+	if c != nil {
+		record.IP = c.IP()
+		record.UserAgent = c.Get("User-Agent")
+	}
+	if entry.Actor != nil {
+		record.ActorID = entry.Actor.ID.String()
+		record.ActorEmail = entry.Actor.Email
+		if record.OwnerID == "" {
+			record.OwnerID = record.ActorID
+		}
+	}
+
+	if err := config.InsertOne(context.Background(), collection, record); err != nil {
+		logger.Error("error recording entry", "action", entry.Action, "error", err)
+	}
+}
+
+// EnsureIndexes creates the query indexes and the TTL index that enforces retention.
+// A retention of zero keeps entries forever.
+func EnsureIndexes(retention time.Duration) error {
+	indexes := config.Database.Collection(collection).Indexes()
+	ctx := context.Background()
+
+	if _, err := indexes.CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}); err != nil {
+		return err
+	}
+
+	// Changing retention means replacing the TTL index, which is left alone otherwise
+	ttlName := "created_at_ttl"
+	expireAfter := int32(retention.Seconds())
+	current, exists, err := ttlIndex(ctx, indexes, ttlName)
+	if err != nil {
+		return err
+	}
+	if exists && retention > 0 && current == expireAfter {
+		return nil
+	}
+	if exists {
+		if _, err := indexes.DropOne(ctx, ttlName); err != nil {
+			return err
+		}
+	}
+	if retention <= 0 {
+		return nil
+	}
+	_, err = indexes.CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetName(ttlName).SetExpireAfterSeconds(expireAfter),
+	})
+	return err
+}
+
+// ttlIndex returns the expiry of the index called name and whether it exists
+func ttlIndex(ctx context.Context, indexes mongo.IndexView, name string) (int32, bool, error) {
+	cursor, err := indexes.List(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	var specs []struct {
+		Name        string `bson:"name"`
+		ExpireAfter *int32 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return 0, false, err
+	}
+	for _, spec := range specs {
+		if spec.Name != name {
+			continue
+		}
+		if spec.ExpireAfter == nil {
+			return 0, true, nil
+		}
+		return *spec.ExpireAfter, true, nil
+	}
+	return 0, false, nil
+}
+
+// redactedFields are encrypted at rest, so diffs only record that they changed
+var redactedFields = map[string]bool{
+	"sender":    true,
+	"receiver":  true,
+	"reference": true,
+	"raw_sms":   true,
+}
+
+// sensitiveFields never appear in diffs
+var sensitiveFields = map[string]bool{
+	"password":            true,
+	"api_key":             true,
+	"totp_secret":         true,
+	"totp_pending_secret": true,
+	"recovery_codes":      true,
+	"refresh_token_hash":  true,
+}
+
+// Diff returns the fields that differ between two versions of a document
+func Diff(before, after interface{}) map[string]models.AuditChange {
+	beforeDoc := toDocument(before)
+	afterDoc := toDocument(after)
+
+	diff := map[string]models.AuditChange{}
+	for key, value := range afterDoc {
+		if sensitiveFields[key] {
+			continue
+		}
+		if previous, ok := beforeDoc[key]; !ok || !reflect.DeepEqual(previous, value) {
+			if redactedFields[key] {
+				diff[key] = models.AuditChange{From: redacted, To: redacted}
+				continue
+			}
+			diff[key] = models.AuditChange{From: beforeDoc[key], To: value}
+		}
+	}
+	for key, value := range beforeDoc {
+		if _, ok := afterDoc[key]; !ok && !sensitiveFields[key] {
+			if redactedFields[key] {
+				value = redacted
+			}
+			diff[key] = models.AuditChange{From: value, To: nil}
+		}
+	}
+	return diff
+}
+
+func toDocument(value interface{}) bson.M {
+	doc := bson.M{}
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return doc
+	}
+	_ = bson.Unmarshal(data, &doc)
+	return doc
+}
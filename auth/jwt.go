@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"mate/config"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrNoSigningKey = errors.New("no session signing key configured")
+)
+
+// Token types carried in the typ claim
+const (
+	TokenAccess = "access"
+	// TokenMFA proves the password step of a two-step login
+	TokenMFA = "mfa"
+)
+
+// Claims are the fields of a signed token
+type Claims struct {
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	ID        string `json:"jti,omitempty"`
+	Type      string `json:"typ"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// SigningEnabled reports whether an active signing key is configured. Without one
+// only API keys work: no sessions and no two-step logins.
+func SigningEnabled() bool {
+	_, ok := config.Current.Session.Keys[config.Current.Session.ActiveKey]
+	return ok
+}
+
+// Sign creates an HS256 JWT with the active signing key
+func Sign(claims Claims) (string, error) {
+	kid := config.Current.Session.ActiveKey
+	secret, ok := config.Current.Session.Keys[kid]
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+
+	headerJSON, err := json.Marshal(header{Alg: "HS256", Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encoding.EncodeToString(headerJSON) + "." + encoding.EncodeToString(claimsJSON)
+	return unsigned + "." + encoding.EncodeToString(signature(secret, unsigned)), nil
+}
+
+// Parse verifies a JWT against the configured keys and returns its claims
+func Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil || h.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens signed with a key that has since been removed are rejected
+	secret, ok := config.Current.Session.Keys[h.Kid]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, signature(secret, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+
+	claimsJSON, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+// LooksLikeJWT tells tokens apart from API keys, which never contain dots
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func signature(secret, unsigned string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
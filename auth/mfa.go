@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"mate/config"
+	"mate/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mfaTokenTTL is how long a user has to enter the second factor after the password
+const mfaTokenTTL = 5 * time.Minute
+
+var ErrInvalidSecondFactor = errors.New("invalid two-factor code")
+
+// usedMFATokens records the IDs of redeemed MFA tokens until they expire
+const usedMFATokens = "used_mfa_tokens"
+
+// IssueMFAToken returns a short-lived, single-use token that stands in for a verified
+// password
+func IssueMFAToken(user models.User) (string, error) {
+	now := time.Now()
+	return Sign(Claims{
+		Subject:   user.ID.Hex(),
+		Type:      TokenMFA,
+		ID:        primitive.NewObjectID().Hex(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(mfaTokenTTL).Unix(),
+	})
+}
+
+// MFAToken is a parsed token from IssueMFAToken
+type MFAToken struct {
+	UserID    primitive.ObjectID
+	id        string
+	expiresAt time.Time
+}
+
+// ParseMFAToken returns the user a token from IssueMFAToken was issued to. The token
+// stays usable until Redeem, so a mistyped code can be corrected; guessing is held
+// back by the login throttle.
+func ParseMFAToken(token string) (*MFAToken, error) {
+	claims, err := Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenMFA || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	_, err = config.FindOne(context.Background(), usedMFATokens, bson.M{"_id": claims.ID})
+	if err == nil {
+		return nil, ErrInvalidToken
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return &MFAToken{UserID: userID, id: claims.ID, expiresAt: time.Unix(claims.ExpiresAt, 0)}, nil
+}
+
+// Redeem uses the token up once the second factor was accepted, so it can't sign in
+// again
+func (t *MFAToken) Redeem() error {
+	// The ID is the document key, so of two concurrent redemptions only one inserts
+	if err := config.InsertOne(context.Background(), usedMFATokens, bson.M{
+		"_id":        t.id,
+		"expires_at": t.expiresAt,
+	}); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+	return nil
+}
+
+// EnsureIndexes creates the TTL index that drops used MFA tokens once they expire
+func EnsureIndexes() error {
+	_, err := config.Database.Collection(usedMFATokens).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused recovery code.
+// TOTP codes can't be replayed and recovery codes are removed once used.
+func VerifySecondFactor(user models.User, code, recoveryCode string) error {
+	if !user.TOTPEnabled {
+		return ErrInvalidSecondFactor
+	}
+
+	if recoveryCode != "" {
+		hash := HashRecoveryCode(recoveryCode)
+		result, err := config.UpdateOne(context.Background(), "users", bson.M{"_id": user.ID, "recovery_codes": hash}, bson.M{
+			"$pull": bson.M{"recovery_codes": hash},
+		})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return ErrInvalidSecondFactor
+		}
+		return nil
+	}
+
+	step, ok := ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidSecondFactor
+	}
+
+	// Only move forward in time, so a code can't be used twice
+	result, err := config.UpdateOne(context.Background(), "users", bson.M{
+		"_id": user.ID,
+		"$or": []bson.M{
+			{"totp_last_step": bson.M{"$exists": false}},
+			{"totp_last_step": bson.M{"$lt": step}},
+		},
+	}, bson.M{"$set": bson.M{"totp_last_step": step}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return ErrInvalidSecondFactor
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"mate/config"
+	"mate/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+// Tokens is the pair handed to a client when a session starts or is refreshed
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// StartSession creates a server-side session for user and returns its first token pair
+func StartSession(user models.User, userAgent, ip string) (*Tokens, error) {
+	if !SigningEnabled() {
+		return nil, ErrNoSigningKey
+	}
+
+	sessionID := primitive.NewObjectID()
+	refreshToken, refreshHash, err := newRefreshToken(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:               sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: refreshHash,
+		UserAgent:        userAgent,
+		IP:               ip,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(config.Current.Session.RefreshTokenTTL),
+	}
+	if err := config.InsertOne(context.Background(), "sessions", session); err != nil {
+		return nil, err
+	}
+
+	return issue(session, refreshToken)
+}
+
+// Refresh rotates the refresh token of a session and issues a new access token.
+// Presenting an already rotated refresh token means it leaked, so the whole session is revoked.
+func Refresh(refreshToken, userAgent, ip string) (*Tokens, error) {
+	sessionHex, _, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	sessionID, err := primitive.ObjectIDFromHex(sessionHex)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := findSession(sessionID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	presented := HashToken(refreshToken)
+	if presented != session.RefreshTokenHash {
+		if presented == session.PreviousRefreshHash {
+			_ = RevokeSession(session.ID)
+			return nil, ErrSessionRevoked
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newToken, newHash, err := newRefreshToken(session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := config.UpdateOne(context.Background(), "sessions", bson.M{"_id": session.ID, "refresh_token_hash": presented}, bson.M{
+		"$set": bson.M{
+			"refresh_token_hash":    newHash,
+			"previous_refresh_hash": presented,
+			"last_used_at":          now,
+			"user_agent":            userAgent,
+			"ip":                    ip,
+			"expires_at":            now.Add(config.Current.Session.RefreshTokenTTL),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Lost a race with a concurrent refresh using the same token
+	if result.ModifiedCount == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return issue(*session, newToken)
+}
+
+// Authenticate verifies an access token and returns the live session it belongs to
+func Authenticate(accessToken string) (*models.Session, error) {
+	claims, err := Parse(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenAccess {
+		return nil, ErrInvalidToken
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	session, err := findSession(sessionID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	return session, nil
+}
+
+// RevokeSession ends a single session
+func RevokeSession(sessionID primitive.ObjectID) error {
+	_, err := config.UpdateOne(context.Background(), "sessions", bson.M{"_id": sessionID, "revoked_at": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"revoked_at": time.Now()},
+	})
+	return err
+}
+
+// RevokeAllSessions ends every session of a user and returns how many were revoked
+func RevokeAllSessions(userID primitive.ObjectID) (int64, error) {
+	result, err := config.UpdateMany(context.Background(), "sessions", bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"revoked_at": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func issue(session models.Session, refreshToken string) (*Tokens, error) {
+	now := time.Now()
+	accessToken, err := Sign(Claims{
+		Subject:   session.UserID.Hex(),
+		SessionID: session.ID.Hex(),
+		Type:      TokenAccess,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(config.Current.Session.AccessTokenTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(config.Current.Session.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// newRefreshToken returns "<session id>.<random>" and its hash; only the hash is stored
+func newRefreshToken(sessionID primitive.ObjectID) (string, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", err
+	}
+	token := sessionID.Hex() + "." + hex.EncodeToString(bytes)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the form opaque tokens are stored in
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func findSession(sessionID primitive.ObjectID) (*models.Session, error) {
+	result, err := config.FindOne(context.Background(), "sessions", bson.M{"_id": sessionID})
+	if err != nil {
+		return nil, err
+	}
+	var session models.Session
+	if err := result.Decode(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"mate/config"
+	"mate/logging"
+)
+
+var logger = logging.Logger("auth")
+
+var (
+	ErrLoginThrottled = errors.New("too many failed attempts, try again later")
+	ErrLoginLocked    = errors.New("account temporarily locked")
+)
+
+// ThrottlePolicy describes how failures for one kind of key are punished
+type ThrottlePolicy struct {
+	// FreeAttempts may fail before any delay applies
+	FreeAttempts int
+	// MaxDelay caps the delay, which doubles with every failure past FreeAttempts
+	MaxDelay time.Duration
+	// LockoutThreshold failures lock the key for LockoutDuration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Window after the last failure when the counter starts over
+	Window time.Duration
+}
+
+var (
+	accountPolicy = ThrottlePolicy{
+		FreeAttempts:     3,
+		MaxDelay:         30 * time.Second,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+		Window:           15 * time.Minute,
+	}
+	// IPs get more room since many users can share one (mobile carriers NAT heavily)
+	ipPolicy = ThrottlePolicy{
+		FreeAttempts:     10,
+		MaxDelay:         30 * time.Second,
+		LockoutThreshold: 50,
+		LockoutDuration:  30 * time.Minute,
+		Window:           30 * time.Minute,
+	}
+)
+
+// LoginGuard throttles failed logins per account and per IP
+type LoginGuard struct {
+	store AttemptStore
+}
+
+func NewLoginGuard(store AttemptStore) *LoginGuard {
+	return &LoginGuard{store: store}
+}
+
+// Guard is the login guard used by the HTTP handlers, configured by InitLoginGuard
+var Guard = NewLoginGuard(NewMemoryAttemptStore())
+
+// InitLoginGuard picks the counter store from config
+func InitLoginGuard() {
+	if config.Current.Auth.LoginThrottleStore == "memory" {
+		Guard = NewLoginGuard(NewMemoryAttemptStore())
+		return
+	}
+	store := NewMongoAttemptStore()
+	if err := store.EnsureIndexes(); err != nil {
+		logger.Error("error creating login throttle indexes", "error", err)
+	}
+	Guard = NewLoginGuard(store)
+}
+
+// Check returns an error and how long to wait when a login attempt must be refused
+func (g *LoginGuard) Check(email, ip string) (time.Duration, error) {
+	for _, check := range []struct {
+		key    string
+		policy ThrottlePolicy
+	}{
+		{accountKey(email), accountPolicy},
+		{ipKey(ip), ipPolicy},
+	} {
+		counter, err := g.store.Get(check.key)
+		if err != nil {
+			// Fail open, a broken store must not lock everyone out
+			logger.Error("error reading login counter", "error", err)
+			continue
+		}
+
+		now := time.Now()
+		if counter.LockedUntil.After(now) {
+			return counter.LockedUntil.Sub(now), ErrLoginLocked
+		}
+		if now.Sub(counter.LastFailure) > check.policy.Window {
+			continue
+		}
+		if wait := counter.LastFailure.Add(check.policy.delay(counter.Failures)).Sub(now); wait > 0 {
+			return wait, ErrLoginThrottled
+		}
+	}
+	return 0, nil
+}
+
+// Failure records a failed attempt against the account and the IP
+func (g *LoginGuard) Failure(email, ip string) {
+	g.fail(accountKey(email), accountPolicy)
+	g.fail(ipKey(ip), ipPolicy)
+}
+
+// Success clears the account counter; the IP counter is left to expire on its own
+func (g *LoginGuard) Success(email string) {
+	if err := g.store.Reset(accountKey(email)); err != nil {
+		logger.Error("error resetting login counter", "error", err)
+	}
+}
+
+func (g *LoginGuard) fail(key string, policy ThrottlePolicy) {
+	counter, err := g.store.Increment(key, policy.Window)
+	if err != nil {
+		logger.Error("error recording login failure", "error", err)
+		return
+	}
+	if counter.Failures >= policy.LockoutThreshold {
+		if err := g.store.Lock(key, time.Now().Add(policy.LockoutDuration)); err != nil {
+			logger.Error("error locking login", "error", err)
+		}
+	}
+}
+
+// delay is 0 for the free attempts, then 1s, 2s, 4s... up to MaxDelay
+func (p ThrottlePolicy) delay(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over < 0 {
+		return 0
+	}
+	if over > 10 {
+		return p.MaxDelay
+	}
+	delay := time.Second << over
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
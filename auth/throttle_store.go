@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"mate/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Counter tracks failed logins for one account or IP
+type Counter struct {
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"last_failure"`
+	LockedUntil time.Time `bson:"locked_until"`
+}
+
+// AttemptStore keeps failed login counters
+type AttemptStore interface {
+	Get(key string) (Counter, error)
+	// Increment records a failure, starting over when the last one is older than window
+	Increment(key string, window time.Duration) (Counter, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// MemoryAttemptStore keeps counters in process; limits only hold per instance
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	counters map[string]Counter
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{counters: make(map[string]Counter)}
+}
+
+func (s *MemoryAttemptStore) Get(key string) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[key], nil
+}
+
+func (s *MemoryAttemptStore) Increment(key string, window time.Duration) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	counter := s.counters[key]
+	if now.Sub(counter.LastFailure) > window {
+		counter.Failures = 0
+	}
+	counter.Failures++
+	counter.LastFailure = now
+	s.counters[key] = counter
+	return counter, nil
+}
+
+func (s *MemoryAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter := s.counters[key]
+	counter.LockedUntil = until
+	s.counters[key] = counter
+	return nil
+}
+
+func (s *MemoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.counters, key)
+	return nil
+}
+
+// MongoAttemptStore keeps counters in MongoDB so every instance sees the same limits.
+// Each counter carries an expires_at past its window and lock, after which MongoDB
+// drops it.
+type MongoAttemptStore struct {
+	collection string
+}
+
+func NewMongoAttemptStore() *MongoAttemptStore {
+	return &MongoAttemptStore{collection: "login_throttle"}
+}
+
+// EnsureIndexes creates the TTL index that drops expired counters
+func (s *MongoAttemptStore) EnsureIndexes() error {
+	_, err := config.Database.Collection(s.collection).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (s *MongoAttemptStore) Get(key string) (Counter, error) {
+	var counter Counter
+	result, err := config.FindOne(context.Background(), s.collection, bson.M{"_id": key})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// No document means no failures
+		return counter, nil
+	}
+	if err != nil {
+		return counter, err
+	}
+	err = result.Decode(&counter)
+	return counter, err
+}
+
+// Increment counts and restarts the window in one pipeline update, so concurrent
+// failures from several instances are never lost
+func (s *MongoAttemptStore) Increment(key string, window time.Duration) (Counter, error) {
+	now := time.Now()
+	result := config.FindOneAndUpsert(context.Background(), s.collection, bson.M{"_id": key}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$last_failure", now.Add(-window)}},
+				bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+				1,
+			}},
+			"last_failure": now,
+			"expires_at":   bson.M{"$max": bson.A{"$expires_at", now.Add(window)}},
+		}}},
+	})
+
+	var counter Counter
+	err := result.Decode(&counter)
+	return counter, err
+}
+
+func (s *MongoAttemptStore) Lock(key string, until time.Time) error {
+	_, err := config.UpsertOne(context.Background(), s.collection, bson.M{"_id": key}, bson.M{
+		"$set": bson.M{"locked_until": until},
+		"$max": bson.M{"expires_at": until},
+	})
+	return err
+}
+
+func (s *MongoAttemptStore) Reset(key string) error {
+	_, err := config.DeleteOne(context.Background(), s.collection, bson.M{"_id": key})
+	return err
+}
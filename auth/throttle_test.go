@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLoginGuard(t *testing.T) {
+	tests := []struct {
+		name string
+		// failures are recorded for ama@example.com from these IPs, in order
+		failures []string
+		// success clears the account counter before the check
+		success bool
+		email   string
+		ip      string
+		wantErr error
+	}{
+		{"no failures", nil, false, "ama@example.com", "192.0.2.1", nil},
+		{"within the free attempts", repeat("192.0.2.1", 2), false, "ama@example.com", "192.0.2.1", nil},
+		{"delay once the free attempts are used", repeat("192.0.2.1", 3), false, "ama@example.com", "192.0.2.1", ErrLoginThrottled},
+		{"delay follows the account to other IPs", repeat("192.0.2.1", 4), false, "ama@example.com", "198.51.100.7", ErrLoginThrottled},
+		{"account key ignores case and spaces", repeat("192.0.2.1", 4), false, " AMA@example.com", "198.51.100.7", ErrLoginThrottled},
+		{"other accounts are not delayed", repeat("192.0.2.1", 4), false, "kofi@example.com", "198.51.100.7", nil},
+		{"account lockout", repeat("192.0.2.1", 10), false, "ama@example.com", "198.51.100.7", ErrLoginLocked},
+		{"success clears the account", repeat("192.0.2.1", 5), true, "ama@example.com", "198.51.100.7", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := NewLoginGuard(NewMemoryAttemptStore())
+			for _, ip := range tt.failures {
+				guard.Failure("ama@example.com", ip)
+			}
+			if tt.success {
+				guard.Success("ama@example.com")
+			}
+
+			wait, err := guard.Check(tt.email, tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if (wait > 0) != (tt.wantErr != nil) {
+				t.Fatalf("got wait %s with error %v", wait, err)
+			}
+		})
+	}
+}
+
+func TestLoginGuardLocksBusyIPs(t *testing.T) {
+	guard := NewLoginGuard(NewMemoryAttemptStore())
+	for i := 0; i < ipPolicy.LockoutThreshold; i++ {
+		guard.Failure(fmt.Sprintf("user%d@example.com", i), "192.0.2.1")
+	}
+
+	if _, err := guard.Check("new@example.com", "192.0.2.1"); !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("got error %v from the locked IP, want %v", err, ErrLoginLocked)
+	}
+	if _, err := guard.Check("new@example.com", "192.0.2.2"); err != nil {
+		t.Fatalf("got error %v from another IP, want none", err)
+	}
+}
+
+func TestThrottleDelay(t *testing.T) {
+	policy := ThrottlePolicy{FreeAttempts: 3, MaxDelay: 30 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{7, 16 * time.Second},
+		{8, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.failures), func(t *testing.T) {
+			if got := policy.delay(tt.failures); got != tt.want {
+				t.Fatalf("delay(%d) = %s, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func repeat(ip string, n int) []string {
+	ips := make([]string, n)
+	for i := range ips {
+		ips[i] = ip
+	}
+	return ips
+}
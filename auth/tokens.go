@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"mate/config"
+	"mate/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purposes of single-use tokens sent by email
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// IssueUserToken creates a single-use token for purpose that expires after ttl.
+// Older unused tokens for the same purpose are invalidated.
+func IssueUserToken(userID primitive.ObjectID, purpose string, ttl time.Duration) (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(bytes)
+
+	now := time.Now()
+	if _, err := config.UpdateMany(context.Background(), "user_tokens", bson.M{
+		"user_id": userID,
+		"purpose": purpose,
+		"used_at": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"used_at": now}}); err != nil {
+		return "", err
+	}
+
+	if err := config.InsertOne(context.Background(), "user_tokens", models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeUserToken marks a token as used and returns the user it was issued to
+func ConsumeUserToken(token, purpose string) (primitive.ObjectID, error) {
+	filter := bson.M{
+		"token_hash": HashToken(token),
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+
+	result, err := config.FindOne(context.Background(), "user_tokens", filter)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidUserToken
+	}
+	var userToken models.UserToken
+	if err := result.Decode(&userToken); err != nil {
+		return primitive.NilObjectID, ErrInvalidUserToken
+	}
+
+	// The used_at condition makes concurrent redemptions of the same token lose
+	filter["_id"] = userToken.ID
+	update, err := config.UpdateOne(context.Background(), "user_tokens", filter, bson.M{"$set": bson.M{"used_at": time.Now()}})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if update.ModifiedCount == 0 {
+		return primitive.NilObjectID, ErrInvalidUserToken
+	}
+	return userToken.UserID, nil
+}
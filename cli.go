@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"mate/config"
+	"mate/routes"
+)
+
+const usage = `usage: mate [command]
+
+Without a command the API server is started.
+
+commands:
+  config print [--redacted]       print the effective configuration and where each value came from
+  openapi                         print the OpenAPI document served at /openapi.json
+
+admin commands, run against the configured database:
+  users create <email> [--password-stdin]
+                                  create an account; a password is generated and printed unless
+                                  --password-stdin reads one from the first line of standard input
+  users disable <email>           block sign-in and API access and end every session
+  users enable <email>            lift a block
+  apikeys issue <email>           replace the user's API key and print the new one
+  apikeys revoke <email>          remove the user's API key until a new one is issued
+  migrate                         create indexes, rewrap data keys and encrypt old transactions
+  reparse [--user <email>] [--origin <sender>] [--from <date>] [--to <date>]
+          [--parser-version <version>|none] [--dry-run]
+                                  re-parse SMS transactions with the current parser, printing the
+                                  fields that change; fields corrected by hand are kept
+  imports retry <job-id>          retry the failed items of an import job
+  reconcile <email> <origin> <statement.pdf> [--layout <name>]
+                                  reconcile a PDF bank statement with the user's transactions
+  export <email> [--out <file>]   build a data export and print its path or copy it to file
+`
+
+// runCommand runs a command line subcommand and returns the exit code
+func runCommand(args []string) int {
+	switch {
+	case len(args) >= 2 && args[0] == "config" && args[1] == "print":
+		return printConfig(args[2:])
+	case len(args) == 1 && args[0] == "openapi":
+		return printOpenAPI()
+	case adminCommands[args[0]] != nil:
+		return runAdmin(adminCommands[args[0]], args[1:])
+	case len(args) >= 2 && adminCommands[args[0]+" "+args[1]] != nil:
+		return runAdmin(adminCommands[args[0]+" "+args[1]], args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+func printConfig(args []string) int {
+	redacted := false
+	for _, arg := range args {
+		switch arg {
+		case "--redacted":
+			redacted = true
+		default:
+			fmt.Fprintf(os.Stderr, "unknown flag %s\n\n%s", arg, usage)
+			return 2
+		}
+	}
+
+	cfg, sources, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	config.Print(os.Stdout, cfg, sources, redacted)
+	return 0
+}
+
+func printOpenAPI() int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(routes.Spec()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
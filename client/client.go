@@ -0,0 +1,163 @@
+// Package client calls the Mate API. The types and one method per operation in
+// client_gen.go are generated from the OpenAPI document served at /openapi.json;
+// run go generate ./client after changing a route.
+package client
+
+//go:generate go run ./internal/clientgen -o client_gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Doer sends requests; *http.Client satisfies it, and so does an adapter around
+// fiber's App.Test for calling the server in-process
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client calls one Mate server. Token is an access token from Login or an API key;
+// Workspace, when set, acts on a shared workspace instead of the personal ledger.
+type Client struct {
+	BaseURL    string
+	Token      string
+	Workspace  string
+	HTTPClient Doer
+}
+
+// New returns a client for the server at baseURL, e.g. https://mate.example.com
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Error is a failed request. Branch on Code, the message is meant for people.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mate: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+	Code    string          `json:"code"`
+}
+
+// do sends a JSON request and decodes the data of the response envelope into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	resp, err := c.send(ctx, method, path, query, reader, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, out)
+}
+
+// upload sends a multipart form with file under the "file" field
+func (c *Client) upload(ctx context.Context, path string, file io.Reader, filename string, fields map[string]string, out any) error {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	resp, err := c.send(ctx, http.MethodPost, path, nil, &buf, form.FormDataContentType())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, out)
+}
+
+// stream returns the body of a download or event stream; the caller closes it
+func (c *Client) stream(ctx context.Context, method, path string, query url.Values) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, method, path, query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, decode(resp, nil)
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.Workspace != "" {
+		req.Header.Set("X-Workspace-ID", c.Workspace)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+func decode(resp *http.Response, out any) error {
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		if resp.StatusCode >= 400 {
+			return &Error{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		}
+		return fmt.Errorf("mate: decoding response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return &Error{Status: resp.StatusCode, Code: env.Code, Message: env.Error}
+	}
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+// pathEscape escapes a path parameter
+func pathEscape(value string) string {
+	return url.PathEscape(value)
+}
@@ -0,0 +1,886 @@
+// Code generated by clientgen from the OpenAPI document (Mate API 1.0.0). DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type APIKey struct {
+	APIKey string `json:"api_key"`
+}
+
+type AccountsRequest struct {
+	Accounts []string `json:"accounts"`
+}
+
+type AmountChange struct {
+	Amount           float64 `json:"amount"`
+	PercentageChange float64 `json:"percentageChange"`
+}
+
+type AuditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+type AuditLog struct {
+	Action     string                 `json:"action"`
+	ActorEmail string                 `json:"actor_email,omitempty"`
+	ActorID    string                 `json:"actor_id,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	Diff       map[string]AuditChange `json:"diff,omitempty"`
+	ID         string                 `json:"id"`
+	IP         string                 `json:"ip"`
+	OwnerID    string                 `json:"owner_id,omitempty"`
+	TargetID   string                 `json:"target_id,omitempty"`
+	TargetType string                 `json:"target_type"`
+	UserAgent  string                 `json:"user_agent"`
+}
+
+type BasicStats struct {
+	Expense           AmountChange `json:"expense"`
+	Income            AmountChange `json:"income"`
+	NetFlow           AmountChange `json:"netFlow"`
+	TotalTransactions int          `json:"totalTransactions"`
+}
+
+type CodeRequest struct {
+	Code string `json:"code"`
+}
+
+type ConsumeRequest struct {
+	Message string `json:"message"`
+	Sender  string `json:"sender"`
+	Time    string `json:"time"`
+}
+
+type ConsumeResult struct {
+	Message string `json:"message"`
+	Success bool   `json:"success"`
+}
+
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type DailyStats struct {
+	Balance float64 `json:"balance"`
+	Credits float64 `json:"credits"`
+	Date    string  `json:"date"`
+	Debits  float64 `json:"debits"`
+	NetFlow float64 `json:"netFlow"`
+}
+
+type DataExport struct {
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Error       string     `json:"error,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ID          string     `json:"id"`
+	Size        int64      `json:"size,omitempty"`
+	Status      string     `json:"status"`
+	UserID      string     `json:"user_id"`
+}
+
+type DeletionSchedule struct {
+	DeletionScheduledFor string `json:"deletion_scheduled_for"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+type HourlyStats struct {
+	Hour         string  `json:"hour"`
+	Transactions int     `json:"transactions"`
+	Volume       float64 `json:"volume"`
+}
+
+type ImportItem struct {
+	Body   string       `json:"body,omitempty"`
+	ID     string       `json:"id"`
+	Index  int          `json:"index"`
+	JobID  string       `json:"job_id"`
+	Reason string       `json:"reason,omitempty"`
+	Row    StatementRow `json:"row,omitempty"`
+	Sender string       `json:"sender"`
+	Status string       `json:"status"`
+	Time   string       `json:"time,omitempty"`
+}
+
+type ImportJob struct {
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Duplicates  int        `json:"duplicates"`
+	Failed      int        `json:"failed"`
+	Filename    string     `json:"filename"`
+	Format      string     `json:"format"`
+	ID          string     `json:"id"`
+	Imported    int        `json:"imported"`
+	Origin      string     `json:"origin,omitempty"`
+	Processed   int        `json:"processed"`
+	Skipped     int        `json:"skipped"`
+	Status      string     `json:"status"`
+	Total       int        `json:"total"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	UserID      string     `json:"user_id"`
+}
+
+type ImportReport struct {
+	Duplicates int          `json:"duplicates"`
+	Failed     int          `json:"failed"`
+	Failures   []ImportItem `json:"failures"`
+	Imported   int          `json:"imported"`
+	Job        ImportJob    `json:"job"`
+	Remaining  int          `json:"remaining"`
+	Skipped    int          `json:"skipped"`
+}
+
+type InviteAcceptance struct {
+	Role        string `json:"role"`
+	WorkspaceID string `json:"workspace_id"`
+}
+
+type InviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type LoginAttempt struct {
+	CreatedAt time.Time `json:"created_at"`
+	Email     string    `json:"email"`
+	ID        string    `json:"id"`
+	IP        string    `json:"ip"`
+	Reason    string    `json:"reason,omitempty"`
+	Success   bool      `json:"success"`
+	UserAgent string    `json:"user_agent"`
+}
+
+type LoginResult struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	Email        string `json:"email,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	UserID       string `json:"user_id,omitempty"`
+}
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+type OriginAnalysis struct {
+	Origins       []OriginStats `json:"origins"`
+	PrimaryOrigin string        `json:"primaryOrigin"`
+}
+
+type OriginStats struct {
+	Name       string  `json:"name"`
+	Percentage float64 `json:"percentage"`
+	Value      int     `json:"value"`
+}
+
+type ReauthRequest struct {
+	Code         string `json:"code,omitempty"`
+	Password     string `json:"password"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RecoveryCodesRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type Registration struct {
+	APIKey string `json:"api_key"`
+	Email  string `json:"email"`
+	UserID string `json:"user_id"`
+}
+
+type ResetPasswordRequest struct {
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+type RevokedSessions struct {
+	Revoked int64 `json:"revoked"`
+}
+
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+type Session struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ID         string     `json:"id"`
+	IP         string     `json:"ip"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	UserAgent  string     `json:"user_agent"`
+	UserID     string     `json:"user_id"`
+}
+
+type SharedAccounts struct {
+	Accounts []string `json:"accounts"`
+}
+
+type StatementFailure struct {
+	Reason string       `json:"reason"`
+	Row    StatementRow `json:"row"`
+}
+
+type StatementReport struct {
+	Duplicates int                `json:"duplicates"`
+	Failed     int                `json:"failed"`
+	Failures   []StatementFailure `json:"failures"`
+	Inserted   int                `json:"inserted"`
+	Layout     string             `json:"layout"`
+	Reconciled int                `json:"reconciled"`
+	Rows       int                `json:"rows"`
+}
+
+type StatementRow struct {
+	Amount      float64   `json:"amount"`
+	Balance     float64   `json:"balance"`
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Reference   string    `json:"reference"`
+	Type        string    `json:"type"`
+}
+
+type TOTPEnrollment struct {
+	OtpauthURI string `json:"otpauth_uri"`
+	Secret     string `json:"secret"`
+}
+
+type TimeAnalysis struct {
+	DailyStats  []DailyStats  `json:"dailyStats"`
+	HourlyStats []HourlyStats `json:"hourlyStats"`
+}
+
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+}
+
+type Transaction struct {
+	Amount        float64    `json:"amount"`
+	BalanceAfter  float64    `json:"balance_after"`
+	BalanceBefore float64    `json:"balance_before"`
+	Date          string     `json:"date"`
+	EditedFields  []string   `json:"edited_fields,omitempty"`
+	Fee           float64    `json:"fee"`
+	ID            string     `json:"id"`
+	Origin        string     `json:"origin"`
+	ParserVersion string     `json:"parser_version,omitempty"`
+	RawSMS        string     `json:"raw_sms"`
+	Receiver      string     `json:"receiver"`
+	ReconciledAt  *time.Time `json:"reconciled_at,omitempty"`
+	Reference     string     `json:"reference"`
+	ReparsedAt    *time.Time `json:"reparsed_at,omitempty"`
+	Sender        string     `json:"sender"`
+	Source        string     `json:"source"`
+	Tax           float64    `json:"tax"`
+	Timestamp     time.Time  `json:"timestamp"`
+	TransactionID string     `json:"transaction_id"`
+	Type          string     `json:"type"`
+	Userid        string     `json:"userid"`
+}
+
+type TransactionAnalysis struct {
+	BasicStats          BasicStats       `json:"basicStats"`
+	OriginAnalysis      OriginAnalysis   `json:"originAnalysis"`
+	TimeAnalysis        TimeAnalysis     `json:"timeAnalysis"`
+	TransactionAnalysis TransactionStats `json:"transactionAnalysis"`
+	Transactions        []Transaction    `json:"transactions"`
+	Undecryptable       int              `json:"undecryptable,omitempty"`
+	UserAnalysis        UserAnalysis     `json:"userAnalysis"`
+}
+
+type TransactionStats struct {
+	AverageTransaction float64 `json:"averageTransaction"`
+	MaxBalance         float64 `json:"maxBalance"`
+	MaxTransaction     float64 `json:"maxTransaction"`
+	MinBalance         float64 `json:"minBalance"`
+	MinTransaction     float64 `json:"minTransaction"`
+	TotalFees          float64 `json:"totalFees"`
+	TotalTax           float64 `json:"totalTax"`
+}
+
+type TransactionUpdate struct {
+	Amount    *float64 `json:"amount,omitempty"`
+	Date      *string  `json:"date,omitempty"`
+	Fee       *float64 `json:"fee,omitempty"`
+	Receiver  *string  `json:"receiver,omitempty"`
+	Reference *string  `json:"reference,omitempty"`
+	Sender    *string  `json:"sender,omitempty"`
+	Tax       *float64 `json:"tax,omitempty"`
+	Type      *string  `json:"type,omitempty"`
+}
+
+type TwoFactorLoginRequest struct {
+	Code         string `json:"code"`
+	MFAToken     string `json:"mfa_token"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type UserAnalysis struct {
+	UniqueUsers int         `json:"uniqueUsers"`
+	UserStats   []UserStats `json:"userStats"`
+}
+
+type UserStats struct {
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Value float64 `json:"value"`
+}
+
+type Webhook struct {
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	Events    []string  `json:"events"`
+	ID        string    `json:"id"`
+	Secret    string    `json:"secret,omitempty"`
+	URL       string    `json:"url"`
+	UserID    string    `json:"user_id"`
+}
+
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	StatusCode int       `json:"status_code"`
+}
+
+type WebhookDelivery struct {
+	Attempts  []WebhookAttempt `json:"attempts"`
+	CreatedAt time.Time        `json:"created_at"`
+	Event     string           `json:"event"`
+	ID        string           `json:"id"`
+	Payload   string           `json:"payload"`
+	Status    string           `json:"status"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	UserID    string           `json:"user_id"`
+	WebhookID string           `json:"webhook_id"`
+}
+
+type WebhookList struct {
+	Events   []string  `json:"events"`
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type WebhookRequest struct {
+	Events []string `json:"events"`
+	URL    string   `json:"url"`
+}
+
+type Workspace struct {
+	CreatedAt time.Time         `json:"created_at"`
+	ID        string            `json:"id"`
+	Members   []WorkspaceMember `json:"members"`
+	Name      string            `json:"name"`
+	OwnerID   string            `json:"owner_id"`
+}
+
+type WorkspaceInvite struct {
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Email       string     `json:"email"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ID          string     `json:"id"`
+	InvitedBy   string     `json:"invited_by"`
+	Role        string     `json:"role"`
+	WorkspaceID string     `json:"workspace_id"`
+}
+
+type WorkspaceMember struct {
+	Accounts []string  `json:"accounts"`
+	Email    string    `json:"email"`
+	JoinedAt time.Time `json:"joined_at"`
+	Role     string    `json:"role"`
+	UserID   string    `json:"user_id"`
+}
+
+type WorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// ConfirmTwoFactor calls POST /v1/2fa/confirm (200): enable two-factor with the first code
+func (c *Client) ConfirmTwoFactor(ctx context.Context, body CodeRequest) (*RecoveryCodes, error) {
+	var out RecoveryCodes
+	if err := c.do(ctx, "POST", "/v1/2fa/confirm", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DisableTwoFactor calls POST /v1/2fa/disable (200): turn two-factor off
+func (c *Client) DisableTwoFactor(ctx context.Context, body ReauthRequest) error {
+	return c.do(ctx, "POST", "/v1/2fa/disable", nil, body, nil)
+}
+
+// EnrollTwoFactor calls POST /v1/2fa/enroll (200): generate a TOTP secret
+func (c *Client) EnrollTwoFactor(ctx context.Context) (*TOTPEnrollment, error) {
+	var out TOTPEnrollment
+	if err := c.do(ctx, "POST", "/v1/2fa/enroll", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RegenerateRecoveryCodes calls POST /v1/2fa/recovery-codes (200): replace the recovery codes
+func (c *Client) RegenerateRecoveryCodes(ctx context.Context, body RecoveryCodesRequest) (*RecoveryCodes, error) {
+	var out RecoveryCodes
+	if err := c.do(ctx, "POST", "/v1/2fa/recovery-codes", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelDeletion calls DELETE /v1/account/deletion (200): cancel a scheduled deletion
+func (c *Client) CancelDeletion(ctx context.Context) error {
+	return c.do(ctx, "DELETE", "/v1/account/deletion", nil, nil, nil)
+}
+
+// RequestDeletion calls POST /v1/account/deletion (202): schedule the account for deletion
+func (c *Client) RequestDeletion(ctx context.Context, body ReauthRequest) (*DeletionSchedule, error) {
+	var out DeletionSchedule
+	if err := c.do(ctx, "POST", "/v1/account/deletion", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListExports calls GET /v1/account/exports (200): list data exports
+func (c *Client) ListExports(ctx context.Context) ([]DataExport, error) {
+	var out []DataExport
+	err := c.do(ctx, "GET", "/v1/account/exports", nil, nil, &out)
+	return out, err
+}
+
+// CreateExport calls POST /v1/account/exports (202): start a data export
+func (c *Client) CreateExport(ctx context.Context) (*DataExport, error) {
+	var out DataExport
+	if err := c.do(ctx, "POST", "/v1/account/exports", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DownloadExport calls GET /v1/account/exports/{id}/download (200): download a finished export
+func (c *Client) DownloadExport(ctx context.Context, id string) (io.ReadCloser, error) {
+	return c.stream(ctx, "GET", "/v1/account/exports/"+pathEscape(id)+"/download", nil)
+}
+
+// ListAuditLogParams are the query parameters of ListAuditLog
+type ListAuditLogParams struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	From       string
+	To         string
+	Before     string
+	Limit      int
+}
+
+// ListAuditLog calls GET /v1/audit (200): list audit entries, newest first
+func (c *Client) ListAuditLog(ctx context.Context, params ListAuditLogParams) ([]AuditLog, error) {
+	query := url.Values{}
+	if params.Action != "" {
+		query.Set("action", params.Action)
+	}
+	if params.TargetType != "" {
+		query.Set("target_type", params.TargetType)
+	}
+	if params.TargetID != "" {
+		query.Set("target_id", params.TargetID)
+	}
+	if params.From != "" {
+		query.Set("from", params.From)
+	}
+	if params.To != "" {
+		query.Set("to", params.To)
+	}
+	if params.Before != "" {
+		query.Set("before", params.Before)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var out []AuditLog
+	err := c.do(ctx, "GET", "/v1/audit", query, nil, &out)
+	return out, err
+}
+
+// ListLoginAttempts calls GET /v1/auth/login-attempts (200): list recent login attempts
+func (c *Client) ListLoginAttempts(ctx context.Context) ([]LoginAttempt, error) {
+	var out []LoginAttempt
+	err := c.do(ctx, "GET", "/v1/auth/login-attempts", nil, nil, &out)
+	return out, err
+}
+
+// Logout calls POST /v1/auth/logout (200): end the current session
+func (c *Client) Logout(ctx context.Context, body RefreshRequest) error {
+	return c.do(ctx, "POST", "/v1/auth/logout", nil, body, nil)
+}
+
+// LogoutAll calls POST /v1/auth/logout-all (200): end every session
+func (c *Client) LogoutAll(ctx context.Context) (*RevokedSessions, error) {
+	var out RevokedSessions
+	if err := c.do(ctx, "POST", "/v1/auth/logout-all", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RefreshSession calls POST /v1/auth/refresh (200): exchange a refresh token for a new token pair
+func (c *Client) RefreshSession(ctx context.Context, body RefreshRequest) (*Tokens, error) {
+	var out Tokens
+	if err := c.do(ctx, "POST", "/v1/auth/refresh", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSessions calls GET /v1/auth/sessions (200): list active sessions
+func (c *Client) ListSessions(ctx context.Context) ([]Session, error) {
+	var out []Session
+	err := c.do(ctx, "GET", "/v1/auth/sessions", nil, nil, &out)
+	return out, err
+}
+
+// Consume calls POST /v1/consume/{userId} (200): parse and store a forwarded SMS
+func (c *Client) Consume(ctx context.Context, userID string, body ConsumeRequest) (*ConsumeResult, error) {
+	var out ConsumeResult
+	if err := c.do(ctx, "POST", "/v1/consume/"+pathEscape(userID), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListImports calls GET /v1/imports (200): list import jobs
+func (c *Client) ListImports(ctx context.Context) ([]ImportJob, error) {
+	var out []ImportJob
+	err := c.do(ctx, "GET", "/v1/imports", nil, nil, &out)
+	return out, err
+}
+
+// CreateImportForm are the form fields of CreateImport besides the file
+type CreateImportForm struct {
+	Format string
+	Origin string
+}
+
+// CreateImport calls POST /v1/imports (202): queue an SMS backup or CSV statement
+func (c *Client) CreateImport(ctx context.Context, file io.Reader, filename string, form CreateImportForm) (*ImportJob, error) {
+	var out ImportJob
+	if err := c.upload(ctx, "/v1/imports", file, filename, map[string]string{"format": form.Format, "origin": form.Origin}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetImport calls GET /v1/imports/{id} (200): import job with its failures
+func (c *Client) GetImport(ctx context.Context, id string) (*ImportReport, error) {
+	var out ImportReport
+	if err := c.do(ctx, "GET", "/v1/imports/"+pathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResumeImport calls POST /v1/imports/{id}/resume (202): resume an interrupted import
+func (c *Client) ResumeImport(ctx context.Context, id string) (*ImportJob, error) {
+	var out ImportJob
+	if err := c.do(ctx, "POST", "/v1/imports/"+pathEscape(id)+"/resume", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Login calls POST /v1/login (200): sign in, or get an MFA token when two-factor is enabled
+func (c *Client) Login(ctx context.Context, body Credentials) (*LoginResult, error) {
+	var out LoginResult
+	if err := c.do(ctx, "POST", "/v1/login", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LoginTwoFactor calls POST /v1/login/2fa (200): complete a login with a TOTP or recovery code
+func (c *Client) LoginTwoFactor(ctx context.Context, body TwoFactorLoginRequest) (*LoginResult, error) {
+	var out LoginResult
+	if err := c.do(ctx, "POST", "/v1/login/2fa", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ForgotPassword calls POST /v1/password/forgot (200): send a password reset link
+func (c *Client) ForgotPassword(ctx context.Context, body EmailRequest) (*Message, error) {
+	var out Message
+	if err := c.do(ctx, "POST", "/v1/password/forgot", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResetPassword calls POST /v1/password/reset (200): set a new password with a reset token
+func (c *Client) ResetPassword(ctx context.Context, body ResetPasswordRequest) error {
+	return c.do(ctx, "POST", "/v1/password/reset", nil, body, nil)
+}
+
+// Register calls POST /v1/register (200): create an account
+func (c *Client) Register(ctx context.Context, body Credentials) (*Registration, error) {
+	var out Registration
+	if err := c.do(ctx, "POST", "/v1/register", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImportStatementForm are the form fields of ImportStatement besides the file
+type ImportStatementForm struct {
+	Layout string
+	Origin string
+}
+
+// ImportStatement calls POST /v1/statements (200): reconcile a PDF e-statement
+func (c *Client) ImportStatement(ctx context.Context, file io.Reader, filename string, form ImportStatementForm) (*StatementReport, error) {
+	var out StatementReport
+	if err := c.upload(ctx, "/v1/statements", file, filename, map[string]string{"layout": form.Layout, "origin": form.Origin}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StreamEventsParams are the query parameters of StreamEvents
+type StreamEventsParams struct {
+	LastEventID string
+	AccessToken string
+}
+
+// StreamEvents calls GET /v1/stream (200): server-sent events for transactions, jobs and alerts
+func (c *Client) StreamEvents(ctx context.Context, params StreamEventsParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	if params.LastEventID != "" {
+		query.Set("last_event_id", params.LastEventID)
+	}
+	if params.AccessToken != "" {
+		query.Set("access_token", params.AccessToken)
+	}
+	return c.stream(ctx, "GET", "/v1/stream", query)
+}
+
+// GetTransactionsParams are the query parameters of GetTransactions
+type GetTransactionsParams struct {
+	Type         string
+	Date         string
+	Counterparty string
+}
+
+// GetTransactions calls GET /v1/transaction (200): transactions with dashboard statistics
+func (c *Client) GetTransactions(ctx context.Context, params GetTransactionsParams) (*TransactionAnalysis, error) {
+	query := url.Values{}
+	if params.Type != "" {
+		query.Set("type", params.Type)
+	}
+	if params.Date != "" {
+		query.Set("date", params.Date)
+	}
+	if params.Counterparty != "" {
+		query.Set("counterparty", params.Counterparty)
+	}
+	var out TransactionAnalysis
+	if err := c.do(ctx, "GET", "/v1/transaction", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateTransaction calls PATCH /v1/transaction/{id} (200): correct fields of a parsed transaction
+func (c *Client) UpdateTransaction(ctx context.Context, id string, body TransactionUpdate) (*Transaction, error) {
+	var out Transaction
+	if err := c.do(ctx, "PATCH", "/v1/transaction/"+pathEscape(id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportTransactionsParams are the query parameters of ExportTransactions
+type ExportTransactionsParams struct {
+	Format       string
+	Type         string
+	Date         string
+	Counterparty string
+}
+
+// ExportTransactions calls GET /v1/transactions/export (200): download transactions as CSV, OFX or QIF
+func (c *Client) ExportTransactions(ctx context.Context, params ExportTransactionsParams) (io.ReadCloser, error) {
+	query := url.Values{}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	if params.Type != "" {
+		query.Set("type", params.Type)
+	}
+	if params.Date != "" {
+		query.Set("date", params.Date)
+	}
+	if params.Counterparty != "" {
+		query.Set("counterparty", params.Counterparty)
+	}
+	return c.stream(ctx, "GET", "/v1/transactions/export", query)
+}
+
+// RotateAPIKey calls POST /v1/user/api-key/rotate (200): replace the API key
+func (c *Client) RotateAPIKey(ctx context.Context) (*APIKey, error) {
+	var out APIKey
+	if err := c.do(ctx, "POST", "/v1/user/api-key/rotate", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// VerifyEmail calls POST /v1/verify-email (200): confirm an email address
+func (c *Client) VerifyEmail(ctx context.Context, body TokenRequest) error {
+	return c.do(ctx, "POST", "/v1/verify-email", nil, body, nil)
+}
+
+// ResendVerification calls POST /v1/verify-email/resend (200): send the verification email again
+func (c *Client) ResendVerification(ctx context.Context) error {
+	return c.do(ctx, "POST", "/v1/verify-email/resend", nil, nil, nil)
+}
+
+// ListWebhooks calls GET /v1/webhooks (200): list webhooks and the supported events
+func (c *Client) ListWebhooks(ctx context.Context) (*WebhookList, error) {
+	var out WebhookList
+	if err := c.do(ctx, "GET", "/v1/webhooks", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateWebhook calls POST /v1/webhooks (200): register a webhook; the secret is only returned here
+func (c *Client) CreateWebhook(ctx context.Context, body WebhookRequest) (*Webhook, error) {
+	var out Webhook
+	if err := c.do(ctx, "POST", "/v1/webhooks", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Redeliver calls POST /v1/webhooks/deliveries/{id}/redeliver (200): send a delivery again
+func (c *Client) Redeliver(ctx context.Context, id string) (*WebhookDelivery, error) {
+	var out WebhookDelivery
+	if err := c.do(ctx, "POST", "/v1/webhooks/deliveries/"+pathEscape(id)+"/redeliver", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteWebhook calls DELETE /v1/webhooks/{id} (200): delete a webhook
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/v1/webhooks/"+pathEscape(id), nil, nil, nil)
+}
+
+// ListDeliveriesParams are the query parameters of ListDeliveries
+type ListDeliveriesParams struct {
+	Status string
+}
+
+// ListDeliveries calls GET /v1/webhooks/{id}/deliveries (200): list deliveries of a webhook
+func (c *Client) ListDeliveries(ctx context.Context, id string, params ListDeliveriesParams) ([]WebhookDelivery, error) {
+	query := url.Values{}
+	if params.Status != "" {
+		query.Set("status", params.Status)
+	}
+	var out []WebhookDelivery
+	err := c.do(ctx, "GET", "/v1/webhooks/"+pathEscape(id)+"/deliveries", query, nil, &out)
+	return out, err
+}
+
+// ListWorkspaces calls GET /v1/workspaces (200): list the workspaces the user belongs to
+func (c *Client) ListWorkspaces(ctx context.Context) ([]Workspace, error) {
+	var out []Workspace
+	err := c.do(ctx, "GET", "/v1/workspaces", nil, nil, &out)
+	return out, err
+}
+
+// CreateWorkspace calls POST /v1/workspaces (200): create a workspace
+func (c *Client) CreateWorkspace(ctx context.Context, body WorkspaceRequest) (*Workspace, error) {
+	var out Workspace
+	if err := c.do(ctx, "POST", "/v1/workspaces", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AcceptInvite calls POST /v1/workspaces/invites/accept (200): join a workspace with an invite token
+func (c *Client) AcceptInvite(ctx context.Context, body TokenRequest) (*InviteAcceptance, error) {
+	var out InviteAcceptance
+	if err := c.do(ctx, "POST", "/v1/workspaces/invites/accept", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWorkspace calls GET /v1/workspaces/{id} (200): get a workspace
+func (c *Client) GetWorkspace(ctx context.Context, id string) (*Workspace, error) {
+	var out Workspace
+	if err := c.do(ctx, "GET", "/v1/workspaces/"+pathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ShareAccounts calls PUT /v1/workspaces/{id}/accounts (200): choose which accounts the workspace sees
+func (c *Client) ShareAccounts(ctx context.Context, id string, body AccountsRequest) (*SharedAccounts, error) {
+	var out SharedAccounts
+	if err := c.do(ctx, "PUT", "/v1/workspaces/"+pathEscape(id)+"/accounts", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// InviteMember calls POST /v1/workspaces/{id}/invites (200): invite someone by email
+func (c *Client) InviteMember(ctx context.Context, id string, body InviteRequest) (*WorkspaceInvite, error) {
+	var out WorkspaceInvite
+	if err := c.do(ctx, "POST", "/v1/workspaces/"+pathEscape(id)+"/invites", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveMember calls DELETE /v1/workspaces/{id}/members/{userId} (200): remove a member, or leave the workspace
+func (c *Client) RemoveMember(ctx context.Context, id string, userID string) error {
+	return c.do(ctx, "DELETE", "/v1/workspaces/"+pathEscape(id)+"/members/"+pathEscape(userID), nil, nil, nil)
+}
+
+// UpdateMember calls PATCH /v1/workspaces/{id}/members/{userId} (200): change a member's role
+func (c *Client) UpdateMember(ctx context.Context, id string, userID string, body RoleRequest) error {
+	return c.do(ctx, "PATCH", "/v1/workspaces/"+pathEscape(id)+"/members/"+pathEscape(userID), nil, body, nil)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"mate/apierror"
+	"mate/client"
+	"mate/openapi"
+	"mate/routes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// appDoer sends client requests to the app in process
+type appDoer struct {
+	app *fiber.App
+}
+
+func (d appDoer) Do(req *http.Request) (*http.Response, error) {
+	return d.app.Test(req, -1)
+}
+
+func newTestClient() *client.Client {
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.Handler})
+	routes.Register(app)
+
+	c := client.New("http://mate.test")
+	c.HTTPClient = appDoer{app: app}
+	return c
+}
+
+// TestSpecMatchesClient fails when client_gen.go was not regenerated after a route or
+// payload changed
+func TestSpecMatchesClient(t *testing.T) {
+	c := newTestClient()
+
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/openapi.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", resp.StatusCode)
+	}
+
+	var doc openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("decoding /openapi.json: %v", err)
+	}
+	want, err := generate(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("client_gen.go does not match /openapi.json, run go generate ./client")
+	}
+}
+
+// TestClientErrors checks that the generated methods reach their routes and decode the
+// error envelope
+func TestClientErrors(t *testing.T) {
+	c := newTestClient()
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		call   func() error
+		status int
+		code   string
+	}{
+		{"authenticated route without a token", func() error {
+			_, err := c.ListWebhooks(ctx)
+			return err
+		}, http.StatusUnauthorized, string(apierror.CodeAuthRequired)},
+		{"login without credentials", func() error {
+			_, err := c.Login(ctx, client.Credentials{})
+			return err
+		}, http.StatusBadRequest, string(apierror.CodeInvalidInput)},
+		{"registration with an invalid email", func() error {
+			_, err := c.Register(ctx, client.Credentials{Email: "not-an-email", Password: "correct horse battery staple"})
+			return err
+		}, http.StatusBadRequest, string(apierror.CodeInvalidInput)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr *client.Error
+			if err := tt.call(); !errors.As(err, &apiErr) {
+				t.Fatalf("got error %v, want a *client.Error", err)
+			}
+			if apiErr.Status != tt.status || apiErr.Code != tt.code {
+				t.Fatalf("got %d %s, want %d %s", apiErr.Status, apiErr.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Command clientgen writes the types and methods of package client from the
+// OpenAPI document the server serves at /openapi.json.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"mate/openapi"
+	"mate/routes"
+)
+
+const componentsPrefix = "#/components/schemas/"
+
+// initialisms are written in upper case in Go identifiers
+var initialisms = map[string]bool{
+	"id": true, "url": true, "uri": true, "api": true, "ip": true, "mfa": true, "totp": true, "sms": true, "json": true,
+}
+
+func main() {
+	output := flag.String("o", "client_gen.go", "file to write")
+	flag.Parse()
+
+	source, err := generate(routes.Spec())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type writer struct {
+	bytes.Buffer
+	usesTime bool
+	usesIO   bool
+	usesURL  bool
+	usesConv bool
+}
+
+func (w *writer) line(format string, args ...any) {
+	fmt.Fprintf(w, format+"\n", args...)
+}
+
+func generate(doc *openapi.Document) ([]byte, error) {
+	w := &writer{}
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.writeStruct(name, doc.Components.Schemas[name])
+	}
+
+	for _, op := range doc.Operations() {
+		if err := w.writeOperation(op); err != nil {
+			return nil, err
+		}
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by clientgen from the OpenAPI document (%s %s). DO NOT EDIT.\n\n", doc.Info.Title, doc.Info.Version)
+	file.WriteString("package client\n\nimport (\n\t\"context\"\n")
+	if w.usesIO {
+		file.WriteString("\t\"io\"\n")
+	}
+	if w.usesURL {
+		file.WriteString("\t\"net/url\"\n")
+	}
+	if w.usesConv {
+		file.WriteString("\t\"strconv\"\n")
+	}
+	if w.usesTime {
+		file.WriteString("\t\"time\"\n")
+	}
+	file.WriteString(")\n\n")
+	file.Write(w.Bytes())
+
+	return format.Source(file.Bytes())
+}
+
+func (w *writer) writeStruct(name string, schema *openapi.Schema) {
+	required := map[string]bool{}
+	for _, field := range schema.Required {
+		required[field] = true
+	}
+
+	w.line("type %s struct {", name)
+	for _, field := range sortedKeys(schema.Properties) {
+		tag := field
+		if !required[field] {
+			tag += ",omitempty"
+		}
+		w.line("\t%s %s `json:%q`", goName(field), w.goType(schema.Properties[field]), tag)
+	}
+	w.line("}\n")
+}
+
+func (w *writer) goType(schema *openapi.Schema) string {
+	if schema.Ref != "" {
+		return strings.TrimPrefix(schema.Ref, componentsPrefix)
+	}
+
+	var t string
+	switch schema.Type {
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			w.usesTime = true
+			t = "time.Time"
+		case "byte", "binary":
+			return "[]byte"
+		default:
+			t = "string"
+		}
+	case "integer":
+		t = "int"
+		if schema.Format == "int64" {
+			t = "int64"
+		}
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + w.goType(schema.Items)
+	case "object":
+		if schema.AdditionalProperties != nil {
+			return "map[string]" + w.goType(schema.AdditionalProperties)
+		}
+		return "map[string]any"
+	default:
+		return "any"
+	}
+
+	if schema.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func (w *writer) writeOperation(ref openapi.OperationRef) error {
+	op := ref.Operation
+	name := goName(op.OperationID)
+
+	var (
+		args      = []string{"ctx context.Context"}
+		pathExpr  = fmt.Sprintf("%q", ref.Path)
+		queryArgs []*openapi.Parameter
+	)
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			arg := lowerFirst(goName(param.Name))
+			args = append(args, arg+" string")
+			pathExpr = strings.Replace(pathExpr, "{"+param.Name+"}", `"+pathEscape(`+arg+`)+"`, 1)
+		case "query":
+			queryArgs = append(queryArgs, param)
+		}
+	}
+	pathExpr = strings.TrimSuffix(pathExpr, `+""`)
+
+	query := "nil"
+	if len(queryArgs) > 0 {
+		w.usesURL = true
+		paramsType := name + "Params"
+		w.line("// %s are the query parameters of %s", paramsType, name)
+		w.line("type %s struct {", paramsType)
+		for _, param := range queryArgs {
+			w.line("\t%s %s", goName(param.Name), w.goType(param.Schema))
+		}
+		w.line("}\n")
+		args = append(args, "params "+paramsType)
+		query = "query"
+	}
+
+	var body string
+	var form []string
+	if op.RequestBody != nil {
+		if media, ok := op.RequestBody.Content["multipart/form-data"]; ok {
+			w.usesIO = true
+			args = append(args, "file io.Reader", "filename string")
+			for _, field := range sortedKeys(media.Schema.Properties) {
+				if field != "file" {
+					form = append(form, field)
+				}
+			}
+			if len(form) > 0 {
+				formType := name + "Form"
+				w.line("// %s are the form fields of %s besides the file", formType, name)
+				w.line("type %s struct {", formType)
+				for _, field := range form {
+					w.line("\t%s string", goName(field))
+				}
+				w.line("}\n")
+				args = append(args, "form "+formType)
+			}
+		} else {
+			body = "body"
+			args = append(args, "body "+w.goType(op.RequestBody.Content["application/json"].Schema))
+		}
+	}
+
+	success, status := successResponse(op)
+	if success == nil {
+		return fmt.Errorf("%s has no success response", op.OperationID)
+	}
+
+	var result string
+	stream := false
+	if media, ok := success.Content["application/json"]; ok {
+		if data := media.Schema.Properties["data"]; data != nil {
+			result = w.goType(data)
+		}
+	} else {
+		stream = true
+		w.usesIO = true
+	}
+
+	w.line("// %s calls %s %s (%s): %s", name, ref.Method, ref.Path, status, strings.ToLower(op.Summary[:1])+op.Summary[1:])
+	switch {
+	case stream:
+		w.line("func (c *Client) %s(%s) (io.ReadCloser, error) {", name, strings.Join(args, ", "))
+	case result == "":
+		w.line("func (c *Client) %s(%s) error {", name, strings.Join(args, ", "))
+	case strings.HasPrefix(result, "[]"):
+		w.line("func (c *Client) %s(%s) (%s, error) {", name, strings.Join(args, ", "), result)
+	default:
+		w.line("func (c *Client) %s(%s) (*%s, error) {", name, strings.Join(args, ", "), result)
+	}
+
+	if len(queryArgs) > 0 {
+		w.line("\tquery := url.Values{}")
+		for _, param := range queryArgs {
+			field := "params." + goName(param.Name)
+			if param.Schema.Type == "integer" {
+				w.usesConv = true
+				w.line("\tif %s != 0 {\n\t\tquery.Set(%q, strconv.Itoa(%s))\n\t}", field, param.Name, field)
+			} else {
+				w.line("\tif %s != \"\" {\n\t\tquery.Set(%q, %s)\n\t}", field, param.Name, field)
+			}
+		}
+	}
+
+	switch {
+	case stream:
+		w.line("\treturn c.stream(ctx, %q, %s, %s)", ref.Method, pathExpr, query)
+	case form != nil || (op.RequestBody != nil && body == ""):
+		fields := "nil"
+		if len(form) > 0 {
+			parts := make([]string, len(form))
+			for i, field := range form {
+				parts[i] = fmt.Sprintf("%q: form.%s", field, goName(field))
+			}
+			fields = "map[string]string{" + strings.Join(parts, ", ") + "}"
+		}
+		w.writeCall(result, fmt.Sprintf("c.upload(ctx, %s, file, filename, %s, %%s)", pathExpr, fields))
+	default:
+		if body == "" {
+			body = "nil"
+		}
+		w.writeCall(result, fmt.Sprintf("c.do(ctx, %q, %s, %s, %s, %%s)", ref.Method, pathExpr, query, body))
+	}
+	w.line("}\n")
+	return nil
+}
+
+// writeCall returns the result of call, which takes the decode target as its last argument
+func (w *writer) writeCall(result, call string) {
+	switch {
+	case result == "":
+		w.line("\treturn "+call, "nil")
+	case strings.HasPrefix(result, "[]"):
+		w.line("\tvar out %s", result)
+		w.line("\terr := "+call, "&out")
+		w.line("\treturn out, err")
+	default:
+		w.line("\tvar out %s", result)
+		w.line("\tif err := "+call+"; err != nil {\n\t\treturn nil, err\n\t}", "&out")
+		w.line("\treturn &out, nil")
+	}
+}
+
+func successResponse(op *openapi.Operation) (*openapi.Response, string) {
+	for _, status := range sortedKeys(op.Responses) {
+		if strings.HasPrefix(status, "2") {
+			return op.Responses[status], status
+		}
+	}
+	return nil, ""
+}
+
+// goName turns snake_case and camelCase names into exported Go identifiers
+func goName(name string) string {
+	var words []string
+	start := 0
+	for i, r := range name {
+		switch {
+		case r == '_' || r == '-':
+			words = append(words, name[start:i])
+			start = i + 1
+		case unicode.IsUpper(r) && i > start && !unicode.IsUpper(rune(name[i-1])):
+			words = append(words, name[start:i])
+			start = i
+		}
+	}
+	words = append(words, name[start:])
+
+	var b strings.Builder
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+func lowerFirst(name string) string {
+	for word := range initialisms {
+		if strings.HasPrefix(name, strings.ToUpper(word)) && len(name) == len(word) {
+			return word
+		}
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,20 +1,256 @@
 package config
 
 import (
-	"os"
-
-	"github.com/joho/godotenv"
+	"strconv"
+	"strings"
+	"time"
 )
 
-var (
-	MongoURI    string
-	MongoDBName string
-)
+// Config is the typed application configuration. Every setting has a key in the
+// config file (section.key) and an environment variable; see Load for precedence.
+type Config struct {
+	Server      ServerConfig      `config:"server"`
+	Mongo       MongoConfig       `config:"mongo"`
+	Session     SessionConfig     `config:"session"`
+	Mail        MailConfig        `config:"mail"`
+	Auth        AuthConfig        `config:"auth"`
+	RateLimit   RateLimitConfig   `config:"rate_limit"`
+	Idempotency IdempotencyConfig `config:"idempotency"`
+	Audit       AuditConfig       `config:"audit"`
+	Privacy     PrivacyConfig     `config:"privacy"`
+	Encryption  EncryptionConfig  `config:"encryption"`
+	Log         LogConfig         `config:"log"`
+	Metrics     MetricsConfig     `config:"metrics"`
+	Tracing     TracingConfig     `config:"tracing"`
+	LLM         LLMConfig         `config:"llm"`
+}
+
+type ServerConfig struct {
+	// Env selects the environment specific .env and config files and stricter checks in production
+	Env      string `config:"env" env:"APP_ENV" default:"development"`
+	Port     int    `config:"port" env:"PORT" default:"3001"`
+	TimeZone string `config:"time_zone" env:"TIME_ZONE" default:"Africa/Accra"`
+	// AppURL is the frontend base URL used in emailed links
+	AppURL string `config:"app_url" env:"APP_URL"`
+	// BodyLimitMB caps request bodies; SMS backups and statements can be several megabytes
+	BodyLimitMB int `config:"body_limit_mb" env:"BODY_LIMIT_MB" default:"32"`
+	// ShutdownTimeout bounds how long in-flight requests and background work get to finish
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// MaxQueueDepth is the number of pending import items and webhook deliveries above
+	// which the instance reports not ready, zero disables the check
+	MaxQueueDepth int `config:"max_queue_depth" env:"MAX_QUEUE_DEPTH" default:"1000"`
+	// ProxyHeader names the header a reverse proxy puts the client address in, such as
+	// X-Real-IP. It is only read on requests from TrustedProxies (IPs or CIDR ranges).
+	ProxyHeader    string   `config:"proxy_header" env:"PROXY_HEADER"`
+	TrustedProxies []string `config:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	Location *time.Location `config:"-"`
+}
+
+type MongoConfig struct {
+	URI    string `config:"uri" env:"MONGO_URI" secret:"true"`
+	DBName string `config:"db_name" env:"MONGO_DB_NAME"`
+}
+
+type SessionConfig struct {
+	// SigningKeys is "kid:secret,kid:secret"; the first key signs new tokens
+	SigningKeys     string        `config:"signing_keys" env:"SESSION_SIGNING_KEYS" secret:"true"`
+	AccessTokenTTL  time.Duration `config:"access_token_ttl" env:"ACCESS_TOKEN_TTL" default:"15m"`
+	RefreshTokenTTL time.Duration `config:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL" default:"720h"`
+
+	Keys      map[string]string `config:"-"`
+	ActiveKey string            `config:"-"`
+}
+
+type MailConfig struct {
+	// Driver is smtp, log or memory
+	Driver       string `config:"driver" env:"MAIL_DRIVER" default:"log"`
+	From         string `config:"from" env:"MAIL_FROM"`
+	SMTPHost     string `config:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     string `config:"smtp_port" env:"SMTP_PORT" default:"587"`
+	SMTPUsername string `config:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `config:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+type AuthConfig struct {
+	// LoginThrottleStore is "mongo" (shared across instances) or "memory"
+	LoginThrottleStore string `config:"login_throttle_store" env:"LOGIN_THROTTLE_STORE" default:"mongo"`
+}
+
+type RateLimitConfig struct {
+	// Store is "mongo" (shared across instances) or "memory"
+	Store string `config:"store" env:"RATE_LIMIT_STORE" default:"mongo"`
+	// Rules is "route:by=limit/window,...", where route is an operation ID from the
+	// OpenAPI document or * for every route together, and by is user or ip
+	Rules string `config:"rules" env:"RATE_LIMITS" default:"*:ip=600/1m,*:user=300/1m,consume:user=30/1m,consume:ip=120/1m,register:ip=10/1h"`
+
+	Parsed []RateRule `config:"-"`
+}
+
+// RateRule allows Limit requests per Window to Route for each user or IP
+type RateRule struct {
+	Route  string
+	By     string
+	Limit  int
+	Window time.Duration
+}
+
+type IdempotencyConfig struct {
+	// Store is "mongo" (shared across instances) or "memory"
+	Store string `config:"store" env:"IDEMPOTENCY_STORE" default:"mongo"`
+	// TTL is how long a key and its stored response are kept for replay
+	TTL time.Duration `config:"ttl" env:"IDEMPOTENCY_TTL" default:"24h"`
+}
+
+type AuditConfig struct {
+	// Retention is how long audit log entries are kept, zero keeps them forever
+	Retention time.Duration `config:"retention" env:"AUDIT_RETENTION" default:"8760h"`
+}
 
-// Load environment variables
-func InitConfig() {
-	_ = godotenv.Overload() // Ignore error if .env is missing
+type PrivacyConfig struct {
+	// DataExportDir holds generated account export archives
+	DataExportDir string `config:"data_export_dir" env:"DATA_EXPORT_DIR" default:"exports"`
+	// AccountDeletionGrace is how long a deletion request can be cancelled before data is purged
+	AccountDeletionGrace time.Duration `config:"account_deletion_grace" env:"ACCOUNT_DELETION_GRACE" default:"720h"`
+}
+
+type EncryptionConfig struct {
+	// MasterKeys is "kid:base64key,..." and wraps the per-user data keys; the first wraps new ones
+	MasterKeys string `config:"master_keys" env:"ENCRYPTION_MASTER_KEYS" secret:"true"`
+	// BlindIndexKey keys the searchable field hashes and must never change
+	BlindIndexKey string `config:"blind_index_key" env:"BLIND_INDEX_KEY" secret:"true"`
+
+	Keys      map[string]string `config:"-"`
+	ActiveKey string            `config:"-"`
+}
+
+type LogConfig struct {
+	// Sinks lists where logs go: stdout, file and/or betterstack
+	Sinks               []string `config:"sinks" env:"LOG_SINKS" default:"stdout"`
+	Level               string   `config:"level" env:"LOG_LEVEL" default:"info"`
+	Format              string   `config:"format" env:"LOG_FORMAT" default:"json"`
+	File                string   `config:"file" env:"LOG_FILE" default:"mate.log"`
+	BetterStackToken    string   `config:"betterstack_token" env:"BETTERSTACK_TOKEN" secret:"true"`
+	BetterStackEndpoint string   `config:"betterstack_endpoint" env:"BETTERSTACK_ENDPOINT"`
+	// RedactKeys are extra attribute names whose values are never logged
+	RedactKeys []string `config:"redact_keys" env:"LOG_REDACT_KEYS"`
+}
+
+type MetricsConfig struct {
+	// Addr is the admin listener serving /metrics, kept off the public port; empty disables it
+	Addr string `config:"addr" env:"METRICS_ADDR" default:"127.0.0.1:9091"`
+	// Token, when set, must be sent as a bearer token by the scraper
+	Token string `config:"token" env:"METRICS_TOKEN" secret:"true"`
+}
+
+type TracingConfig struct {
+	// Exporter is "none" (spans are only used to correlate logs) or "otlp"
+	Exporter    string `config:"exporter" env:"TRACING_EXPORTER" default:"none"`
+	ServiceName string `config:"service_name" env:"OTEL_SERVICE_NAME" default:"mate"`
+	// OTLPEndpoint is the OTLP/HTTP collector URL, e.g. http://localhost:4318
+	OTLPEndpoint string `config:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" default:"http://localhost:4318"`
+	// OTLPHeaders is "key=value,..." sent with every export, e.g. an API key for a hosted backend
+	OTLPHeaders string `config:"otlp_headers" env:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true"`
+	// SampleRatio is the fraction of new traces recorded; traces started upstream follow the caller
+	SampleRatio float64 `config:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1"`
+}
+
+type LLMConfig struct {
+	HuggingFaceAPIKey string `config:"hugging_face_api_key" env:"HUGGING_FACE_API" secret:"true"`
+	// DailyQuota caps the parser model calls per user and day, zero removes the cap
+	DailyQuota int `config:"daily_quota" env:"LLM_DAILY_QUOTA" default:"500"`
+}
+
+// Current is the configuration loaded at startup
+var Current = &Config{}
+
+// InitConfig loads the configuration for the process, see Load
+func InitConfig() error {
+	cfg, _, err := Load()
+	if err != nil {
+		return err
+	}
+	Current = cfg
+	return nil
+}
+
+// IsProduction reports whether the stricter production checks apply
+func (c *Config) IsProduction() bool {
+	return c.Server.Env == "production"
+}
+
+// parseKeyList reads "kid:secret,kid:secret". The first key is the active one and the
+// rest are only kept for reading, so keys are rotated by prepending a new one and later
+// dropping the old. Session signing keys and encryption master keys both use it.
+func parseKeyList(value string) (map[string]string, string, error) {
+	keys := map[string]string{}
+	active := ""
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(entry, ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, "", errInvalidKeyEntry
+		}
+		if _, exists := keys[kid]; exists {
+			return nil, "", errDuplicateKeyID
+		}
+		keys[kid] = secret
+		if active == "" {
+			active = kid
+		}
+	}
+	return keys, active, nil
+}
+
+// parseRateRules reads "route:by=limit/window,...", e.g. "consume:user=30/1m"
+func parseRateRules(value string) ([]RateRule, error) {
+	var rules []RateRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, rate, ok := strings.Cut(entry, "=")
+		route, by, hasBy := strings.Cut(target, ":")
+		limit, window, hasWindow := strings.Cut(rate, "/")
+		if !ok || !hasBy || !hasWindow || route == "" || (by != "user" && by != "ip") {
+			return nil, errInvalidRateRule
+		}
+		rule := RateRule{Route: route, By: by}
+		var err error
+		if rule.Limit, err = strconv.Atoi(limit); err != nil || rule.Limit < 1 {
+			return nil, errInvalidRate
+		}
+		if rule.Window, err = time.ParseDuration(window); err != nil || rule.Window < time.Second {
+			return nil, errInvalidRate
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// OTLPHeaderMap returns the configured OTLP export headers
+func (c *TracingConfig) OTLPHeaderMap() map[string]string {
+	headers, _ := parseHeaders(c.OTLPHeaders)
+	return headers
+}
 
-	MongoURI = os.Getenv("MONGO_URI")
-	MongoDBName = os.Getenv("MONGO_DB_NAME")
+// parseHeaders reads "key=value,key=value"
+func parseHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, errInvalidHeader
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return headers, nil
 }
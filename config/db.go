@@ -2,89 +2,173 @@ package config
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
+	"os"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var Client *mongo.Client
 var Database *mongo.Database
 
+var tracer = otel.Tracer("mate/config")
+
 // Connect to MongoDB
 func ConnectToDB() {
-	clientOptions := options.Client().ApplyURI(MongoURI)
+	clientOptions := options.Client().ApplyURI(Current.Mongo.URI)
 	var err error
 	Client, err = mongo.Connect(context.Background(), clientOptions)
 	if err != nil {
-		log.Fatal("Failed to connect to MongoDB:", err)
+		slog.Error("failed to connect to MongoDB", "error", err)
+		os.Exit(1)
 	}
 
-	Database = Client.Database(MongoDBName)
-	fmt.Println("Successfully connected to MongoDB")
+	Database = Client.Database(Current.Mongo.DBName)
+	slog.Info("connected to MongoDB", "database", Current.Mongo.DBName)
+}
+
+// Ping checks that MongoDB is reachable
+func Ping(ctx context.Context) error {
+	return Client.Ping(ctx, nil)
+}
+
+// Disconnect closes the MongoDB connections
+func Disconnect(ctx context.Context) error {
+	return Client.Disconnect(ctx)
 }
 
 // InsertOne - Insert a single document into the collection
-func InsertOne(collectionName string, document interface{}) error {
+func InsertOne(ctx context.Context, collectionName string, document interface{}) error {
+	ctx, span := startSpan(ctx, "InsertOne", collectionName)
 	collection := Database.Collection(collectionName)
-	_, err := collection.InsertOne(context.Background(), document)
+	_, err := collection.InsertOne(ctx, document)
+	endSpan(span, err)
+	return err
+}
+
+// InsertMany - Insert multiple documents into the collection
+func InsertMany(ctx context.Context, collectionName string, documents []interface{}) error {
+	ctx, span := startSpan(ctx, "InsertMany", collectionName)
+	collection := Database.Collection(collectionName)
+	_, err := collection.InsertMany(ctx, documents)
+	endSpan(span, err)
 	return err
 }
 
 // FindOne - Find a single document in the collection
-func FindOne(collectionName string, filter bson.M) (*mongo.SingleResult, error) {
+func FindOne(ctx context.Context, collectionName string, filter bson.M) (*mongo.SingleResult, error) {
+	ctx, span := startSpan(ctx, "FindOne", collectionName)
 	collection := Database.Collection(collectionName)
-	result := collection.FindOne(context.Background(), filter)
-	return result, result.Err()
+	result := collection.FindOne(ctx, filter)
+	err := result.Err()
+	endSpan(span, err)
+	return result, err
 }
 
 // Find - Find multiple documents in the collection
-func Find(collectionName string, filter bson.M, options *options.FindOptions) (*mongo.Cursor, error) {
+func Find(ctx context.Context, collectionName string, filter bson.M, options *options.FindOptions) (*mongo.Cursor, error) {
+	ctx, span := startSpan(ctx, "Find", collectionName)
 	collection := Database.Collection(collectionName)
-	cursor, err := collection.Find(context.Background(), filter, options)
+	cursor, err := collection.Find(ctx, filter, options)
+	endSpan(span, err)
 	return cursor, err
 }
 
 // UpdateOne - Update a single document in the collection
-func UpdateOne(collectionName string, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+func UpdateOne(ctx context.Context, collectionName string, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+	ctx, span := startSpan(ctx, "UpdateOne", collectionName)
+	collection := Database.Collection(collectionName)
+	result, err := collection.UpdateOne(ctx, filter, update)
+	endSpan(span, err)
+	return result, err
+}
+
+// UpsertOne - Update a single document, inserting it if no document matches the filter
+func UpsertOne(ctx context.Context, collectionName string, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+	ctx, span := startSpan(ctx, "UpsertOne", collectionName)
 	collection := Database.Collection(collectionName)
-	result, err := collection.UpdateOne(context.Background(), filter, update)
+	result, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	endSpan(span, err)
 	return result, err
 }
 
+// FindOneAndUpsert - Update a single document, inserting it if no document matches the filter, and return it as updated
+func FindOneAndUpsert(ctx context.Context, collectionName string, filter bson.M, update interface{}) *mongo.SingleResult {
+	ctx, span := startSpan(ctx, "FindOneAndUpsert", collectionName)
+	collection := Database.Collection(collectionName)
+	result := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	endSpan(span, result.Err())
+	return result
+}
+
 // UpdateMany - Update multiple documents in the collection
-func UpdateMany(collectionName string, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+func UpdateMany(ctx context.Context, collectionName string, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+	ctx, span := startSpan(ctx, "UpdateMany", collectionName)
 	collection := Database.Collection(collectionName)
-	result, err := collection.UpdateMany(context.Background(), filter, update)
+	result, err := collection.UpdateMany(ctx, filter, update)
+	endSpan(span, err)
 	return result, err
 }
 
 // DeleteOne - Delete a single document in the collection
-func DeleteOne(collectionName string, filter bson.M) (*mongo.DeleteResult, error) {
+func DeleteOne(ctx context.Context, collectionName string, filter bson.M) (*mongo.DeleteResult, error) {
+	ctx, span := startSpan(ctx, "DeleteOne", collectionName)
 	collection := Database.Collection(collectionName)
-	result, err := collection.DeleteOne(context.Background(), filter)
+	result, err := collection.DeleteOne(ctx, filter)
+	endSpan(span, err)
 	return result, err
 }
 
 // DeleteMany - Delete multiple documents in the collection
-func DeleteMany(collectionName string, filter bson.M) (*mongo.DeleteResult, error) {
+func DeleteMany(ctx context.Context, collectionName string, filter bson.M) (*mongo.DeleteResult, error) {
+	ctx, span := startSpan(ctx, "DeleteMany", collectionName)
 	collection := Database.Collection(collectionName)
-	result, err := collection.DeleteMany(context.Background(), filter)
+	result, err := collection.DeleteMany(ctx, filter)
+	endSpan(span, err)
 	return result, err
 }
 
 // CountDocuments - Count the number of documents matching the filter
-func CountDocuments(collectionName string, filter bson.M) (int64, error) {
+func CountDocuments(ctx context.Context, collectionName string, filter bson.M) (int64, error) {
+	ctx, span := startSpan(ctx, "CountDocuments", collectionName)
 	collection := Database.Collection(collectionName)
-	count, err := collection.CountDocuments(context.Background(), filter)
+	count, err := collection.CountDocuments(ctx, filter)
+	endSpan(span, err)
 	return count, err
 }
 
 // Aggregate - Perform aggregation operations
-func Aggregate(collectionName string, pipeline interface{}, options *options.AggregateOptions) (*mongo.Cursor, error) {
+func Aggregate(ctx context.Context, collectionName string, pipeline interface{}, options *options.AggregateOptions) (*mongo.Cursor, error) {
+	ctx, span := startSpan(ctx, "Aggregate", collectionName)
 	collection := Database.Collection(collectionName)
-	cursor, err := collection.Aggregate(context.Background(), pipeline, options)
+	cursor, err := collection.Aggregate(ctx, pipeline, options)
+	endSpan(span, err)
 	return cursor, err
 }
+
+// startSpan traces one database call; its context is handed to the driver so the
+// call is cancelled with the request
+func startSpan(ctx context.Context, operation, collectionName string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "mongodb."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "mongodb"),
+		attribute.String("db.name", Current.Mongo.DBName),
+		attribute.String("db.mongodb.collection", collectionName),
+		attribute.String("db.operation", operation),
+	))
+}
+
+// endSpan finishes a database span; not finding a document is not an error
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	errInvalidKeyEntry = errors.New(`entries must look like "kid:secret"`)
+	errDuplicateKeyID  = errors.New("key ids must be unique")
+	errInvalidHeader   = errors.New(`entries must look like "key=value"`)
+	errInvalidRateRule = errors.New(`entries must look like "route:user=limit/window" or "route:ip=limit/window"`)
+	errInvalidRate     = errors.New("limits must be positive and windows at least 1s")
+)
+
+// Sources records where each setting came from, by file key (e.g. "mongo.uri")
+type Sources map[string]string
+
+// field is one leaf setting of Config
+type field struct {
+	key    string
+	env    string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields lists the settings of cfg in declaration order
+func fields(cfg *Config) []field {
+	var list []field
+	root := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i).Tag.Get("config")
+		sectionValue := root.Field(i)
+		for j := 0; j < sectionValue.NumField(); j++ {
+			structField := sectionValue.Type().Field(j)
+			name := structField.Tag.Get("config")
+			if name == "" || name == "-" {
+				continue
+			}
+			list = append(list, field{
+				key:    section + "." + name,
+				env:    structField.Tag.Get("env"),
+				def:    structField.Tag.Get("default"),
+				secret: structField.Tag.Get("secret") == "true",
+				value:  sectionValue.Field(j),
+			})
+		}
+	}
+	return list
+}
+
+// layer is one source of raw values, lookups are by file key or environment name
+type layer struct {
+	name   string
+	lookup func(f field) (string, string, bool, error)
+}
+
+// Load builds the configuration. Each setting takes the first value found in:
+//
+//  1. the process environment (NAME, or NAME_FILE holding the path to a secret)
+//  2. .env.<APP_ENV>, then .env
+//  3. the config file: CONFIG_FILE, or the first of mate.<APP_ENV>.{yaml,yml,toml}
+//     and mate.{yaml,yml,toml} that exists (section.key, or section.key_file)
+//  4. the default
+//
+// Every problem is reported at once so a broken deployment can be fixed in one go.
+func Load() (*Config, Sources, error) {
+	var problems []string
+
+	// APP_ENV decides which files to read, so it is looked up first
+	baseDotenv, err := readDotenv(".env")
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	env := os.Getenv("APP_ENV")
+	if env == "" {
+		env = baseDotenv["APP_ENV"]
+	}
+	if env == "" {
+		env = "development"
+	}
+	envDotenv, err := readDotenv(".env." + env)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile == "" {
+		configFile = envDotenv["CONFIG_FILE"]
+	}
+	if configFile == "" {
+		configFile = baseDotenv["CONFIG_FILE"]
+	}
+	if configFile == "" {
+		configFile = findConfigFile(env)
+	}
+	fileValues := map[string]string{}
+	if configFile != "" {
+		if fileValues, err = readConfigFile(configFile); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	layers := []layer{
+		{name: "env", lookup: envLookup(os.LookupEnv)},
+		{name: ".env." + env, lookup: envLookup(mapLookup(envDotenv))},
+		{name: ".env", lookup: envLookup(mapLookup(baseDotenv))},
+		{name: "file " + configFile, lookup: fileLookup(fileValues)},
+	}
+
+	cfg := &Config{}
+	sources := Sources{}
+	known := map[string]bool{}
+	for _, f := range fields(cfg) {
+		known[f.key] = true
+		known[f.key+"_file"] = f.secret
+
+		raw, source := f.def, "default"
+		for _, l := range layers {
+			value, origin, ok, err := l.lookup(f)
+			if err != nil {
+				problems = append(problems, err.Error())
+				break
+			}
+			if ok {
+				raw, source = value, l.name+origin
+				break
+			}
+		}
+		sources[f.key] = source
+
+		if err := setField(f.value, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (%s): %v", f.key, f.env, err))
+		}
+	}
+	for key := range fileValues {
+		if !known[key] {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting %q", configFile, key))
+		}
+	}
+	// the environment the files were chosen for wins over an APP_ENV inside them
+	cfg.Server.Env = env
+
+	if len(problems) == 0 {
+		problems = cfg.finish()
+	}
+	if len(problems) > 0 {
+		return nil, sources, &Error{Problems: problems}
+	}
+	return cfg, sources, nil
+}
+
+// Error lists every configuration problem found at startup
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// envLookup reads NAME or NAME_FILE from an environment-like source
+func envLookup(get func(string) (string, bool)) func(f field) (string, string, bool, error) {
+	return func(f field) (string, string, bool, error) {
+		if f.env == "" {
+			return "", "", false, nil
+		}
+		value, hasValue := get(f.env)
+		path, hasFile := get(f.env + "_FILE")
+		switch {
+		case hasValue && hasFile:
+			return "", "", false, fmt.Errorf("%s and %s_FILE are both set", f.env, f.env)
+		case hasFile:
+			secret, err := readSecretFile(path)
+			if err != nil {
+				return "", "", false, fmt.Errorf("%s_FILE: %v", f.env, err)
+			}
+			return secret, " (" + f.env + "_FILE)", true, nil
+		case hasValue:
+			return value, "", true, nil
+		}
+		return "", "", false, nil
+	}
+}
+
+// fileLookup reads section.key or section.key_file from a config file
+func fileLookup(values map[string]string) func(f field) (string, string, bool, error) {
+	return func(f field) (string, string, bool, error) {
+		value, hasValue := values[f.key]
+		path, hasFile := values[f.key+"_file"]
+		if hasFile && !f.secret {
+			hasFile = false
+		}
+		switch {
+		case hasValue && hasFile:
+			return "", "", false, fmt.Errorf("%s and %s_file are both set", f.key, f.key)
+		case hasFile:
+			secret, err := readSecretFile(path)
+			if err != nil {
+				return "", "", false, fmt.Errorf("%s_file: %v", f.key, err)
+			}
+			return secret, " (" + f.key + "_file)", true, nil
+		case hasValue:
+			return value, "", true, nil
+		}
+		return "", "", false, nil
+	}
+}
+
+func mapLookup(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+// readSecretFile reads a secret mounted as a file, e.g. a Docker or Kubernetes secret
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readDotenv(path string) (map[string]string, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return map[string]string{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return values, nil
+}
+
+func findConfigFile(env string) string {
+	for _, name := range []string{"mate." + env, "mate"} {
+		for _, ext := range []string{".yaml", ".yml", ".toml"} {
+			if _, err := os.Stat(name + ext); err == nil {
+				return name + ext
+			}
+		}
+	}
+	return ""
+}
+
+// readConfigFile flattens a YAML or TOML file of sections into "section.key" values
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	document := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("%s: config files must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	values := map[string]string{}
+	for section, content := range document {
+		settings, ok := content.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %q must be a section of settings", path, section)
+		}
+		for key, value := range settings {
+			flat, err := flatten(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s.%s: %v", path, section, key, err)
+			}
+			values[section+"."+key] = flat
+		}
+	}
+	return values, nil
+}
+
+// flatten turns a file value into the string form used by environment variables
+func flatten(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			flat, err := flatten(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, flat)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+func setField(value reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch value.Interface().(type) {
+	case string:
+		value.SetString(raw)
+	case int:
+		if raw == "" {
+			value.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		value.SetInt(int64(n))
+	case float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		value.SetFloat(f)
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		value.SetBool(b)
+	case time.Duration:
+		if raw == "" {
+			value.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 15m or 720h", raw)
+		}
+		value.SetInt(int64(d))
+	case []string:
+		list := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+	return nil
+}
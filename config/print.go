@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redactedValue = "[REDACTED]"
+
+// Print writes cfg as a YAML config file, noting where each value came from.
+// With redacted set, secrets that have a value are masked.
+func Print(w io.Writer, cfg *Config, sources Sources, redacted bool) {
+	fmt.Fprintf(w, "# environment: %s\n", cfg.Server.Env)
+
+	section := ""
+	for _, f := range fields(cfg) {
+		name, key, _ := strings.Cut(f.key, ".")
+		if name != section {
+			if section != "" {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s:\n", name)
+			section = name
+		}
+
+		value := formatValue(f.value.Interface())
+		if redacted && f.secret && !f.value.IsZero() {
+			value = strconv.Quote(redactedValue)
+		}
+		fmt.Fprintf(w, "  %s: %s # %s, %s\n", key, value, f.env, sources[f.key])
+	}
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case time.Duration:
+		return strconv.Quote(v.String())
+	case []string:
+		quoted := make([]string, len(v))
+		for i, item := range v {
+			quoted[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
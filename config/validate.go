@@ -0,0 +1,205 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// finish derives the computed settings and checks every value, returning all problems found
+func (c *Config) finish() []string {
+	var problems []string
+	problem := func(key, env, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("%s (%s): %s", key, env, fmt.Sprintf(format, args...)))
+	}
+
+	switch c.Server.Env {
+	case "development", "test", "staging", "production":
+	default:
+		problem("server.env", "APP_ENV", "must be development, test, staging or production, got %q", c.Server.Env)
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		problem("server.port", "PORT", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if c.Server.BodyLimitMB < 1 {
+		problem("server.body_limit_mb", "BODY_LIMIT_MB", "must be at least 1")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		problem("server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "must be positive")
+	}
+	if c.Server.MaxQueueDepth < 0 {
+		problem("server.max_queue_depth", "MAX_QUEUE_DEPTH", "must not be negative")
+	}
+	if c.Server.ProxyHeader != "" && len(c.Server.TrustedProxies) == 0 {
+		problem("server.trusted_proxies", "TRUSTED_PROXIES", "is required with PROXY_HEADER, or any client could set its own address")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			problem("server.trusted_proxies", "TRUSTED_PROXIES", "%q is not an IP address or CIDR range", proxy)
+		}
+	}
+	location, err := time.LoadLocation(c.Server.TimeZone)
+	if err != nil {
+		problem("server.time_zone", "TIME_ZONE", "unknown time zone %q", c.Server.TimeZone)
+	}
+	c.Server.Location = location
+	c.Server.AppURL = strings.TrimSuffix(c.Server.AppURL, "/")
+
+	if c.Mongo.URI == "" {
+		problem("mongo.uri", "MONGO_URI", "is required")
+	}
+	if c.Mongo.DBName == "" {
+		problem("mongo.db_name", "MONGO_DB_NAME", "is required")
+	}
+
+	if c.Session.Keys, c.Session.ActiveKey, err = parseKeyList(c.Session.SigningKeys); err != nil {
+		problem("session.signing_keys", "SESSION_SIGNING_KEYS", "%v", err)
+	}
+	if c.Session.AccessTokenTTL <= 0 {
+		problem("session.access_token_ttl", "ACCESS_TOKEN_TTL", "must be positive")
+	}
+	if c.Session.RefreshTokenTTL <= c.Session.AccessTokenTTL {
+		problem("session.refresh_token_ttl", "REFRESH_TOKEN_TTL", "must be longer than the access token TTL")
+	}
+
+	switch c.Mail.Driver {
+	case "smtp":
+		if c.Mail.SMTPHost == "" {
+			problem("mail.smtp_host", "SMTP_HOST", "is required by the smtp mail driver")
+		}
+		if c.Mail.From == "" {
+			problem("mail.from", "MAIL_FROM", "is required by the smtp mail driver")
+		}
+	case "log", "memory":
+	default:
+		problem("mail.driver", "MAIL_DRIVER", "must be smtp, log or memory, got %q", c.Mail.Driver)
+	}
+
+	switch c.Auth.LoginThrottleStore {
+	case "mongo", "memory":
+	default:
+		problem("auth.login_throttle_store", "LOGIN_THROTTLE_STORE", "must be mongo or memory, got %q", c.Auth.LoginThrottleStore)
+	}
+
+	switch c.RateLimit.Store {
+	case "mongo", "memory":
+	default:
+		problem("rate_limit.store", "RATE_LIMIT_STORE", "must be mongo or memory, got %q", c.RateLimit.Store)
+	}
+	if c.RateLimit.Parsed, err = parseRateRules(c.RateLimit.Rules); err != nil {
+		problem("rate_limit.rules", "RATE_LIMITS", "%v", err)
+	}
+	switch c.Idempotency.Store {
+	case "mongo", "memory":
+	default:
+		problem("idempotency.store", "IDEMPOTENCY_STORE", "must be mongo or memory, got %q", c.Idempotency.Store)
+	}
+	if c.Idempotency.TTL < time.Minute {
+		problem("idempotency.ttl", "IDEMPOTENCY_TTL", "must be at least 1m")
+	}
+	if c.LLM.DailyQuota < 0 {
+		problem("llm.daily_quota", "LLM_DAILY_QUOTA", "must not be negative")
+	}
+
+	if c.Audit.Retention < 0 {
+		problem("audit.retention", "AUDIT_RETENTION", "must not be negative")
+	}
+	if c.Privacy.DataExportDir == "" {
+		problem("privacy.data_export_dir", "DATA_EXPORT_DIR", "is required")
+	}
+	if c.Privacy.AccountDeletionGrace < 0 {
+		problem("privacy.account_deletion_grace", "ACCOUNT_DELETION_GRACE", "must not be negative")
+	}
+
+	if c.Encryption.Keys, c.Encryption.ActiveKey, err = parseKeyList(c.Encryption.MasterKeys); err != nil {
+		problem("encryption.master_keys", "ENCRYPTION_MASTER_KEYS", "%v", err)
+	}
+	for kid, key := range c.Encryption.Keys {
+		if err := checkAESKey(key); err != nil {
+			problem("encryption.master_keys", "ENCRYPTION_MASTER_KEYS", "key %s: %v", kid, err)
+		}
+	}
+	if c.Encryption.BlindIndexKey != "" {
+		if err := checkAESKey(c.Encryption.BlindIndexKey); err != nil {
+			problem("encryption.blind_index_key", "BLIND_INDEX_KEY", "%v", err)
+		}
+	} else if len(c.Encryption.Keys) > 0 {
+		problem("encryption.blind_index_key", "BLIND_INDEX_KEY", "is required when encryption is enabled")
+	}
+
+	for _, sink := range c.Log.Sinks {
+		switch strings.ToLower(sink) {
+		case "stdout", "file":
+		case "betterstack":
+			if c.Log.BetterStackToken == "" {
+				problem("log.betterstack_token", "BETTERSTACK_TOKEN", "is required by the betterstack sink")
+			}
+		default:
+			problem("log.sinks", "LOG_SINKS", "unknown sink %q, use stdout, file or betterstack", sink)
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		problem("log.level", "LOG_LEVEL", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		problem("log.format", "LOG_FORMAT", "must be json or text, got %q", c.Log.Format)
+	}
+
+	if c.Metrics.Addr != "" && c.Metrics.Addr == fmt.Sprintf(":%d", c.Server.Port) {
+		problem("metrics.addr", "METRICS_ADDR", "must not be the API port")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none":
+	case "otlp":
+		if _, err := url.ParseRequestURI(c.Tracing.OTLPEndpoint); err != nil {
+			problem("tracing.otlp_endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", "must be a URL like http://localhost:4318")
+		}
+	default:
+		problem("tracing.exporter", "TRACING_EXPORTER", "must be none or otlp, got %q", c.Tracing.Exporter)
+	}
+	if _, err := parseHeaders(c.Tracing.OTLPHeaders); err != nil {
+		problem("tracing.otlp_headers", "OTEL_EXPORTER_OTLP_HEADERS", "%v", err)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problem("tracing.sample_ratio", "TRACING_SAMPLE_RATIO", "must be between 0 and 1")
+	}
+
+	// Production refuses the development fallbacks
+	if c.IsProduction() {
+		if len(c.Session.Keys) == 0 {
+			problem("session.signing_keys", "SESSION_SIGNING_KEYS", "is required in production")
+		}
+		if len(c.Encryption.Keys) == 0 {
+			problem("encryption.master_keys", "ENCRYPTION_MASTER_KEYS", "is required in production")
+		}
+		if c.Mail.Driver != "smtp" {
+			problem("mail.driver", "MAIL_DRIVER", "must be smtp in production")
+		}
+		if c.Server.AppURL == "" {
+			problem("server.app_url", "APP_URL", "is required in production")
+		}
+	}
+
+	return problems
+}
+
+// checkAESKey accepts base64 encoded 32 byte keys
+func checkAESKey(encoded string) error {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return errors.New("must be base64 encoded")
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("must be 32 bytes, got %d", len(key))
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+// Package dto holds the request and response bodies of the HTTP API. They are kept
+// apart from the MongoDB documents in models so storage changes can't leak into
+// the wire format; handlers convert with the From* functions.
+package dto
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"mate/apierror"
+	"mate/ingest"
+	"mate/users"
+	"mate/utils"
+	"mate/webhooks"
+	"mate/workspace"
+)
+
+// Request is a body the handlers parse. Validate normalises the fields it can
+// (trimming, lower-casing emails) and rejects the rest with a 400.
+type Request interface {
+	Validate() error
+}
+
+// dateLayout is the format of transaction dates
+const dateLayout = "2006-01-02"
+
+func invalid(message string) error {
+	return apierror.BadRequest(message)
+}
+
+// normaliseEmail puts an email looked up by sign-in or password reset in its stored
+// form. The format is not checked there: accounts registered before it was may hold
+// addresses that fail the check and must still be able to sign in.
+func normaliseEmail(email *string) error {
+	*email = users.NormaliseEmail(*email)
+	if *email == "" {
+		return invalid("Email is required")
+	}
+	return nil
+}
+
+// validEmail normalises an email about to be stored and checks its format
+func validEmail(email *string) error {
+	*email = users.NormaliseEmail(*email)
+	if err := utils.ValidateEmail(*email); err != nil {
+		return invalid("Invalid email address")
+	}
+	return nil
+}
+
+// Credentials signs up or signs in. The email format and password strength are
+// checked on registration only, by users.Create.
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (r *Credentials) Validate() error {
+	if err := normaliseEmail(&r.Email); err != nil {
+		return err
+	}
+	if r.Password == "" {
+		return invalid("Password is required")
+	}
+	return nil
+}
+
+type TwoFactorLoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+func (r *TwoFactorLoginRequest) Validate() error {
+	if r.MFAToken == "" {
+		return invalid("mfa_token is required")
+	}
+	if r.Code == "" && r.RecoveryCode == "" {
+		return invalid("code or recovery_code is required")
+	}
+	return nil
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *RefreshRequest) Validate() error {
+	if r.RefreshToken == "" {
+		return invalid("refresh_token is required")
+	}
+	return nil
+}
+
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+func (r *TokenRequest) Validate() error {
+	if r.Token == "" {
+		return invalid("token is required")
+	}
+	return nil
+}
+
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+func (r *EmailRequest) Validate() error {
+	return normaliseEmail(&r.Email)
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (r *ResetPasswordRequest) Validate() error {
+	if r.Token == "" {
+		return invalid("token is required")
+	}
+	if r.Password == "" {
+		return invalid("Password is required")
+	}
+	return nil
+}
+
+// ConsumeRequest is a forwarded SMS. Time is the day it was received, YYYY-MM-DD or an
+// RFC3339 timestamp, and defaults to today.
+type ConsumeRequest struct {
+	Message string `json:"message"`
+	Time    string `json:"time"`
+	Sender  string `json:"sender"`
+}
+
+func (r *ConsumeRequest) Validate() error {
+	if !ingest.IsKnownSender(r.Sender) {
+		return ingest.ErrInvalidSender
+	}
+	if strings.TrimSpace(r.Message) == "" {
+		return invalid("message is required")
+	}
+
+	switch {
+	case r.Time == "":
+		r.Time = time.Now().Format(dateLayout)
+	case isDate(r.Time):
+	default:
+		at, err := time.Parse(time.RFC3339, r.Time)
+		if err != nil {
+			return invalid("time must be YYYY-MM-DD or RFC3339")
+		}
+		r.Time = at.In(time.Local).Format(dateLayout)
+	}
+	return nil
+}
+
+// TransactionUpdate only changes the fields that are set
+type TransactionUpdate struct {
+	Type      *string  `json:"type"`
+	Amount    *float64 `json:"amount"`
+	Fee       *float64 `json:"fee"`
+	Tax       *float64 `json:"tax"`
+	Sender    *string  `json:"sender"`
+	Receiver  *string  `json:"receiver"`
+	Reference *string  `json:"reference"`
+	Date      *string  `json:"date"`
+}
+
+func (r *TransactionUpdate) Validate() error {
+	if r.Type != nil && *r.Type != "credit" && *r.Type != "debit" {
+		return invalid("Type must be credit or debit")
+	}
+	for field, value := range map[string]*float64{"amount": r.Amount, "fee": r.Fee, "tax": r.Tax} {
+		if value != nil && *value < 0 {
+			return invalid(field + " can't be negative")
+		}
+	}
+	if r.Date != nil && !isDate(*r.Date) {
+		return invalid("Date must be YYYY-MM-DD")
+	}
+	return nil
+}
+
+type CodeRequest struct {
+	Code string `json:"code"`
+}
+
+func (r *CodeRequest) Validate() error {
+	if r.Code == "" {
+		return invalid("code is required")
+	}
+	return nil
+}
+
+// ReauthRequest confirms a sensitive change with the password and, when enabled, a second factor
+type ReauthRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+func (r *ReauthRequest) Validate() error {
+	if r.Password == "" {
+		return invalid("Password is required")
+	}
+	return nil
+}
+
+type RecoveryCodesRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+func (r *RecoveryCodesRequest) Validate() error {
+	if r.Password == "" {
+		return invalid("Password is required")
+	}
+	return nil
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func (r *WebhookRequest) Validate() error {
+	endpoint, err := url.Parse(r.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return invalid("Invalid webhook url")
+	}
+	if len(r.Events) == 0 {
+		return invalid("At least one event is required")
+	}
+	for _, event := range r.Events {
+		if !webhooks.IsSupportedEvent(event) {
+			return invalid("Unsupported event: " + event)
+		}
+	}
+	return nil
+}
+
+type WorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+func (r *WorkspaceRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > 100 {
+		return invalid("Name must be 1 to 100 characters")
+	}
+	return nil
+}
+
+type InviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (r *InviteRequest) Validate() error {
+	if err := validEmail(&r.Email); err != nil {
+		return err
+	}
+	if !workspace.IsValidRole(r.Role) {
+		return invalid("Role must be editor or viewer")
+	}
+	return nil
+}
+
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (r *RoleRequest) Validate() error {
+	if !workspace.IsValidRole(r.Role) {
+		return invalid("Role must be editor or viewer")
+	}
+	return nil
+}
+
+// AccountsRequest lists the senders, e.g. MobileMoney, whose transactions a workspace
+// sees; an empty list stops sharing
+type AccountsRequest struct {
+	Accounts []string `json:"accounts"`
+}
+
+func (r *AccountsRequest) Validate() error {
+	if r.Accounts == nil {
+		r.Accounts = []string{}
+	}
+	for _, account := range r.Accounts {
+		if !ingest.IsKnownSender(account) {
+			return invalid("Unknown account: " + account)
+		}
+	}
+	return nil
+}
+
+func isDate(value string) bool {
+	_, err := time.Parse(dateLayout, value)
+	return err == nil
+}
@@ -0,0 +1,643 @@
+package dto
+
+import (
+	"time"
+
+	"mate/auth"
+	"mate/models"
+	"mate/statement"
+)
+
+// Response data of the auth, account and workspace endpoints
+
+type Registration struct {
+	Email  string `json:"email"`
+	APIKey string `json:"api_key"`
+	UserID string `json:"user_id"`
+}
+
+// LoginResult either carries the session, or only MFARequired and MFAToken when a
+// second factor has to be sent to /login/2fa
+type LoginResult struct {
+	Email        string `json:"email,omitempty"`
+	UserID       string `json:"user_id,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func FromTokens(tokens *auth.Tokens) Tokens {
+	return Tokens{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    tokens.TokenType,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+}
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+type ConsumeResult struct {
+	Message string `json:"message"`
+	Success bool   `json:"success"`
+}
+
+type APIKey struct {
+	APIKey string `json:"api_key"`
+}
+
+type RevokedSessions struct {
+	Revoked int64 `json:"revoked"`
+}
+
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DeletionSchedule struct {
+	DeletionScheduledFor string `json:"deletion_scheduled_for"`
+}
+
+type InviteAcceptance struct {
+	WorkspaceID string `json:"workspace_id"`
+	Role        string `json:"role"`
+}
+
+type SharedAccounts struct {
+	Accounts []string `json:"accounts"`
+}
+
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func FromSessions(sessions []models.Session) []Session {
+	out := make([]Session, len(sessions))
+	for i, s := range sessions {
+		out[i] = Session{
+			ID:         s.ID.Hex(),
+			UserID:     s.UserID.Hex(),
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			ExpiresAt:  s.ExpiresAt,
+			RevokedAt:  s.RevokedAt,
+		}
+	}
+	return out
+}
+
+type LoginAttempt struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func FromLoginAttempts(attempts []models.LoginAttempt) []LoginAttempt {
+	out := make([]LoginAttempt, len(attempts))
+	for i, a := range attempts {
+		out[i] = LoginAttempt{
+			ID:        a.ID.Hex(),
+			Email:     a.Email,
+			IP:        a.IP,
+			UserAgent: a.UserAgent,
+			Success:   a.Success,
+			Reason:    a.Reason,
+			CreatedAt: a.CreatedAt,
+		}
+	}
+	return out
+}
+
+type AuditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+type AuditLog struct {
+	ID         string                 `json:"id"`
+	ActorID    string                 `json:"actor_id,omitempty"`
+	ActorEmail string                 `json:"actor_email,omitempty"`
+	OwnerID    string                 `json:"owner_id,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id,omitempty"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	Diff       map[string]AuditChange `json:"diff,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+func FromAuditLogs(entries []models.AuditLog) []AuditLog {
+	out := make([]AuditLog, len(entries))
+	for i, e := range entries {
+		var diff map[string]AuditChange
+		if len(e.Diff) > 0 {
+			diff = make(map[string]AuditChange, len(e.Diff))
+			for field, change := range e.Diff {
+				diff[field] = AuditChange{From: change.From, To: change.To}
+			}
+		}
+		out[i] = AuditLog{
+			ID:         e.ID.Hex(),
+			ActorID:    e.ActorID,
+			ActorEmail: e.ActorEmail,
+			OwnerID:    e.OwnerID,
+			Action:     e.Action,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+			IP:         e.IP,
+			UserAgent:  e.UserAgent,
+			Diff:       diff,
+			CreatedAt:  e.CreatedAt,
+		}
+	}
+	return out
+}
+
+type DataExport struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Status      string     `json:"status"`
+	Size        int64      `json:"size,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
+
+func FromDataExport(e models.DataExport) DataExport {
+	return DataExport{
+		ID:          e.ID.Hex(),
+		UserID:      e.UserID,
+		Status:      e.Status,
+		Size:        e.Size,
+		Error:       e.Error,
+		CreatedAt:   e.CreatedAt,
+		CompletedAt: e.CompletedAt,
+		ExpiresAt:   e.ExpiresAt,
+	}
+}
+
+func FromDataExports(exports []models.DataExport) []DataExport {
+	out := make([]DataExport, len(exports))
+	for i, e := range exports {
+		out[i] = FromDataExport(e)
+	}
+	return out
+}
+
+type WorkspaceMember struct {
+	UserID   string    `json:"user_id"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	Accounts []string  `json:"accounts"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type Workspace struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	OwnerID   string            `json:"owner_id"`
+	Members   []WorkspaceMember `json:"members"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func FromWorkspace(ws models.Workspace) Workspace {
+	members := make([]WorkspaceMember, len(ws.Members))
+	for i, m := range ws.Members {
+		members[i] = WorkspaceMember{
+			UserID:   m.UserID.Hex(),
+			Email:    m.Email,
+			Role:     m.Role,
+			Accounts: m.Accounts,
+			JoinedAt: m.JoinedAt,
+		}
+	}
+	return Workspace{
+		ID:        ws.ID.Hex(),
+		Name:      ws.Name,
+		OwnerID:   ws.OwnerID.Hex(),
+		Members:   members,
+		CreatedAt: ws.CreatedAt,
+	}
+}
+
+func FromWorkspaces(workspaces []models.Workspace) []Workspace {
+	out := make([]Workspace, len(workspaces))
+	for i, ws := range workspaces {
+		out[i] = FromWorkspace(ws)
+	}
+	return out
+}
+
+type WorkspaceInvite struct {
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspace_id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	InvitedBy   string     `json:"invited_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+}
+
+func FromWorkspaceInvite(invite models.WorkspaceInvite) WorkspaceInvite {
+	return WorkspaceInvite{
+		ID:          invite.ID.Hex(),
+		WorkspaceID: invite.WorkspaceID.Hex(),
+		Email:       invite.Email,
+		Role:        invite.Role,
+		InvitedBy:   invite.InvitedBy.Hex(),
+		CreatedAt:   invite.CreatedAt,
+		ExpiresAt:   invite.ExpiresAt,
+		AcceptedAt:  invite.AcceptedAt,
+	}
+}
+
+// Response data of the transaction, import and webhook endpoints
+
+type Transaction struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"userid"`
+	Type          string     `json:"type"`
+	Amount        float64    `json:"amount"`
+	Fee           float64    `json:"fee"`
+	Tax           float64    `json:"tax"`
+	BalanceBefore float64    `json:"balance_before"`
+	BalanceAfter  float64    `json:"balance_after"`
+	Date          string     `json:"date"`
+	Sender        string     `json:"sender"`
+	Receiver      string     `json:"receiver"`
+	TransactionID string     `json:"transaction_id"`
+	Reference     string     `json:"reference"`
+	RawSMS        string     `json:"raw_sms"`
+	Source        string     `json:"source"`
+	Timestamp     time.Time  `json:"timestamp"`
+	Origin        string     `json:"origin"`
+	ReconciledAt  *time.Time `json:"reconciled_at,omitempty"`
+	EditedFields  []string   `json:"edited_fields,omitempty"`
+	ParserVersion string     `json:"parser_version,omitempty"`
+	ReparsedAt    *time.Time `json:"reparsed_at,omitempty"`
+}
+
+func FromTransaction(tx models.Transaction) Transaction {
+	return Transaction{
+		ID:            tx.ID.Hex(),
+		UserID:        tx.UserID,
+		Type:          tx.Type,
+		Amount:        tx.Amount,
+		Fee:           tx.Fee,
+		Tax:           tx.Tax,
+		BalanceBefore: tx.BalanceBefore,
+		BalanceAfter:  tx.BalanceAfter,
+		Date:          tx.Date,
+		Sender:        tx.Sender,
+		Receiver:      tx.Receiver,
+		TransactionID: tx.TransactionID,
+		Reference:     tx.Reference,
+		RawSMS:        tx.RawSMS,
+		Source:        tx.Source,
+		Timestamp:     tx.Timestamp,
+		Origin:        tx.Origin,
+		ReconciledAt:  tx.ReconciledAt,
+		EditedFields:  tx.EditedFields,
+		ParserVersion: tx.ParserVersion,
+		ReparsedAt:    tx.ReparsedAt,
+	}
+}
+
+func FromTransactions(transactions []models.Transaction) []Transaction {
+	out := make([]Transaction, len(transactions))
+	for i, tx := range transactions {
+		out[i] = FromTransaction(tx)
+	}
+	return out
+}
+
+// TransactionAnalysis is the dashboard payload of the transaction list
+type TransactionAnalysis struct {
+	Transactions        []Transaction    `json:"transactions"`
+	BasicStats          BasicStats       `json:"basicStats"`
+	TransactionAnalysis TransactionStats `json:"transactionAnalysis"`
+	TimeAnalysis        TimeAnalysis     `json:"timeAnalysis"`
+	UserAnalysis        UserAnalysis     `json:"userAnalysis"`
+	OriginAnalysis      OriginAnalysis   `json:"originAnalysis"`
+	// Undecryptable counts transactions whose sensitive fields could not be decrypted
+	// and are returned blank. The list is partial when it is set.
+	Undecryptable int `json:"undecryptable,omitempty"`
+}
+
+type BasicStats struct {
+	TotalTransactions int          `json:"totalTransactions"`
+	NetFlow           AmountChange `json:"netFlow"`
+	Income            AmountChange `json:"income"`
+	Expense           AmountChange `json:"expense"`
+}
+
+// AmountChange is a total with its change from yesterday to today, in percent
+type AmountChange struct {
+	Amount           float64 `json:"amount"`
+	PercentageChange float64 `json:"percentageChange"`
+}
+
+type TransactionStats struct {
+	AverageTransaction float64 `json:"averageTransaction"`
+	MaxTransaction     float64 `json:"maxTransaction"`
+	MinTransaction     float64 `json:"minTransaction"`
+	MaxBalance         float64 `json:"maxBalance"`
+	MinBalance         float64 `json:"minBalance"`
+	TotalFees          float64 `json:"totalFees"`
+	TotalTax           float64 `json:"totalTax"`
+}
+
+type TimeAnalysis struct {
+	HourlyStats []HourlyStats `json:"hourlyStats"`
+	DailyStats  []DailyStats  `json:"dailyStats"`
+}
+
+type HourlyStats struct {
+	Hour         string  `json:"hour"`
+	Transactions int     `json:"transactions"`
+	Volume       float64 `json:"volume"`
+}
+
+type DailyStats struct {
+	Date    string  `json:"date"`
+	Credits float64 `json:"credits"`
+	Debits  float64 `json:"debits"`
+	Balance float64 `json:"balance"`
+	NetFlow float64 `json:"netFlow"`
+}
+
+type UserAnalysis struct {
+	UserStats   []UserStats `json:"userStats"`
+	UniqueUsers int         `json:"uniqueUsers"`
+}
+
+type UserStats struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"value"`
+	Type   string  `json:"type"`
+}
+
+type OriginAnalysis struct {
+	Origins       []OriginStats `json:"origins"`
+	PrimaryOrigin string        `json:"primaryOrigin"`
+}
+
+type OriginStats struct {
+	Name       string  `json:"name"`
+	Value      int     `json:"value"`
+	Percentage float64 `json:"percentage"`
+}
+
+type ImportJob struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Format      string     `json:"format"`
+	Filename    string     `json:"filename"`
+	Origin      string     `json:"origin,omitempty"`
+	Status      string     `json:"status"`
+	Total       int        `json:"total"`
+	Skipped     int        `json:"skipped"`
+	Processed   int        `json:"processed"`
+	Imported    int        `json:"imported"`
+	Duplicates  int        `json:"duplicates"`
+	Failed      int        `json:"failed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func FromImportJob(job models.ImportJob) ImportJob {
+	return ImportJob{
+		ID:          job.ID.Hex(),
+		UserID:      job.UserID,
+		Format:      job.Format,
+		Filename:    job.Filename,
+		Origin:      job.Origin,
+		Status:      job.Status,
+		Total:       job.Total,
+		Skipped:     job.Skipped,
+		Processed:   job.Processed,
+		Imported:    job.Imported,
+		Duplicates:  job.Duplicates,
+		Failed:      job.Failed,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+		CompletedAt: job.CompletedAt,
+	}
+}
+
+func FromImportJobs(jobs []models.ImportJob) []ImportJob {
+	out := make([]ImportJob, len(jobs))
+	for i, job := range jobs {
+		out[i] = FromImportJob(job)
+	}
+	return out
+}
+
+type StatementRow struct {
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Reference   string    `json:"reference"`
+	Type        string    `json:"type"`
+	Amount      float64   `json:"amount"`
+	Balance     float64   `json:"balance"`
+}
+
+func fromStatementRow(row models.StatementRow) StatementRow {
+	return StatementRow{
+		Date:        row.Date,
+		Description: row.Description,
+		Reference:   row.Reference,
+		Type:        row.Type,
+		Amount:      row.Amount,
+		Balance:     row.Balance,
+	}
+}
+
+type ImportItem struct {
+	ID     string        `json:"id"`
+	JobID  string        `json:"job_id"`
+	Index  int           `json:"index"`
+	Sender string        `json:"sender"`
+	Body   string        `json:"body,omitempty"`
+	Time   string        `json:"time,omitempty"`
+	Row    *StatementRow `json:"row,omitempty"`
+	Status string        `json:"status"`
+	Reason string        `json:"reason,omitempty"`
+}
+
+// ImportReport is an import job with the reasons its failed items were rejected
+type ImportReport struct {
+	Job        ImportJob    `json:"job"`
+	Imported   int          `json:"imported"`
+	Duplicates int          `json:"duplicates"`
+	Failed     int          `json:"failed"`
+	Skipped    int          `json:"skipped"`
+	Remaining  int          `json:"remaining"`
+	Failures   []ImportItem `json:"failures"`
+}
+
+func FromImportReport(job models.ImportJob, failures []models.ImportItem) ImportReport {
+	items := make([]ImportItem, len(failures))
+	for i, item := range failures {
+		items[i] = ImportItem{
+			ID:     item.ID.Hex(),
+			JobID:  item.JobID.Hex(),
+			Index:  item.Index,
+			Sender: item.Sender,
+			Body:   item.Body,
+			Time:   item.Time,
+			Status: item.Status,
+			Reason: item.Reason,
+		}
+		if item.Row != nil {
+			row := fromStatementRow(*item.Row)
+			items[i].Row = &row
+		}
+	}
+	return ImportReport{
+		Job:        FromImportJob(job),
+		Imported:   job.Imported,
+		Duplicates: job.Duplicates,
+		Failed:     job.Failed,
+		Skipped:    job.Skipped,
+		Remaining:  job.Total - job.Processed,
+		Failures:   items,
+	}
+}
+
+type StatementFailure struct {
+	Row    StatementRow `json:"row"`
+	Reason string       `json:"reason"`
+}
+
+type StatementReport struct {
+	Layout     string             `json:"layout"`
+	Rows       int                `json:"rows"`
+	Inserted   int                `json:"inserted"`
+	Reconciled int                `json:"reconciled"`
+	Duplicates int                `json:"duplicates"`
+	Failed     int                `json:"failed"`
+	Failures   []StatementFailure `json:"failures"`
+}
+
+func FromStatementReport(report statement.Report) StatementReport {
+	failures := make([]StatementFailure, len(report.Failures))
+	for i, failure := range report.Failures {
+		failures[i] = StatementFailure{Row: fromStatementRow(failure.Row), Reason: failure.Reason}
+	}
+	return StatementReport{
+		Layout:     report.Layout,
+		Rows:       report.Rows,
+		Inserted:   report.Inserted,
+		Reconciled: report.Reconciled,
+		Duplicates: report.Duplicates,
+		Failed:     report.Failed,
+		Failures:   failures,
+	}
+}
+
+// Webhook never carries the signing secret, except in the response that creates it
+type Webhook struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func FromWebhook(webhook models.Webhook) Webhook {
+	return Webhook{
+		ID:        webhook.ID.Hex(),
+		UserID:    webhook.UserID,
+		URL:       webhook.URL,
+		Events:    webhook.Events,
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+type WebhookList struct {
+	Webhooks []Webhook `json:"webhooks"`
+	Events   []string  `json:"events"`
+}
+
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+type WebhookDelivery struct {
+	ID        string           `json:"id"`
+	WebhookID string           `json:"webhook_id"`
+	UserID    string           `json:"user_id"`
+	Event     string           `json:"event"`
+	Payload   string           `json:"payload"`
+	Status    string           `json:"status"`
+	Attempts  []WebhookAttempt `json:"attempts"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+func FromWebhookDelivery(delivery models.WebhookDelivery) WebhookDelivery {
+	attempts := make([]WebhookAttempt, len(delivery.Attempts))
+	for i, a := range delivery.Attempts {
+		attempts[i] = WebhookAttempt{At: a.At, StatusCode: a.StatusCode, Error: a.Error, DurationMs: a.DurationMs}
+	}
+	return WebhookDelivery{
+		ID:        delivery.ID.Hex(),
+		WebhookID: delivery.WebhookID.Hex(),
+		UserID:    delivery.UserID,
+		Event:     delivery.Event,
+		Payload:   delivery.Payload,
+		Status:    delivery.Status,
+		Attempts:  attempts,
+		CreatedAt: delivery.CreatedAt,
+		UpdatedAt: delivery.UpdatedAt,
+	}
+}
+
+func FromWebhookDeliveries(deliveries []models.WebhookDelivery) []WebhookDelivery {
+	out := make([]WebhookDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		out[i] = FromWebhookDelivery(delivery)
+	}
+	return out
+}
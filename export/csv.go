@@ -0,0 +1,58 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"mate/models"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin() error {
+	return c.w.Write([]string{
+		"date", "timestamp", "type", "amount", "fee", "tax", "balance_after",
+		"sender", "receiver", "transaction_id", "reference", "origin", "source",
+	})
+}
+
+func (c *csvWriter) Write(tx models.Transaction) error {
+	err := c.w.Write([]string{
+		tx.Date,
+		tx.Timestamp.Format(time.RFC3339),
+		tx.Type,
+		formatAmount(tx.Amount),
+		formatAmount(tx.Fee),
+		formatAmount(tx.Tax),
+		formatAmount(tx.BalanceAfter),
+		tx.Sender,
+		tx.Receiver,
+		tx.TransactionID,
+		tx.Reference,
+		tx.Origin,
+		tx.Source,
+	})
+	if err != nil {
+		return err
+	}
+	// Flush each row so large exports never build up in memory
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"mate/models"
+)
+
+// Supported export formats
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+	FormatQIF = "qif"
+)
+
+// Options describes the statement being exported
+type Options struct {
+	Currency  string
+	AccountID string
+	Start     time.Time
+	End       time.Time
+}
+
+// Writer streams transactions in a given file format, one at a time
+type Writer interface {
+	Begin() error
+	Write(tx models.Transaction) error
+	End() error
+}
+
+// IsSupported reports whether format can be exported
+func IsSupported(format string) bool {
+	switch strings.ToLower(format) {
+	case FormatCSV, FormatOFX, FormatQIF:
+		return true
+	}
+	return false
+}
+
+// NewWriter returns a writer for format
+func NewWriter(format string, w io.Writer, opts Options) (Writer, error) {
+	if opts.Currency == "" {
+		opts.Currency = "GHS"
+	}
+
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatOFX:
+		return newOFXWriter(w, opts), nil
+	case FormatQIF:
+		return newQIFWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ContentType returns the MIME type for format
+func ContentType(format string) string {
+	switch strings.ToLower(format) {
+	case FormatOFX:
+		return "application/x-ofx"
+	case FormatQIF:
+		return "application/qif"
+	default:
+		return "text/csv"
+	}
+}
+
+// Posted is the day a transaction took place. Timestamp is when it was stored, which
+// for forwarded and imported messages can be much later, so it only stands in when
+// Date is not a YYYY-MM-DD day.
+func Posted(tx models.Transaction) time.Time {
+	if day, err := time.Parse("2006-01-02", tx.Date); err == nil {
+		return day
+	}
+	return tx.Timestamp
+}
+
+// signedAmount returns the amount as it affects the balance
+func signedAmount(tx models.Transaction) float64 {
+	if tx.Type == "debit" {
+		return -tx.Amount
+	}
+	return tx.Amount
+}
+
+// counterparty returns whoever is on the other side of the transaction
+func counterparty(tx models.Transaction) string {
+	if tx.Sender != "" {
+		return tx.Sender
+	}
+	return tx.Receiver
+}
+
+// memo folds the columns OFX and QIF have no field for into a free-text memo
+func memo(tx models.Transaction) string {
+	parts := []string{}
+	if tx.Reference != "" {
+		parts = append(parts, "Ref "+tx.Reference)
+	}
+	parts = append(parts,
+		fmt.Sprintf("Fee %.2f", tx.Fee),
+		fmt.Sprintf("Tax %.2f", tx.Tax),
+		fmt.Sprintf("Balance %.2f", tx.BalanceAfter),
+	)
+	return strings.Join(parts, " | ")
+}
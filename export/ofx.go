@@ -0,0 +1,106 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"mate/models"
+)
+
+// ofxWriter writes an OFX 1.02 (SGML) bank statement
+type ofxWriter struct {
+	w       io.Writer
+	opts    Options
+	balance float64
+}
+
+func newOFXWriter(w io.Writer, opts Options) *ofxWriter {
+	return &ofxWriter{w: w, opts: opts}
+}
+
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func ofxDate(t time.Time) string {
+	return t.UTC().Format("20060102150405")
+}
+
+func (o *ofxWriter) Begin() error {
+	now := time.Now()
+	_, err := fmt.Fprintf(o.w, `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS>
+<STATUS><CODE>0<SEVERITY>INFO</STATUS>
+<DTSERVER>%s
+<LANGUAGE>ENG
+</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS>
+<TRNUID>1
+<STATUS><CODE>0<SEVERITY>INFO</STATUS>
+<STMTRS>
+<CURDEF>%s
+<BANKACCTFROM>
+<BANKID>MATE
+<ACCTID>%s
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>%s
+<DTEND>%s
+`, ofxDate(now), ofxEscaper.Replace(o.opts.Currency), ofxEscaper.Replace(o.opts.AccountID), ofxDate(o.opts.Start), ofxDate(o.opts.End))
+	return err
+}
+
+func (o *ofxWriter) Write(tx models.Transaction) error {
+	trnType := "CREDIT"
+	if tx.Type == "debit" {
+		trnType = "DEBIT"
+	}
+
+	fitID := tx.TransactionID
+	if fitID == "" {
+		fitID = tx.ID.Hex()
+	}
+
+	name := counterparty(tx)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+
+	// Transactions are written oldest first, so the last balance is the closing one
+	o.balance = tx.BalanceAfter
+
+	_, err := fmt.Fprintf(o.w, `<STMTTRN>
+<TRNTYPE>%s
+<DTPOSTED>%s
+<TRNAMT>%.2f
+<FITID>%s
+<NAME>%s
+<MEMO>%s
+</STMTTRN>
+`, trnType, ofxDate(Posted(tx)), signedAmount(tx), ofxEscaper.Replace(fitID), ofxEscaper.Replace(name), ofxEscaper.Replace(memo(tx)))
+	return err
+}
+
+func (o *ofxWriter) End() error {
+	_, err := fmt.Fprintf(o.w, `</BANKTRANLIST>
+<LEDGERBAL>
+<BALAMT>%.2f
+<DTASOF>%s
+</LEDGERBAL>
+</STMTRS>
+</STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`, o.balance, ofxDate(o.opts.End))
+	return err
+}
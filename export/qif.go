@@ -0,0 +1,40 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"mate/models"
+)
+
+type qifWriter struct {
+	w io.Writer
+}
+
+func newQIFWriter(w io.Writer) *qifWriter {
+	return &qifWriter{w: w}
+}
+
+// QIF is line based, so embedded newlines would start a new field
+var qifEscaper = strings.NewReplacer("\r", " ", "\n", " ")
+
+func (q *qifWriter) Begin() error {
+	_, err := fmt.Fprint(q.w, "!Type:Bank\n")
+	return err
+}
+
+func (q *qifWriter) Write(tx models.Transaction) error {
+	_, err := fmt.Fprintf(q.w, "D%s\nT%.2f\nN%s\nP%s\nM%s\n^\n",
+		Posted(tx).Format("01/02/2006"),
+		signedAmount(tx),
+		qifEscaper.Replace(tx.TransactionID),
+		qifEscaper.Replace(counterparty(tx)),
+		qifEscaper.Replace(memo(tx)),
+	)
+	return err
+}
+
+func (q *qifWriter) End() error {
+	return nil
+}
@@ -0,0 +1,166 @@
+// Package idempotency lets clients retry mutating requests safely. A request sent
+// with an Idempotency-Key header runs once per user, route and key; retries get the
+// stored response back until the key expires.
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/logging"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var logger = logging.Logger("idempotency")
+
+// Header is the request header carrying the key
+const Header = "Idempotency-Key"
+
+// staleAfter is how long a claim without a response blocks retries; after that the
+// request that made it is assumed lost
+const staleAfter = 2 * time.Minute
+
+var (
+	ErrInvalidKey = apierror.BadRequest(Header + " must be 1 to 255 printable characters")
+	ErrKeyReused  = apierror.New(422, apierror.CodeIdempotencyReused, Header+" was already used for a different request")
+	ErrKeyInUse   = apierror.New(409, apierror.CodeIdempotencyBusy, "A request with this "+Header+" is still being processed")
+)
+
+// Default is the store used by Middleware, configured by Init
+var Default Store = NewMemoryStore()
+
+// Init picks the key store from config
+func Init() {
+	if config.Current.Idempotency.Store == "memory" {
+		Default = NewMemoryStore()
+		return
+	}
+	store := NewMongoStore()
+	if err := store.EnsureIndexes(); err != nil {
+		logger.Error("error creating idempotency indexes", "error", err)
+	}
+	Default = store
+}
+
+// Middleware makes the route with the given operation ID idempotent for requests that
+// send a key. Keys are scoped to the user, or to the :userId parameter on public
+// routes; requests without either run as usual. Only successful responses are stored,
+// a failed request releases its key so it can be retried.
+func Middleware(operation string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(Header)
+		if key == "" {
+			return c.Next()
+		}
+		if !validKey(key) {
+			return ErrInvalidKey
+		}
+		user := requestUser(c)
+		if user == "" {
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		id := digest(user, operation, key)
+		now := time.Now()
+		record := Record{
+			UserID:      user,
+			Fingerprint: fingerprint(c, operation),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(config.Current.Idempotency.TTL),
+		}
+
+		existing, reserved, err := Default.Reserve(ctx, id, record, staleAfter)
+		if err != nil {
+			// Fail open, a broken store must not take the API down
+			logger.ErrorContext(ctx, "error reserving idempotency key", "error", err)
+			return c.Next()
+		}
+		if !reserved {
+			switch {
+			case existing.Fingerprint != record.Fingerprint:
+				return ErrKeyReused
+			case !existing.Done:
+				return ErrKeyInUse.After(time.Second)
+			}
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, existing.ContentType)
+			return c.Status(existing.Status).Send(existing.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			release(c, id)
+			return err
+		}
+
+		response := c.Response()
+		if response.StatusCode() >= 400 {
+			release(c, id)
+			return nil
+		}
+		body := append([]byte(nil), response.Body()...)
+		if err := Default.Complete(ctx, id, response.StatusCode(), string(response.Header.ContentType()), body); err != nil {
+			logger.ErrorContext(ctx, "error storing idempotent response", "error", err)
+		}
+		return nil
+	}
+}
+
+func release(c *fiber.Ctx, id string) {
+	if err := Default.Release(c.UserContext(), id); err != nil {
+		logger.ErrorContext(c.UserContext(), "error releasing idempotency key", "error", err)
+	}
+}
+
+// validKey accepts UUIDs and any other key of visible ASCII characters
+func validKey(key string) bool {
+	if len(key) > 255 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint identifies what a request asks for: the operation, method, route
+// parameters and body. The path is left out, so a retry through a deprecated alias of
+// the route still matches.
+func fingerprint(c *fiber.Ctx, operation string) string {
+	parts := []string{operation, c.Method()}
+	params := c.AllParams()
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+params[name])
+	}
+	return digest(append(parts, string(c.Body()))...)
+}
+
+func digest(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// requestUser is the hex ID of the signed-in user, or the public user ID of a public route
+func requestUser(c *fiber.Ctx) string {
+	if user, ok := c.Locals("user").(models.User); ok {
+		return user.ID.Hex()
+	}
+	return c.Params("userId")
+}
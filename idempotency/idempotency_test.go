@@ -0,0 +1,188 @@
+package idempotency
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testUsers = map[string]models.User{
+	"ama":  {ID: primitive.NewObjectID()},
+	"kofi": {ID: primitive.NewObjectID()},
+}
+
+// newTestApp serves a createItem route under /v1 and its deprecated /api alias. It
+// answers 500 to a "fail" body and waits for release on a "slow" one.
+func newTestApp(calls *atomic.Int32, release <-chan struct{}) *fiber.App {
+	config.Current.Idempotency.TTL = time.Hour
+	Default = NewMemoryStore()
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.Handler})
+	signIn := func(c *fiber.Ctx) error {
+		if user, ok := testUsers[c.Get("X-Test-User")]; ok {
+			c.Locals("user", user)
+		}
+		return c.Next()
+	}
+	handler := func(c *fiber.Ctx) error {
+		calls.Add(1)
+		switch string(c.Body()) {
+		case "fail":
+			return c.SendStatus(http.StatusInternalServerError)
+		case "slow":
+			<-release
+		}
+		return c.Status(http.StatusCreated).SendString("created " + c.Params("id"))
+	}
+	for _, prefix := range []string{"/v1", "/api"} {
+		app.Post(prefix+"/items/:id", signIn, Middleware("createItem"), handler)
+	}
+	return app
+}
+
+type step struct {
+	path   string
+	user   string
+	key    string
+	body   string
+	status int
+	replay bool
+}
+
+func (s step) request() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, s.path, strings.NewReader(s.body))
+	req.Header.Set("X-Test-User", s.user)
+	if s.key != "" {
+		req.Header.Set(Header, s.key)
+	}
+	return req
+}
+
+func (s step) do(t *testing.T, app *fiber.App) *http.Response {
+	t.Helper()
+	resp, err := app.Test(s.request(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []step
+		wantCalls int32
+	}{
+		{"replays a completed request", []step{
+			{"/v1/items/1", "ama", "k1", "a", http.StatusCreated, false},
+			{"/v1/items/1", "ama", "k1", "a", http.StatusCreated, true},
+		}, 1},
+		{"replays through a deprecated alias", []step{
+			{"/v1/items/1", "ama", "k1", "a", http.StatusCreated, false},
+			{"/api/items/1", "ama", "k1", "a", http.StatusCreated, true},
+		}, 1},
+		{"refuses a key reused with another body", []step{
+			{"/v1/items/1", "ama", "k1", "a", http.StatusCreated, false},
+			{"/v1/items/1", "ama", "k1", "b", http.StatusUnprocessableEntity, false},
+		}, 1},
+		{"refuses a key reused with another path parameter", []step{
+			{"/v1/items/1", "ama", "k1", "a", http.StatusCreated, false},
+			{"/v1/items/2", "ama", "k1", "a", http.StatusUnprocessableEntity, false},
+		}, 1},
+		{"keys belong to one user", []step{
+			{"/v1/items/1", "ama", "k1", "a", http.StatusCreated, false},
+			{"/v1/items/1", "kofi", "k1", "a", http.StatusCreated, false},
+		}, 2},
+		{"failed requests can be retried", []step{
+			{"/v1/items/1", "ama", "k1", "fail", http.StatusInternalServerError, false},
+			{"/v1/items/1", "ama", "k1", "fail", http.StatusInternalServerError, false},
+		}, 2},
+		{"requests without a key always run", []step{
+			{"/v1/items/1", "ama", "", "a", http.StatusCreated, false},
+			{"/v1/items/1", "ama", "", "a", http.StatusCreated, false},
+		}, 2},
+		{"anonymous requests always run", []step{
+			{"/v1/items/1", "", "k1", "a", http.StatusCreated, false},
+			{"/v1/items/1", "", "k1", "a", http.StatusCreated, false},
+		}, 2},
+		{"refuses an invalid key", []step{
+			{"/v1/items/1", "ama", "has space", "a", http.StatusBadRequest, false},
+		}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			app := newTestApp(&calls, nil)
+
+			var first string
+			for i, s := range tt.steps {
+				resp := s.do(t, app)
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+
+				if resp.StatusCode != s.status {
+					t.Fatalf("step %d: got status %d, want %d (%s)", i, resp.StatusCode, s.status, body)
+				}
+				if replayed := resp.Header.Get("Idempotent-Replayed") == "true"; replayed != s.replay {
+					t.Fatalf("step %d: got replayed %v, want %v", i, replayed, s.replay)
+				}
+				if i == 0 {
+					first = string(body)
+				} else if s.replay && string(body) != first {
+					t.Fatalf("step %d: replayed %q, want %q", i, body, first)
+				}
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("handler ran %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRefusesRetriesInFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	app := newTestApp(&calls, release)
+
+	slow := step{path: "/v1/items/1", user: "ama", key: "k1", body: "slow"}
+	done := make(chan int)
+	go func() {
+		resp, err := app.Test(slow.request(), -1)
+		if err != nil {
+			t.Error(err)
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	resp := slow.do(t, app)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("got status %d while the first request runs, want %d", resp.StatusCode, http.StatusConflict)
+	}
+
+	close(release)
+	if status := <-done; status != http.StatusCreated {
+		t.Fatalf("got status %d for the first request, want %d", status, http.StatusCreated)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("handler ran %d times, want 1", got)
+	}
+}
@@ -0,0 +1,165 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mate/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Record is a claimed key; Done is set once the response is stored
+type Record struct {
+	// UserID is the user the key belongs to, so their records go when they are purged
+	UserID      string    `bson:"user_id"`
+	Fingerprint string    `bson:"fingerprint"`
+	Done        bool      `bson:"done"`
+	Status      int       `bson:"status,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// Store keeps idempotency records
+type Store interface {
+	// Reserve claims key for a request. When the key is taken it returns the existing
+	// record and false; a claim older than staleAfter without a response is taken over.
+	Reserve(ctx context.Context, key string, record Record, staleAfter time.Duration) (*Record, bool, error)
+	// Complete stores the response of a reserved key
+	Complete(ctx context.Context, key string, status int, contentType string, body []byte) error
+	// Release drops a key so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore keeps records in process; keys only hold per instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]Record
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, key string, record Record, staleAfter time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Expired records are swept once a minute, the requested key is checked directly
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, existing := range s.records {
+			if now.After(existing.ExpiresAt) {
+				delete(s.records, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	existing, ok := s.records[key]
+	if ok && now.After(existing.ExpiresAt) {
+		ok = false
+	}
+	if ok && (existing.Done || now.Sub(existing.CreatedAt) < staleAfter) {
+		return &existing, false, nil
+	}
+	s.records[key] = record
+	return &record, true, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[key]
+	record.Done, record.Status, record.ContentType, record.Body = true, status, contentType, body
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// MongoStore keeps records in MongoDB so a retry may reach any instance
+type MongoStore struct {
+	collection string
+}
+
+func NewMongoStore() *MongoStore {
+	return &MongoStore{collection: "idempotency_keys"}
+}
+
+func (s *MongoStore) Reserve(ctx context.Context, key string, record Record, staleAfter time.Duration) (*Record, bool, error) {
+	document := bson.M{
+		"_id":         key,
+		"user_id":     record.UserID,
+		"fingerprint": record.Fingerprint,
+		"done":        false,
+		"created_at":  record.CreatedAt,
+		"expires_at":  record.ExpiresAt,
+	}
+	err := config.InsertOne(ctx, s.collection, document)
+	if err == nil {
+		return &record, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	// Take over a claim left behind by a request that never finished
+	result, err := config.UpdateOne(ctx, s.collection, bson.M{
+		"_id":        key,
+		"done":       false,
+		"created_at": bson.M{"$lt": record.CreatedAt.Add(-staleAfter)},
+	}, bson.M{"$set": document})
+	if err != nil {
+		return nil, false, err
+	}
+	if result.ModifiedCount > 0 {
+		return &record, true, nil
+	}
+
+	found, err := config.FindOne(ctx, s.collection, bson.M{"_id": key})
+	if err != nil {
+		return nil, false, err
+	}
+	var existing Record
+	if err := found.Decode(&existing); err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (s *MongoStore) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+	_, err := config.UpdateOne(ctx, s.collection, bson.M{"_id": key}, bson.M{"$set": bson.M{
+		"done":         true,
+		"status":       status,
+		"content_type": contentType,
+		"body":         body,
+	}})
+	return err
+}
+
+func (s *MongoStore) Release(ctx context.Context, key string) error {
+	_, err := config.DeleteOne(ctx, s.collection, bson.M{"_id": key})
+	return err
+}
+
+// EnsureIndexes creates the TTL index that drops expired keys and the index used to
+// purge a user's keys
+func (s *MongoStore) EnsureIndexes() error {
+	_, err := config.Database.Collection(s.collection).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	return err
+}
@@ -0,0 +1,402 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"mate/config"
+	"mate/ingest"
+	"mate/lifecycle"
+	"mate/logging"
+	"mate/models"
+	"mate/ratelimit"
+	"mate/stream"
+	"mate/vault"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var logger = logging.Logger("importer")
+
+// Job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	// JobPaused ran out of the user's daily parser quota and waits to be resumed
+	JobPaused = "paused"
+)
+
+// Item statuses
+const (
+	ItemPending   = "pending"
+	ItemImported  = "imported"
+	ItemDuplicate = "duplicate"
+	ItemFailed    = "failed"
+)
+
+// batchSize is how many pending items a worker loads at a time
+const batchSize = 100
+
+// Pending items hold raw SMS bodies and statement descriptions, encrypted like transactions
+const (
+	itemBodyField        = "import_body"
+	itemDescriptionField = "import_description"
+)
+
+var (
+	runningMu sync.Mutex
+	running   = map[primitive.ObjectID]bool{}
+)
+
+// CreateSMSJob stores the messages of an uploaded backup as a pending import job
+func CreateSMSJob(user models.User, format, filename string, messages []ingest.Message, skipped int) (*models.ImportJob, error) {
+	job := newJob(user, format, filename, "", len(messages), skipped)
+
+	items := make([]interface{}, 0, len(messages))
+	for i, msg := range messages {
+		body, err := vault.Encrypt(job.UserID, itemBodyField, msg.Body)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, models.ImportItem{
+			JobID:  job.ID,
+			Index:  i,
+			Sender: msg.Sender,
+			Body:   body,
+			Time:   msg.Time,
+			Status: ItemPending,
+		})
+	}
+
+	return job, saveJob(job, items)
+}
+
+// CreateStatementJob stores the rows of an uploaded bank statement as a pending import job
+func CreateStatementJob(user models.User, filename, origin string, rows []models.StatementRow, skipped int) (*models.ImportJob, error) {
+	job := newJob(user, FormatCSV, filename, origin, len(rows), skipped)
+
+	items := make([]interface{}, 0, len(rows))
+	for i := range rows {
+		description, err := vault.Encrypt(job.UserID, itemDescriptionField, rows[i].Description)
+		if err != nil {
+			return nil, err
+		}
+		rows[i].Description = description
+		items = append(items, models.ImportItem{
+			JobID:  job.ID,
+			Index:  i,
+			Sender: origin,
+			Row:    &rows[i],
+			Status: ItemPending,
+		})
+	}
+
+	return job, saveJob(job, items)
+}
+
+func newJob(user models.User, format, filename, origin string, total, skipped int) *models.ImportJob {
+	now := time.Now()
+	return &models.ImportJob{
+		ID:           primitive.NewObjectID(),
+		UserID:       user.ID.String(),
+		UserObjectID: user.ID,
+		Format:       format,
+		Filename:     filename,
+		Origin:       origin,
+		Status:       JobPending,
+		Total:        total,
+		Skipped:      skipped,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+func saveJob(job *models.ImportJob, items []interface{}) error {
+	// Items go in first so a job is never visible without its work
+	for start := 0; start < len(items); start += 1000 {
+		end := start + 1000
+		if end > len(items) {
+			end = len(items)
+		}
+		if err := config.InsertMany(context.Background(), "import_items", items[start:end]); err != nil {
+			return err
+		}
+	}
+	return config.InsertOne(context.Background(), "import_jobs", job)
+}
+
+// Start runs a job in the background. Starting a job that is already running is a no-op.
+func Start(jobID primitive.ObjectID) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if running[jobID] {
+		return
+	}
+	running[jobID] = true
+
+	lifecycle.Go(func() {
+		defer func() {
+			runningMu.Lock()
+			delete(running, jobID)
+			runningMu.Unlock()
+		}()
+		if err := run(jobID); err != nil {
+			logger.Error("job failed", "job_id", jobID.Hex(), "error", err)
+		}
+	})
+}
+
+// ResumePending restarts every job that was interrupted, e.g. by a restart
+func ResumePending() error {
+	cursor, err := config.Find(context.Background(), "import_jobs", bson.M{"status": bson.M{"$in": []string{JobPending, JobRunning}}}, nil)
+	if err != nil {
+		return err
+	}
+
+	var jobs []models.ImportJob
+	if err := cursor.All(context.Background(), &jobs); err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		Start(job.ID)
+	}
+	return nil
+}
+
+// Backlog counts the items still to be processed by pending and running jobs. Paused
+// jobs wait for quota, not for this instance, and are left out.
+func Backlog(ctx context.Context) (int, error) {
+	cursor, err := config.Aggregate(ctx, "import_jobs", mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$in": []string{JobPending, JobRunning}}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "left": bson.M{"$sum": bson.M{"$subtract": bson.A{"$total", "$processed"}}}}}},
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Left int `bson:"left"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil || len(totals) == 0 {
+		return 0, err
+	}
+	return totals[0].Left, nil
+}
+
+// RetryFailed queues the failed items of a job again and returns how many there were.
+// The job is left pending for Start.
+func RetryFailed(ctx context.Context, jobID primitive.ObjectID) (int, error) {
+	result, err := config.UpdateMany(ctx, "import_items", bson.M{"job_id": jobID, "status": ItemFailed}, bson.M{
+		"$set":   bson.M{"status": ItemPending},
+		"$unset": bson.M{"reason": ""},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := int(result.ModifiedCount)
+	if _, err := config.UpdateOne(ctx, "import_jobs", bson.M{"_id": jobID}, bson.M{
+		"$inc":   bson.M{"processed": -count, "failed": -count},
+		"$set":   bson.M{"status": JobPending, "updated_at": time.Now()},
+		"$unset": bson.M{"completed_at": ""},
+	}); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+func run(jobID primitive.ObjectID) error {
+	job, err := LoadJob(jobID)
+	if err != nil {
+		return err
+	}
+
+	user := models.User{ID: job.UserObjectID}
+	setStatus(job, JobRunning)
+
+	for {
+		cursor, err := config.Find(context.Background(), "import_items", bson.M{"job_id": jobID, "status": ItemPending},
+			options.Find().SetSort(bson.D{{Key: "index", Value: 1}}).SetLimit(batchSize))
+		if err != nil {
+			setStatus(job, JobFailed)
+			return err
+		}
+
+		var items []models.ImportItem
+		if err := cursor.All(context.Background(), &items); err != nil {
+			setStatus(job, JobFailed)
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			// Stop between items on shutdown; the job stays running and ResumePending
+			// picks up the items that are still pending
+			if lifecycle.Stopping() {
+				return nil
+			}
+			status, reason := processItem(user, job, item)
+			if status == ItemPending {
+				setStatus(job, JobPaused)
+				return nil
+			}
+			recordItem(job, item, status, reason)
+		}
+
+		// Let live clients follow progress once per batch
+		if latest, err := LoadJob(jobID); err == nil {
+			job = latest
+			stream.Publish(job.UserID, stream.EventIngestionJob, job)
+		}
+	}
+
+	setStatus(job, JobCompleted)
+	return nil
+}
+
+func processItem(user models.User, job *models.ImportJob, item models.ImportItem) (string, string) {
+	if err := OpenItem(job, &item); err != nil {
+		return ItemFailed, err.Error()
+	}
+
+	var err error
+	if item.Row != nil {
+		err = importRow(job, item)
+	} else {
+		_, err = ingest.Process(context.Background(), user, ingest.Message{
+			Body:   item.Body,
+			Time:   item.Time,
+			Sender: item.Sender,
+		})
+	}
+
+	switch {
+	case err == nil:
+		return ItemImported, ""
+	case errors.Is(err, ingest.ErrDuplicate):
+		return ItemDuplicate, ""
+	case errors.Is(err, ratelimit.ErrQuotaExceeded):
+		// Left pending for when the job is resumed
+		return ItemPending, ""
+	default:
+		return ItemFailed, err.Error()
+	}
+}
+
+// OpenItem decrypts the message or statement row held by an item
+func OpenItem(job *models.ImportJob, item *models.ImportItem) error {
+	var err error
+	if item.Row != nil {
+		item.Row.Description, err = vault.Decrypt(job.UserID, itemDescriptionField, item.Row.Description)
+		return err
+	}
+	item.Body, err = vault.Decrypt(job.UserID, itemBodyField, item.Body)
+	return err
+}
+
+// importRow stores a bank statement row, which needs no parsing
+func importRow(job *models.ImportJob, item models.ImportItem) error {
+	row := item.Row
+	ctx := context.Background()
+
+	// Rows are deduplicated on their reference, through its blind index when
+	// encryption is on, and rows without one on date, amount and balance
+	filter := bson.M{
+		"date":         row.Date.Format("2006-01-02"),
+		"type":         row.Type,
+		"amount":       row.Amount,
+		"balanceafter": row.Balance,
+	}
+	if row.Reference != "" {
+		filter = vault.Equals(vault.FieldReference, row.Reference)
+		filter["source"] = "csv"
+	}
+	filter["userid"], filter["origin"] = job.UserID, job.Origin
+	count, err := config.CountDocuments(ctx, "transactions", filter)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ingest.ErrDuplicate
+	}
+
+	transaction := &models.Transaction{
+		UserID:       job.UserID,
+		Type:         row.Type,
+		Amount:       row.Amount,
+		BalanceAfter: row.Balance,
+		Date:         row.Date.Format("2006-01-02"),
+		Sender:       row.Description,
+		Reference:    row.Reference,
+		Source:       "csv",
+		Timestamp:    row.Date,
+		Origin:       job.Origin,
+	}
+	return ingest.Save(ctx, transaction)
+}
+
+func recordItem(job *models.ImportJob, item models.ImportItem, status, reason string) {
+	if _, err := config.UpdateOne(context.Background(), "import_items", bson.M{"_id": item.ID}, bson.M{
+		"$set": bson.M{"status": status, "reason": reason},
+	}); err != nil {
+		logger.Error("error updating item", "job_id", job.ID.Hex(), "error", err)
+		return
+	}
+
+	counter := map[string]string{
+		ItemImported:  "imported",
+		ItemDuplicate: "duplicates",
+		ItemFailed:    "failed",
+	}[status]
+
+	if _, err := config.UpdateOne(context.Background(), "import_jobs", bson.M{"_id": job.ID}, bson.M{
+		"$inc": bson.M{"processed": 1, counter: 1},
+		"$set": bson.M{"updated_at": time.Now()},
+	}); err != nil {
+		logger.Error("error updating job", "job_id", job.ID.Hex(), "error", err)
+	}
+}
+
+func setStatus(job *models.ImportJob, status string) {
+	now := time.Now()
+	set := bson.M{"status": status, "updated_at": now}
+	if status == JobCompleted || status == JobFailed {
+		set["completed_at"] = now
+		job.CompletedAt = &now
+	}
+
+	if _, err := config.UpdateOne(context.Background(), "import_jobs", bson.M{"_id": job.ID}, bson.M{"$set": set}); err != nil {
+		logger.Error("error updating job status", "job_id", job.ID.Hex(), "error", err)
+	}
+
+	if latest, err := LoadJob(job.ID); err == nil {
+		*job = *latest
+	} else {
+		job.Status = status
+	}
+	stream.Publish(job.UserID, stream.EventIngestionJob, job)
+}
+
+// LoadJob reads a job by ID
+func LoadJob(jobID primitive.ObjectID) (*models.ImportJob, error) {
+	result, err := config.FindOne(context.Background(), "import_jobs", bson.M{"_id": jobID})
+	if err != nil {
+		return nil, err
+	}
+	var job models.ImportJob
+	if err := result.Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,295 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"mate/ingest"
+	"mate/models"
+)
+
+// Supported upload formats
+const (
+	FormatSMSXML  = "sms_xml"
+	FormatSMSJSON = "sms_json"
+	FormatCSV     = "csv"
+)
+
+// smsInbox is the "type" SMS Backup & Restore uses for received messages
+const smsInbox = "1"
+
+// DetectFormat guesses the upload format from the file name
+func DetectFormat(filename string) string {
+	switch {
+	case strings.HasSuffix(strings.ToLower(filename), ".xml"):
+		return FormatSMSXML
+	case strings.HasSuffix(strings.ToLower(filename), ".json"):
+		return FormatSMSJSON
+	case strings.HasSuffix(strings.ToLower(filename), ".csv"):
+		return FormatCSV
+	}
+	return ""
+}
+
+// ParseSMSBackupXML reads an "SMS Backup & Restore" XML dump, keeping inbox messages from known senders.
+// It returns the messages kept and the number skipped.
+func ParseSMSBackupXML(r io.Reader) ([]ingest.Message, int, error) {
+	var messages []ingest.Message
+	skipped := 0
+
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading backup: %w", err)
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Local != "sms" {
+			continue
+		}
+
+		attrs := map[string]string{}
+		for _, attr := range element.Attr {
+			attrs[attr.Name.Local] = attr.Value
+		}
+
+		if attrs["type"] != "" && attrs["type"] != smsInbox {
+			skipped++
+			continue
+		}
+		if !ingest.IsKnownSender(attrs["address"]) {
+			skipped++
+			continue
+		}
+
+		messages = append(messages, ingest.Message{
+			Sender: attrs["address"],
+			Body:   attrs["body"],
+			Time:   formatMillis(attrs["date"]),
+		})
+	}
+
+	return messages, skipped, nil
+}
+
+// ParseSMSBackupJSON reads a JSON array of messages as exported by SMS backup apps.
+// Both {address, body, date} and {sender, message, time} field names are accepted.
+func ParseSMSBackupJSON(r io.Reader) ([]ingest.Message, int, error) {
+	var entries []map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("error reading backup: %w", err)
+	}
+
+	var messages []ingest.Message
+	skipped := 0
+	for _, entry := range entries {
+		sender := firstString(entry, "address", "sender")
+		if kind := firstString(entry, "type"); kind != "" && kind != smsInbox {
+			skipped++
+			continue
+		}
+		if !ingest.IsKnownSender(sender) {
+			skipped++
+			continue
+		}
+
+		when := firstString(entry, "time")
+		if when == "" {
+			when = formatMillis(firstString(entry, "date"))
+		}
+
+		messages = append(messages, ingest.Message{
+			Sender: sender,
+			Body:   firstString(entry, "body", "message"),
+			Time:   when,
+		})
+	}
+
+	return messages, skipped, nil
+}
+
+// Column names recognised in bank CSV statements
+var csvColumns = map[string][]string{
+	"date":        {"date", "transaction date", "trans date", "value date", "posting date"},
+	"description": {"description", "narration", "details", "particulars", "transaction details"},
+	"reference":   {"reference", "ref", "ref no", "reference number", "transaction id", "cheque no"},
+	"debit":       {"debit", "debits", "withdrawal", "withdrawals", "money out", "dr"},
+	"credit":      {"credit", "credits", "deposit", "deposits", "money in", "cr"},
+	"amount":      {"amount", "transaction amount"},
+	"balance":     {"balance", "running balance", "closing balance", "available balance"},
+}
+
+// ParseStatementCSV reads a bank CSV statement. The first row must be a header; debit/credit
+// columns or a single signed amount column are both supported.
+func ParseStatementCSV(r io.Reader) ([]models.StatementRow, int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading statement header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for column, aliases := range csvColumns {
+			for _, alias := range aliases {
+				if _, seen := columns[column]; !seen && name == alias {
+					columns[column] = i
+				}
+			}
+		}
+	}
+
+	if _, ok := columns["date"]; !ok {
+		return nil, 0, errors.New("statement has no date column")
+	}
+	_, hasAmount := columns["amount"]
+	_, hasDebit := columns["debit"]
+	_, hasCredit := columns["credit"]
+	if !hasAmount && !hasDebit && !hasCredit {
+		return nil, 0, errors.New("statement has no amount, debit or credit column")
+	}
+
+	var rows []models.StatementRow
+	skipped := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading statement: %w", err)
+		}
+
+		field := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		date, err := ParseStatementDate(field("date"))
+		if err != nil {
+			// Opening balance lines, totals and page footers
+			skipped++
+			continue
+		}
+
+		row := models.StatementRow{
+			Date:        date,
+			Description: field("description"),
+			Reference:   field("reference"),
+			Balance:     ParseAmount(field("balance")),
+		}
+
+		if debit := ParseAmount(field("debit")); debit != 0 {
+			row.Type, row.Amount = "debit", debit
+		} else if credit := ParseAmount(field("credit")); credit != 0 {
+			row.Type, row.Amount = "credit", credit
+		} else if amount := ParseAmount(field("amount")); amount != 0 {
+			row.Type, row.Amount = "credit", amount
+			if amount < 0 {
+				row.Type, row.Amount = "debit", -amount
+			}
+		} else {
+			skipped++
+			continue
+		}
+		if row.Amount < 0 {
+			row.Amount = -row.Amount
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, skipped, nil
+}
+
+var statementDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"02/01/2006",
+	"02/01/2006 15:04",
+	"02-01-2006",
+	"02-Jan-2006",
+	"02-Jan-06",
+	"02 Jan 2006",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+}
+
+// ParseStatementDate parses the date formats Ghanaian banks use (day before month)
+func ParseStatementDate(value string) (time.Time, error) {
+	for _, layout := range statementDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date: %q", value)
+}
+
+// ParseAmount parses amounts like "1,234.50", "GHS 20.00", "(15.00)" or "-15.00", returning 0 when empty or invalid
+func ParseAmount(value string) float64 {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "GHS")
+	value = strings.TrimPrefix(value, "GH¢")
+	value = strings.ReplaceAll(value, ",", "")
+	value = strings.TrimSpace(value)
+
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = strings.Trim(value, "()")
+	}
+	if strings.HasSuffix(value, "DR") || strings.HasSuffix(value, "Dr") {
+		negative = true
+		value = strings.TrimSpace(value[:len(value)-2])
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	if negative {
+		return -amount
+	}
+	return amount
+}
+
+// formatMillis turns a unix epoch in milliseconds into the date format used by transactions
+func formatMillis(value string) string {
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value
+	}
+	return time.UnixMilli(millis).Format("2006-01-02")
+}
+
+func firstString(entry map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := entry[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
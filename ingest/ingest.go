@@ -0,0 +1,278 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/logging"
+	"mate/metrics"
+	"mate/models"
+	"mate/notify"
+	"mate/ratelimit"
+	"mate/utils"
+	"mate/vault"
+	"mate/webhooks"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	logger = logging.Logger("ingest")
+	tracer = otel.Tracer("mate/ingest")
+)
+
+// Errors returned by the pipeline, worded for API responses
+var (
+	ErrInvalidSender = apierror.New(422, apierror.CodeInvalidSender, "Invalid sender")
+	ErrParseMessage  = apierror.New(502, apierror.CodeUpstreamLLM, "Error parsing message")
+	ErrDecodeLLM     = apierror.New(422, apierror.CodeParseFailed, "Error turning LLM response to Struct")
+	ErrAmount        = apierror.New(422, apierror.CodeParseFailed, "Error converting amount to number")
+	ErrFee           = apierror.New(422, apierror.CodeParseFailed, "Error converting fee to number")
+	ErrTax           = apierror.New(422, apierror.CodeParseFailed, "Error converting tax to number")
+	ErrBalance       = apierror.New(422, apierror.CodeParseFailed, "Error converting balance to number")
+	ErrDuplicate     = apierror.New(409, apierror.CodeDuplicateTransaction, "Duplicate transaction")
+	ErrSave          = apierror.Internal(nil, "Error saving transaction")
+)
+
+// errorClasses label pipeline errors in metrics
+var errorClasses = map[error]string{
+	ErrInvalidSender: "invalid_sender",
+	ErrParseMessage:  "llm",
+	ErrDecodeLLM:     "llm_output",
+	ErrAmount:        "amount",
+	ErrFee:           "fee",
+	ErrTax:           "tax",
+	ErrBalance:       "balance",
+	ErrSave:          "save",
+}
+
+func recordError(span trace.Span, err error) {
+	if errors.Is(err, ErrDuplicate) {
+		span.SetAttributes(attribute.Bool("mate.duplicate", true))
+		return // counted by the check that caught it
+	}
+	class, ok := errorClasses[err]
+	if !ok {
+		class = "other"
+	}
+	metrics.IngestError(class)
+	span.SetAttributes(attribute.String("mate.error_class", class))
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// ParserVersion is stored on every transaction parsed from an SMS. Bump it whenever a
+// change to the model, its prompt or the cleanup below changes what is derived, so
+// older transactions can be selected for re-parsing.
+const ParserVersion = "1"
+
+// KnownSenders are the SMS sender IDs the parser understands
+var KnownSenders = []string{"MobileMoney", "ATMoney", "Fidelity"}
+
+// IsKnownSender reports whether sender is one of KnownSenders
+func IsKnownSender(sender string) bool {
+	for _, known := range KnownSenders {
+		if sender == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Message is a single SMS to ingest
+type Message struct {
+	Body   string
+	Time   string
+	Sender string
+}
+
+// Process parses an SMS with the LLM and regex parsers and stores the resulting transaction
+func Process(ctx context.Context, user models.User, msg Message) (*models.Transaction, error) {
+	ctx, span := tracer.Start(ctx, "ingest.process", trace.WithAttributes(attribute.String("mate.origin", msg.Sender)))
+	defer span.End()
+
+	if !IsKnownSender(msg.Sender) {
+		recordError(span, ErrInvalidSender)
+		return nil, ErrInvalidSender
+	}
+	metrics.SMSReceived(msg.Sender)
+
+	transaction, err := parse(ctx, user, msg)
+	if errors.Is(err, ErrDuplicate) {
+		// A resent SMS is not a failure, it is already stored
+		return nil, err
+	}
+	if err != nil {
+		recordError(span, err)
+		// The SMS itself is left out, it would reach webhook endpoints in cleartext
+		notify.Publish(user.ID.String(), webhooks.EventIngestionFailed, map[string]interface{}{
+			"origin": msg.Sender,
+			"time":   msg.Time,
+			"reason": err.Error(),
+		})
+		return nil, err
+	}
+
+	if err := Save(ctx, transaction); err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// Reparse runs the parsers again over the raw SMS of a stored transaction and returns
+// what they derive now. Nothing is stored. Re-parsing is started by an operator, so it
+// does not use up the user's daily parser quota.
+func Reparse(ctx context.Context, user models.User, stored models.Transaction) (*models.Transaction, error) {
+	ctx, span := tracer.Start(ctx, "ingest.reparse", trace.WithAttributes(attribute.String("mate.origin", stored.Origin)))
+	defer span.End()
+
+	transaction, err := extract(ctx, user, Message{Body: stored.RawSMS, Time: stored.Date, Sender: stored.Origin})
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	return transaction, nil
+}
+
+func parse(ctx context.Context, user models.User, msg Message) (*models.Transaction, error) {
+	// Skip the LLM call entirely for messages we have already stored
+	duplicateFilter := vault.Equals(vault.FieldRawSMS, msg.Body)
+	duplicateFilter["userid"] = user.ID.String()
+	if _, err := config.FindOne(ctx, "transactions", duplicateFilter); err == nil {
+		metrics.Duplicate("raw_sms")
+		return nil, ErrDuplicate
+	}
+	if err := ratelimit.LLMCall(ctx, user.ID.Hex()); err != nil {
+		return nil, err
+	}
+	return extract(ctx, user, msg)
+}
+
+// extract derives a transaction from an SMS with the LLM and regex parsers
+func extract(ctx context.Context, user models.User, msg Message) (*models.Transaction, error) {
+	transaction := &models.Transaction{
+		UserID: user.ID.String(),
+	}
+
+	// parse sms
+	transactionLLM, err := utils.ExtractEntitiesFromSMS(ctx, msg.Body)
+	if err != nil {
+		logger.ErrorContext(ctx, "error calling parser model", "origin", msg.Sender, "error", err)
+		metrics.ParseOutcome(metrics.EngineLLM, metrics.OutcomeFailed)
+		return nil, ErrParseMessage
+	}
+	if len(transactionLLM.Choices) == 0 {
+		metrics.ParseOutcome(metrics.EngineLLM, metrics.OutcomeFailed)
+		return nil, ErrParseMessage
+	}
+
+	escapedJSON := transactionLLM.Choices[0].Message.Content
+
+	cleanJSON := strings.Replace(escapedJSON, "\\n", "", -1)
+	cleanJSON = strings.Replace(cleanJSON, ".deep array", "", -1)
+
+	if !strings.HasSuffix(cleanJSON, "}") {
+		cleanJSON += "}"
+	}
+
+	// Remove unnecessary text using regex
+	re := regexp.MustCompile(`"receiver":\s*"(.*?)\s*Current Balance:.*?"`)
+	cleanJSON = re.ReplaceAllString(cleanJSON, `"receiver": "$1"`)
+
+	var transactionx models.LLMTransaction
+
+	err = json.Unmarshal([]byte(cleanJSON), &transactionx)
+	if err != nil {
+		// The model output holds the counterparty and balance, keep it out of the logs
+		logger.ErrorContext(ctx, "error decoding parser model output", "origin", msg.Sender, "error", err)
+		metrics.ParseOutcome(metrics.EngineLLM, metrics.OutcomeFailed)
+		return nil, ErrDecodeLLM
+	}
+	metrics.ParseOutcome(metrics.EngineLLM, metrics.OutcomeParsed)
+
+	amount, err := utils.ConvertCurrencyToFloat(transactionx.Amount)
+	if err != nil {
+		logger.ErrorContext(ctx, "error converting amount", "origin", msg.Sender, "error", err)
+		return nil, ErrAmount
+	}
+
+	_, regexSpan := tracer.Start(ctx, "parse.regex")
+	parsedMsg := utils.ParseTransaction(msg.Body)
+	regexSpan.SetAttributes(attribute.String("mate.transaction_type", string(parsedMsg.Type)))
+	regexSpan.End()
+	if parsedMsg.Type == utils.Unknown {
+		metrics.ParseOutcome(metrics.EngineRegex, metrics.OutcomeUnknown)
+	} else {
+		metrics.ParseOutcome(metrics.EngineRegex, metrics.OutcomeParsed)
+	}
+
+	transaction.Amount = amount
+	transaction.Sender = transactionx.CounterParty
+	transaction.TransactionID = transactionx.TransactionID
+	transaction.Type = string(parsedMsg.Type)
+	transaction.RawSMS = msg.Body
+	transaction.Reference = transactionx.Reference
+	transaction.Source = "sms"
+	transaction.Timestamp = time.Now()
+	transaction.Origin = msg.Sender
+	transaction.Date = msg.Time
+	transaction.ParserVersion = ParserVersion
+
+	transaction.Fee, err = utils.ConvertCurrencyToFloat(transactionx.Fee)
+	if err != nil {
+		return nil, ErrFee
+	}
+
+	transaction.Tax, err = utils.ConvertCurrencyToFloat(transactionx.Tax)
+	if err != nil {
+		logger.ErrorContext(ctx, "error converting tax", "origin", msg.Sender, "error", err)
+		return nil, ErrTax
+	}
+
+	transaction.BalanceAfter, err = utils.ConvertCurrencyToFloat(transactionx.Balance)
+	if err != nil {
+		return nil, ErrBalance
+	}
+
+	return transaction, nil
+}
+
+// Save rejects duplicates, stores the transaction and notifies subscribers
+func Save(ctx context.Context, transaction *models.Transaction) error {
+	// Check for duplicate transaction
+	if transaction.TransactionID != "" {
+		existingTransactionFilter := bson.M{"userid": transaction.UserID, "transactionid": transaction.TransactionID}
+		if _, err := config.FindOne(ctx, "transactions", existingTransactionFilter); err == nil {
+			metrics.Duplicate("transaction_id")
+			return ErrDuplicate
+		}
+	}
+
+	if transaction.ID.IsZero() {
+		transaction.ID = primitive.NewObjectID()
+	}
+	// Subscribers get the cleartext, only the stored copy is encrypted
+	sealed, err := vault.SealTransaction(*transaction)
+	if err != nil {
+		logger.ErrorContext(ctx, "error encrypting transaction", "error", err)
+		return ErrSave
+	}
+	if err := config.InsertOne(ctx, "transactions", sealed); err != nil {
+		logger.ErrorContext(ctx, "error saving transaction", "error", err)
+		return ErrSave
+	}
+
+	notify.Publish(transaction.UserID, webhooks.EventTransactionCreated, transaction)
+	return nil
+}
@@ -0,0 +1,97 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ctx, cancel           = context.WithCancel(context.Background())
+	drainCtx, cancelDrain = context.WithCancel(context.Background())
+	workers               sync.WaitGroup
+	active                atomic.Int64
+	draining, stopping    atomic.Bool
+)
+
+// Go runs fn in the background; Wait blocks until it returns
+func Go(fn func()) {
+	workers.Add(1)
+	active.Add(1)
+	go func() {
+		defer func() {
+			active.Add(-1)
+			workers.Done()
+		}()
+		fn()
+	}()
+}
+
+// Context is cancelled once shutdown begins
+func Context() context.Context {
+	return ctx
+}
+
+// Draining is cancelled once the server stops taking requests. Long lived responses
+// such as event streams end on it, so they do not hold up the drain.
+func Draining() context.Context {
+	return drainCtx
+}
+
+// Drain begins shutdown of the server: readiness fails and Draining is cancelled,
+// while background workers keep running until Stop
+func Drain() {
+	draining.Store(true)
+	cancelDrain()
+}
+
+// Ready reports whether the instance still takes new requests
+func Ready() bool {
+	return !draining.Load()
+}
+
+// Stopping reports whether shutdown has begun. Long running workers check it at safe
+// points and leave the rest of their work to be resumed on the next start.
+func Stopping() bool {
+	return stopping.Load()
+}
+
+// Sleep pauses for d and reports false if shutdown began in the meantime
+func Sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Active is the number of background workers still running
+func Active() int {
+	return int(active.Load())
+}
+
+// Stop begins shutdown of background work: Context is cancelled and workers wind down
+func Stop() {
+	Drain()
+	stopping.Store(true)
+	cancel()
+}
+
+// Wait blocks until every worker has returned or ctx is done
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,179 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"mate/config"
+
+	slogbetterstack "github.com/samber/slog-betterstack"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sink names accepted in LOG_SINKS
+const (
+	SinkStdout      = "stdout"
+	SinkFile        = "file"
+	SinkBetterStack = "betterstack"
+)
+
+// current is the configured handler; loggers created before Init pick it up once it is set
+var current atomic.Pointer[slog.Handler]
+
+func init() {
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{ReplaceAttr: redact})
+	current.Store(&handler)
+	slog.SetDefault(slog.New(&lazyHandler{}))
+}
+
+// Init builds the sinks from config and makes them the default logger. The standard
+// library log package is routed through it as well.
+func Init() error {
+	level := parseLevel(config.Current.Log.Level)
+	setRedactKeys(config.Current.Log.RedactKeys)
+
+	handlers := make([]slog.Handler, 0, len(config.Current.Log.Sinks))
+	for _, sink := range config.Current.Log.Sinks {
+		switch strings.ToLower(sink) {
+		case SinkStdout:
+			handlers = append(handlers, newWriterHandler(os.Stdout, level))
+		case SinkFile:
+			file, err := os.OpenFile(config.Current.Log.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+			if err != nil {
+				return fmt.Errorf("opening log file: %w", err)
+			}
+			handlers = append(handlers, newWriterHandler(file, level))
+		case SinkBetterStack:
+			if config.Current.Log.BetterStackToken == "" {
+				return fmt.Errorf("the %s sink needs BETTERSTACK_TOKEN", SinkBetterStack)
+			}
+			handlers = append(handlers, slogbetterstack.Option{
+				Level:       level,
+				Token:       config.Current.Log.BetterStackToken,
+				Endpoint:    config.Current.Log.BetterStackEndpoint,
+				ReplaceAttr: redact,
+			}.NewBetterstackHandler())
+		default:
+			return fmt.Errorf("unknown log sink %q", sink)
+		}
+	}
+
+	var handler slog.Handler = fanout(handlers)
+	if len(handlers) == 1 {
+		handler = handlers[0]
+	}
+	current.Store(&handler)
+	slog.SetDefault(slog.New(&lazyHandler{}))
+	return nil
+}
+
+// Logger returns a logger tagged with the component it is used from
+func Logger(component string) *slog.Logger {
+	return slog.New(&lazyHandler{}).With("component", component)
+}
+
+func newWriterHandler(w io.Writer, level slog.Level) slog.Handler {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	if strings.ToLower(config.Current.Log.Format) == "text" {
+		return slog.NewTextHandler(w, options)
+	}
+	return slog.NewJSONHandler(w, options)
+}
+
+func parseLevel(value string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// lazyHandler forwards to the current handler, replaying the attributes and groups
+// it was derived with
+type lazyHandler struct {
+	derive []func(slog.Handler) slog.Handler
+}
+
+func (h *lazyHandler) handler() slog.Handler {
+	handler := *current.Load()
+	for _, derive := range h.derive {
+		handler = derive(handler)
+	}
+	return handler
+}
+
+func (h *lazyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (*current.Load()).Enabled(ctx, level)
+}
+
+// Handle adds the trace and span IDs of a span in ctx, so logs written with the
+// *Context methods can be matched to their trace
+func (h *lazyHandler) Handle(ctx context.Context, record slog.Record) error {
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return h.handler().Handle(ctx, record)
+}
+
+func (h *lazyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *lazyHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *lazyHandler) with(derive func(slog.Handler) slog.Handler) slog.Handler {
+	derived := make([]func(slog.Handler) slog.Handler, len(h.derive), len(h.derive)+1)
+	copy(derived, h.derive)
+	return &lazyHandler{derive: append(derived, derive)}
+}
+
+// fanout sends every record to all sinks
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range f {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, handler := range f {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, handler := range f {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, handler := range f {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIDKey = "requestid"
+	loggerKey    = "logger"
+)
+
+// RequestID assigns every request an ID, reusing a valid X-Request-ID from the client
+func RequestID() fiber.Handler {
+	return requestid.New(requestid.Config{
+		ContextKey: requestIDKey,
+	})
+}
+
+// AccessLog writes one line per request. Bodies and query strings are never logged
+// since they carry passwords, tokens and SMS text.
+func AccessLog() fiber.Handler {
+	logger := Logger("http")
+
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		if err != nil {
+			// Let the error handler pick the status before it is logged
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"request_id", RequestIDFrom(c),
+			"method", c.Method(),
+			"path", c.Path(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"ip", c.IP(),
+			"user_agent", c.Get(fiber.HeaderUserAgent),
+			"bytes", len(c.Response().Body()),
+		}
+		if user, ok := c.Locals("user").(models.User); ok {
+			attrs = append(attrs, "user_id", user.ID.Hex())
+		}
+		logger.Log(c.UserContext(), level, "request", attrs...)
+		return nil
+	}
+}
+
+// RequestIDFrom returns the ID assigned by RequestID
+func RequestIDFrom(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestIDKey).(string)
+	return id
+}
+
+// FromCtx returns a logger for a request handler, tagged with the request and trace IDs
+func FromCtx(c *fiber.Ctx) *slog.Logger {
+	if logger, ok := c.Locals(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	logger := Logger("routes").With("request_id", RequestIDFrom(c))
+	if span := trace.SpanContextFromContext(c.UserContext()); span.IsValid() {
+		logger = logger.With("trace_id", span.TraceID().String())
+	}
+	c.Locals(loggerKey, logger)
+	return logger
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// defaultRedactKeys are attribute names that hold credentials or bank data. Matching
+// ignores case and treats "-" like "_", so "API-Key" and "api_key" are both caught.
+var defaultRedactKeys = []string{
+	"password", "new_password", "current_password",
+	"api_key", "apikey", "authorization", "cookie",
+	"token", "access_token", "refresh_token", "mfa_token",
+	"secret", "totp_secret", "code", "recovery_code",
+	"raw_sms", "sms", "body", "request_body",
+}
+
+var redactKeys atomic.Pointer[map[string]bool]
+
+func init() {
+	setRedactKeys(nil)
+}
+
+func setRedactKeys(extra []string) {
+	keys := map[string]bool{}
+	for _, key := range append(append([]string{}, defaultRedactKeys...), extra...) {
+		keys[normalizeKey(key)] = true
+	}
+	redactKeys.Store(&keys)
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "-", "_")
+}
+
+// IsSensitive reports whether values logged under key are redacted
+func IsSensitive(key string) bool {
+	return (*redactKeys.Load())[normalizeKey(key)]
+}
+
+// redact is the ReplaceAttr hook shared by every sink
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() != slog.KindGroup && IsSensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
@@ -0,0 +1,21 @@
+package mailer
+
+import "mate/config"
+
+// Init picks the mailer from MAIL_DRIVER: "smtp", "memory" or "log" (the default)
+func Init() {
+	switch config.Current.Mail.Driver {
+	case "smtp":
+		Default = &SMTPMailer{
+			Host:     config.Current.Mail.SMTPHost,
+			Port:     config.Current.Mail.SMTPPort,
+			Username: config.Current.Mail.SMTPUsername,
+			Password: config.Current.Mail.SMTPPassword,
+			From:     config.Current.Mail.From,
+		}
+	case "memory":
+		Default = &MemoryMailer{}
+	default:
+		Default = &LogMailer{}
+	}
+}
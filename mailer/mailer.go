@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer delivers through an SMTP server using PLAIN auth
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	body := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		msg.Body,
+	}, "\r\n")
+
+	// The envelope sender must be a bare address even when From has a display name
+	sender := m.From
+	if address, err := mail.ParseAddress(m.From); err == nil {
+		sender = address.Address
+	}
+
+	if err := smtp.SendMail(m.Host+":"+m.Port, auth, sender, []string{msg.To}, []byte(body)); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	return nil
+}
+
+// LogMailer prints emails to stdout instead of sending them, for local development.
+// They bypass the log sinks since they carry verification and reset links.
+type LogMailer struct{}
+
+func (m *LogMailer) Send(msg Message) error {
+	_, err := fmt.Fprintf(os.Stdout, "To: %s\nSubject: %s\n\n%s\n\n", msg.To, msg.Subject, msg.Body)
+	return err
+}
+
+// MemoryMailer keeps sent emails in memory so tests can inspect them
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns every email sent so far
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := make([]Message, len(m.messages))
+	copy(messages, m.messages)
+	return messages
+}
+
+// Last returns the most recent email sent to address
+func (m *MemoryMailer) Last(address string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == address {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// Default is the mailer used by the HTTP handlers, chosen by Init
+var Default Mailer = &LogMailer{}
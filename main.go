@@ -1,68 +1,168 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"mate/apierror"
+	"mate/auth"
 	"mate/config"
-	"mate/middleware"
+	"mate/idempotency"
+	"mate/importer"
+	"mate/lifecycle"
+	"mate/logging"
+	"mate/mailer"
+	"mate/metrics"
+	"mate/privacy"
+	"mate/ratelimit"
 	"mate/routes"
+	"mate/tracing"
+	"mate/vault"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"log/slog"
-
-	slogbetterstack "github.com/samber/slog-betterstack"
-
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/idempotency"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/gofiber/fiber/v2/middleware/monitor"
 )
 
 func main() {
-	config.InitConfig()
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	if err := config.InitConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	time.Local = config.Current.Server.Location
+	if err := logging.Init(); err != nil {
+		fatal("failed to configure logging", err)
+	}
+	shutdownTracing, err := tracing.Init()
+	if err != nil {
+		fatal("failed to configure tracing", err)
+	}
 	config.ConnectToDB()
+	if err := vault.Init(); err != nil {
+		fatal("failed to load encryption keys", err)
+	}
+	mailer.Init()
+	auth.InitLoginGuard()
+	ratelimit.Init()
+	idempotency.Init()
 
-	logtail := slog.New(slogbetterstack.Option{Token: "LaqNpxUmKuSH74TGTRKS8iML"}.NewBetterstackHandler())
+	if err := ensureIndexes(); err != nil {
+		slog.Error("failed to create indexes", "error", err)
+	}
 
-	app := fiber.New()
+	// Finish any master key rotation and encrypt data stored before encryption was enabled
+	lifecycle.Go(func() {
+		if err := migrateData(); err != nil {
+			slog.Error("failed to migrate data", "error", err)
+		}
+	})
 
-	// Initialize handlers
-	userHandler := routes.NewUserHandler()
-	transactionHandler := routes.NewTransactionHandler()
+	// Pick up imports interrupted by the last shutdown
+	if err := importer.ResumePending(); err != nil {
+		slog.Error("failed to resume imports", "error", err)
+	}
+	if err := privacy.ResumePendingExports(); err != nil {
+		slog.Error("failed to resume data exports", "error", err)
+	}
+	privacy.StartSweeper()
 
-	app.Use(idempotency.New(idempotency.Config{
-		KeyHeaderValidate: func(k string) error {
-			if l, wl := len(k), 3; l != wl { // UUID length is 36 chars
-				return fmt.Errorf("%w: invalid length: %d != %d", idempotency.ErrInvalidIdempotencyKey, l, wl)
-			}
+	// Client addresses key the per-IP rate limits and login throttle, so a proxy header
+	// is only believed from the configured proxies
+	app := fiber.New(fiber.Config{
+		BodyLimit:               config.Current.Server.BodyLimitMB * 1024 * 1024,
+		ErrorHandler:            apierror.Handler,
+		ProxyHeader:             config.Current.Server.ProxyHeader,
+		EnableTrustedProxyCheck: len(config.Current.Server.TrustedProxies) > 0,
+		TrustedProxies:          config.Current.Server.TrustedProxies,
+		EnableIPValidation:      true,
+	})
 
-			return nil
-		},
-	}))
+	healthHandler := routes.NewHealthHandler()
+
+	// Probes are registered ahead of the middleware so they stay out of the access log
+	app.Get("/healthz", healthHandler.Live)
+	app.Get("/readyz", healthHandler.Ready)
+
+	app.Use(logging.RequestID())
+	app.Use(tracing.Middleware())
+	app.Use(metrics.HTTP())
+	app.Use(logging.AccessLog())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 	}))
 
-	app.Use(logger.New(logger.Config{
-		TimeFormat: time.RFC3339Nano,
-		TimeZone:   "Africa/Accra",
-		Done: func(c *fiber.Ctx, logString []byte) {
-			bodyBytes := c.Body()
-			logtail.Info(string(logString) + " | Request Body: " + string(bodyBytes))
-		},
-	}))
+	routes.Register(app)
+
+	var metricsServer *http.Server
+	if addr := config.Current.Metrics.Addr; addr != "" {
+		metricsServer = metrics.NewServer(addr, config.Current.Metrics.Token)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("metrics server stopped", err)
+			}
+		}()
+	}
+
+	go func() {
+		if err := app.Listen(fmt.Sprintf(":%d", config.Current.Server.Port)); err != nil {
+			fatal("server stopped", err)
+		}
+	}()
 
-	// Public routes
-	app.Post("/register", userHandler.Register)
-	app.Post("/login", userHandler.Login)
-	app.Post("/consume/:userId", transactionHandler.Consume)
-	app.Get("/metrics", monitor.New())
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	shutdown(app, metricsServer, shutdownTracing)
+}
+
+// shutdown fails readiness and ends event streams, stops accepting connections, lets
+// in-flight requests such as Consume finish, then stops and waits for background
+// workers and closes MongoDB, all within the shutdown timeout
+func shutdown(app *fiber.App, metricsServer *http.Server, shutdownTracing func(context.Context) error) {
+	timeout := config.Current.Server.ShutdownTimeout
+	slog.Info("shutting down", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	// Protected routes
-	api := app.Group("/api", middleware.APIKeyAuth())
-	api.Get("/transaction", transactionHandler.GetTransactions)
-	// api.Get("/transactions", transactionHandler.List)
+	// Drain requests first: the work they hand to background workers, such as webhook
+	// deliveries for a Consume, must still be started before the workers are stopped.
+	// Event streams never finish on their own, so they are ended before the drain.
+	lifecycle.Drain()
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		slog.Error("failed to drain requests", "error", err)
+	}
+	lifecycle.Stop()
+	if err := lifecycle.Wait(ctx); err != nil {
+		slog.Error("background work did not finish", "active", lifecycle.Active(), "error", err)
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			slog.Error("failed to stop metrics server", "error", err)
+		}
+	}
+
+	// Use a fresh deadline so the connection is closed even when draining ran out of time
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer closeCancel()
+	if err := config.Disconnect(closeCtx); err != nil {
+		slog.Error("failed to disconnect from MongoDB", "error", err)
+	}
+	if err := shutdownTracing(closeCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+	slog.Info("shutdown complete")
+}
 
-	log.Fatal(app.Listen(":3001"))
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Parse engines and outcomes
+const (
+	EngineLLM   = "llm"
+	EngineRegex = "regex"
+
+	OutcomeParsed  = "parsed"
+	OutcomeFailed  = "failed"
+	OutcomeUnknown = "unknown"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	smsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mate_sms_received_total",
+		Help: "SMS messages received for ingestion, by sender.",
+	}, []string{"sender"})
+
+	parseOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mate_parse_outcomes_total",
+		Help: "Parse results by engine and outcome.",
+	}, []string{"engine", "outcome"})
+
+	llmDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mate_llm_request_duration_seconds",
+		Help:    "Latency of LLM parser requests.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 16, 32},
+	}, []string{"status"})
+
+	llmTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mate_llm_tokens_total",
+		Help: "Tokens used by LLM parser requests, by kind (prompt or completion).",
+	}, []string{"kind"})
+
+	ingestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mate_ingest_errors_total",
+		Help: "Messages that could not be ingested, by error class.",
+	}, []string{"class"})
+
+	duplicates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mate_duplicate_rejections_total",
+		Help: "Transactions rejected as duplicates, by the check that caught them.",
+	}, []string{"check"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mate_http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mate_rate_limited_total",
+		Help: "Requests and parser model calls refused by a rate limit or quota, by route and key.",
+	}, []string{"route", "by"})
+
+	deprecatedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mate_deprecated_requests_total",
+		Help: "Requests to deprecated API versions, by version and route.",
+	}, []string{"version", "route"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		smsReceived, parseOutcomes, llmDuration, llmTokens, ingestErrors, duplicates, httpDuration,
+		rateLimited, deprecatedRequests,
+	)
+}
+
+// SMSReceived counts a message from sender, which callers should limit to known senders
+func SMSReceived(sender string) {
+	smsReceived.WithLabelValues(sender).Inc()
+}
+
+// ParseOutcome counts one parse attempt
+func ParseOutcome(engine, outcome string) {
+	parseOutcomes.WithLabelValues(engine, outcome).Inc()
+}
+
+// LLMRequest records the latency of one LLM call
+func LLMRequest(duration time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	llmDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+// LLMTokens records the token usage reported by the LLM provider
+func LLMTokens(prompt, completion int) {
+	llmTokens.WithLabelValues("prompt").Add(float64(prompt))
+	llmTokens.WithLabelValues("completion").Add(float64(completion))
+}
+
+// IngestError counts a failed ingestion by error class
+func IngestError(class string) {
+	ingestErrors.WithLabelValues(class).Inc()
+}
+
+// Duplicate counts a duplicate caught by check
+func Duplicate(check string) {
+	duplicates.WithLabelValues(check).Inc()
+}
+
+// RateLimited counts a request refused by the rule for route and by (user or ip)
+func RateLimited(route, by string) {
+	rateLimited.WithLabelValues(route, by).Inc()
+}
+
+// DeprecatedRequest counts a call to a route of a deprecated API version
+func DeprecatedRequest(version, route string) {
+	deprecatedRequests.WithLabelValues(version, route).Inc()
+}
+
+// HTTP records request latency. Routes are labelled by their pattern (e.g.
+// /v1/transaction/:id) so ids do not blow up the number of series.
+func HTTP() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		route := "unmatched"
+		if r := c.Route(); r != nil && r.Path != "/" {
+			route = r.Path
+		}
+		httpDuration.WithLabelValues(c.Method(), route, strconv.Itoa(c.Response().StatusCode())).
+			Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Handler serves the Prometheus exposition format. A non-empty token must be sent
+// as "Authorization: Bearer <token>".
+func Handler(token string) http.Handler {
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return handler
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// NewServer returns the admin server exposing /metrics on addr
+func NewServer(addr, token string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(token))
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
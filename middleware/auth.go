@@ -1,41 +1,66 @@
 package middleware
 
 import (
+	"strings"
+
+	"mate/apierror"
+	"mate/auth"
 	"mate/config"
 	"mate/models"
+	"mate/users"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-func APIKeyAuth() fiber.Handler {
+// Auth accepts either a session access token ("Bearer <jwt>") or an API key,
+// with or without the Bearer scheme. Live streams may pass the access token as
+// ?access_token= instead; the long-lived API key is never read from the URL.
+func Auth() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		apiKey := c.Get("Authorization")
-		if apiKey == "" {
-			return c.Status(401).JSON(models.Response{
-				Success: false,
-				Error:   "API key required",
-			})
+		credential := strings.TrimSpace(c.Get("Authorization"))
+		if scheme, token, ok := strings.Cut(credential, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			credential = strings.TrimSpace(token)
+		}
+
+		// EventSource can't set headers. URLs end up in proxy logs and browser history, so
+		// only the short-lived access token is accepted there.
+		if credential == "" && strings.Contains(c.Get("Accept"), "text/event-stream") {
+			if token := c.Query("access_token"); auth.LooksLikeJWT(token) {
+				credential = token
+			}
+		}
+
+		if credential == "" {
+			return apierror.New(401, apierror.CodeAuthRequired, "API key required")
+		}
+
+		var filter bson.M
+		if auth.LooksLikeJWT(credential) {
+			session, err := auth.Authenticate(credential)
+			if err != nil {
+				return apierror.New(401, apierror.CodeSessionExpired, "Invalid or expired session")
+			}
+			c.Locals("session_id", session.ID)
+			filter = bson.M{"_id": session.UserID}
+		} else {
+			// Verify API key
+			filter = bson.M{"api_key": credential}
 		}
 
-		// Verify API key
-		filter := bson.M{"api_key": apiKey}
 		var user models.User
-		result, err := config.FindOne("users", filter)
+		result, err := config.FindOne(c.UserContext(), "users", filter)
 
 		if err != nil {
-			return c.Status(401).JSON(models.Response{
-				Success: false,
-				Error:   "Invalid API key",
-			})
+			return apierror.New(401, apierror.CodeInvalidAPIKey, "Invalid API key")
 		}
 
 		// Decode the result into the User struct
 		if err := result.Decode(&user); err != nil {
-			return c.Status(500).JSON(models.Response{
-				Success: false,
-				Error:   "Error decoding user data",
-			})
+			return apierror.Internal(err, "Error decoding user data")
+		}
+		if user.DisabledAt != nil {
+			return users.ErrDisabled
 		}
 
 		// Add user to context
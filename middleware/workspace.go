@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"mate/apierror"
+	"mate/config"
+	"mate/models"
+	"mate/workspace"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Workspace resolves the active workspace from the X-Workspace-ID header and stores the
+// resulting scope in c.Locals("scope"). Without the header requests see the user's own ledger.
+func Workspace() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(models.User)
+
+		workspaceID := c.Get("X-Workspace-ID")
+		if workspaceID == "" {
+			workspaceID = c.Query("workspace_id")
+		}
+		if workspaceID == "" {
+			c.Locals("scope", workspace.Personal(user))
+			return c.Next()
+		}
+
+		id, err := primitive.ObjectIDFromHex(workspaceID)
+		if err != nil {
+			return apierror.InvalidID("Invalid workspace id")
+		}
+
+		// Only members can see a workspace
+		result, err := config.FindOne(c.UserContext(), "workspaces", bson.M{"_id": id, "members.user_id": user.ID})
+		if err != nil {
+			return apierror.New(404, apierror.CodeWorkspaceNotFound, "Workspace not found")
+		}
+
+		var ws models.Workspace
+		if err := result.Decode(&ws); err != nil {
+			return apierror.Internal(err, "Error decoding workspace")
+		}
+
+		member, _ := workspace.Member(&ws, user)
+		c.Locals("scope", workspace.Scope{User: user, Workspace: &ws, Role: member.Role})
+		return c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"mate/audit"
+	"mate/auth"
+	"mate/config"
+	"mate/statement"
+	"mate/users"
+	"mate/vault"
+	"mate/webhooks"
+)
+
+// ensureIndexes creates the indexes the collections rely on. Stores picked in Init
+// functions, such as the rate limit counters, create their own.
+func ensureIndexes() error {
+	var errs []error
+	if err := audit.EnsureIndexes(config.Current.Audit.Retention); err != nil {
+		errs = append(errs, fmt.Errorf("audit log indexes: %w", err))
+	}
+	if err := auth.EnsureIndexes(); err != nil {
+		errs = append(errs, fmt.Errorf("used MFA token indexes: %w", err))
+	}
+	if err := vault.EnsureIndexes(); err != nil {
+		errs = append(errs, fmt.Errorf("data key indexes: %w", err))
+	}
+	if err := vault.EnsureTransactionIndexes(); err != nil {
+		errs = append(errs, fmt.Errorf("transaction indexes: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// migrateData finishes any master key rotation, encrypts transactions and webhook
+// payloads stored before encryption was enabled, takes statement references out of
+// the cleartext transaction ID and lowercases stored emails. Every step is safe to
+// repeat.
+func migrateData() error {
+	var errs []error
+	if count, err := vault.RewrapDataKeys(); err != nil {
+		errs = append(errs, fmt.Errorf("rewrapping data keys: %w", err))
+	} else if count > 0 {
+		slog.Info("rewrapped data keys", "count", count)
+	}
+	if count, err := vault.SealExistingTransactions(); err != nil {
+		errs = append(errs, fmt.Errorf("encrypting existing transactions: %w", err))
+	} else if count > 0 {
+		slog.Info("encrypted existing transactions", "count", count)
+	}
+	if count, err := statement.MoveReferences(context.Background()); err != nil {
+		errs = append(errs, fmt.Errorf("moving statement references: %w", err))
+	} else if count > 0 {
+		slog.Info("moved statement references", "count", count)
+	}
+	if count, conflicts, err := users.NormaliseStoredEmails(context.Background()); err != nil {
+		errs = append(errs, fmt.Errorf("normalising emails: %w", err))
+	} else {
+		if count > 0 {
+			slog.Info("normalised account emails", "count", count)
+		}
+		for _, id := range conflicts {
+			slog.Warn("account email differs only in case from another account, left unchanged", "user_id", id.Hex())
+		}
+	}
+	if count, err := webhooks.SealExistingPayloads(); err != nil {
+		errs = append(errs, fmt.Errorf("encrypting webhook payloads: %w", err))
+	} else if count > 0 {
+		slog.Info("encrypted webhook payloads", "count", count)
+	}
+	return errors.Join(errs...)
+}
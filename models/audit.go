@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditChange struct {
+	From interface{} `bson:"from" json:"from"`
+	To   interface{} `bson:"to" json:"to"`
+}
+
+type AuditLog struct {
+	ID         primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	ActorID    string                 `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	ActorEmail string                 `bson:"actor_email,omitempty" json:"actor_email,omitempty"`
+	OwnerID    string                 `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	Action     string                 `bson:"action" json:"action"`
+	TargetType string                 `bson:"target_type" json:"target_type"`
+	TargetID   string                 `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	IP         string                 `bson:"ip" json:"ip"`
+	UserAgent  string                 `bson:"user_agent" json:"user_agent"`
+	Diff       map[string]AuditChange `bson:"diff,omitempty" json:"diff,omitempty"`
+	CreatedAt  time.Time              `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DataExport struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       string             `bson:"user_id" json:"user_id"`
+	UserObjectID primitive.ObjectID `bson:"user_object_id" json:"-"`
+	Status       string             `bson:"status" json:"status"`
+	Path         string             `bson:"path,omitempty" json:"-"`
+	Size         int64              `bson:"size,omitempty" json:"size,omitempty"`
+	Error        string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	CompletedAt  *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+}
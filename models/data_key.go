@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DataKey is a user's data encryption key, wrapped by a master key
+type DataKey struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	MasterKeyID string             `bson:"master_key_id" json:"master_key_id"`
+	WrappedKey  []byte             `bson:"wrapped_key" json:"-"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	RotatedAt   *time.Time         `bson:"rotated_at,omitempty" json:"rotated_at,omitempty"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ImportJob struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       string             `bson:"user_id" json:"user_id"`
+	UserObjectID primitive.ObjectID `bson:"user_object_id" json:"-"`
+	Format       string             `bson:"format" json:"format"`
+	Filename     string             `bson:"filename" json:"filename"`
+	Origin       string             `bson:"origin,omitempty" json:"origin,omitempty"`
+	Status       string             `bson:"status" json:"status"`
+	Total        int                `bson:"total" json:"total"`
+	Skipped      int                `bson:"skipped" json:"skipped"`
+	Processed    int                `bson:"processed" json:"processed"`
+	Imported     int                `bson:"imported" json:"imported"`
+	Duplicates   int                `bson:"duplicates" json:"duplicates"`
+	Failed       int                `bson:"failed" json:"failed"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	CompletedAt  *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+type StatementRow struct {
+	Date        time.Time `bson:"date" json:"date"`
+	Description string    `bson:"description" json:"description"`
+	Reference   string    `bson:"reference" json:"reference"`
+	Type        string    `bson:"type" json:"type"`
+	Amount      float64   `bson:"amount" json:"amount"`
+	Balance     float64   `bson:"balance" json:"balance"`
+}
+
+type ImportItem struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	JobID  primitive.ObjectID `bson:"job_id" json:"job_id"`
+	Index  int                `bson:"index" json:"index"`
+	Sender string             `bson:"sender" json:"sender"`
+	Body   string             `bson:"body,omitempty" json:"body,omitempty"`
+	Time   string             `bson:"time,omitempty" json:"time,omitempty"`
+	Row    *StatementRow      `bson:"row,omitempty" json:"row,omitempty"`
+	Status string             `bson:"status" json:"status"`
+	Reason string             `bson:"reason,omitempty" json:"reason,omitempty"`
+}
@@ -4,4 +4,6 @@ type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	// Code is the machine readable error code, see package apierror
+	Code string `json:"code,omitempty"`
 }
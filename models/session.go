@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Session struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID              primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RefreshTokenHash    string             `bson:"refresh_token_hash" json:"-"`
+	PreviousRefreshHash string             `bson:"previous_refresh_hash,omitempty" json:"-"`
+	UserAgent           string             `bson:"user_agent" json:"user_agent"`
+	IP                  string             `bson:"ip" json:"ip"`
+	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt          time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt           time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt           *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
@@ -35,4 +35,15 @@ type Transaction struct {
 	Source        string             `bson:"source" json:"source"`
 	Timestamp     time.Time          `bson:"timestamp" json:"timestamp"`
 	Origin        string             `bson:"origin" json:"origin"`
+	ReconciledAt  *time.Time         `bson:"reconciled_at,omitempty" json:"reconciled_at,omitempty"`
+	EditedFields  []string           `bson:"edited_fields,omitempty" json:"edited_fields,omitempty"`
+	ParserVersion string             `bson:"parser_version,omitempty" json:"parser_version,omitempty"`
+	ReparsedAt    *time.Time         `bson:"reparsed_at,omitempty" json:"reparsed_at,omitempty"`
+
+	// Set when the sensitive fields are encrypted; the indexes allow exact-match lookups
+	Encrypted      bool   `bson:"encrypted,omitempty" json:"-"`
+	SenderIndex    string `bson:"sender_index,omitempty" json:"-"`
+	ReceiverIndex  string `bson:"receiver_index,omitempty" json:"-"`
+	ReferenceIndex string `bson:"reference_index,omitempty" json:"-"`
+	RawSMSIndex    string `bson:"raw_sms_index,omitempty" json:"-"`
 }
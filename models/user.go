@@ -7,12 +7,25 @@ import (
 )
 
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    string             `json:"user_id"`
-	Balance   float64            `json:"balance"`
-	Currency  string             `json:"currency"`
-	CreatedAt time.Time          `json:"created_at"`
-	Email     string             `bson:"email" json:"email"`
-	Password  string             `bson:"password" json:"-"`
-	ApiKey    string             `bson:"api_key" json:"api_key"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        string             `json:"user_id"`
+	Balance       float64            `json:"balance"`
+	Currency      string             `json:"currency"`
+	CreatedAt     time.Time          `json:"created_at"`
+	Email         string             `bson:"email" json:"email"`
+	EmailVerified bool               `bson:"email_verified" json:"email_verified"`
+	Password      string             `bson:"password" json:"-"`
+	ApiKey        string             `bson:"api_key" json:"api_key"`
+
+	TOTPEnabled       bool     `bson:"totp_enabled" json:"totp_enabled"`
+	TOTPSecret        string   `bson:"totp_secret,omitempty" json:"-"`
+	TOTPPendingSecret string   `bson:"totp_pending_secret,omitempty" json:"-"`
+	TOTPLastStep      int64    `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodes     []string `bson:"recovery_codes,omitempty" json:"-"`
+
+	// DisabledAt is set while an operator has blocked the account
+	DisabledAt *time.Time `bson:"disabled_at,omitempty" json:"disabled_at,omitempty"`
+
+	DeletionRequestedAt  *time.Time `bson:"deletion_requested_at,omitempty" json:"deletion_requested_at,omitempty"`
+	DeletionScheduledFor *time.Time `bson:"deletion_scheduled_for,omitempty" json:"deletion_scheduled_for,omitempty"`
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WorkspaceMember struct {
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Email  string             `bson:"email" json:"email"`
+	Role   string             `bson:"role" json:"role"`
+	// Accounts are the origins (e.g. MobileMoney) this member shares; empty shares none
+	Accounts []string  `bson:"accounts" json:"accounts"`
+	JoinedAt time.Time `bson:"joined_at" json:"joined_at"`
+}
+
+type Workspace struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"owner_id"`
+	Members   []WorkspaceMember  `bson:"members" json:"members"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type WorkspaceInvite struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WorkspaceID primitive.ObjectID `bson:"workspace_id" json:"workspace_id"`
+	Email       string             `bson:"email" json:"email"`
+	Role        string             `bson:"role" json:"role"`
+	TokenHash   string             `bson:"token_hash" json:"-"`
+	InvitedBy   primitive.ObjectID `bson:"invited_by" json:"invited_by"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt   time.Time          `bson:"expires_at" json:"expires_at"`
+	AcceptedAt  *time.Time         `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+}
@@ -0,0 +1,12 @@
+package notify
+
+import (
+	"mate/stream"
+	"mate/webhooks"
+)
+
+// Publish fans an event out to the user's webhooks and live stream clients
+func Publish(userID, event string, data interface{}) {
+	webhooks.Dispatch(userID, event, data)
+	stream.Publish(userID, event, data)
+}
@@ -0,0 +1,293 @@
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Document is the subset of an OpenAPI 3.0 document the API needs
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema, either inline or a $ref to components
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Param is a query or form parameter; path parameters are taken from the route
+type Param struct {
+	Name        string
+	Description string
+	Type        string // string (default), integer or file
+	Required    bool
+	Enum        []string
+}
+
+// Route describes one endpoint. Request and Response are zero values of the body
+// and data types, their schemas are derived from the json tags.
+type Route struct {
+	Method      string
+	Path        string // fiber syntax, /v1/webhooks/:id
+	OperationID string
+	Summary     string
+	Tag         string
+	Public      bool // no Authorization required
+	Secret      bool // the success body carries credentials, never stored for idempotent replays
+	Query       []Param
+	Form        []Param // multipart/form-data body instead of Request
+	Request     any
+	Response    any
+	Status      int    // success status, 200 when zero
+	Produces    string // media type of a success body that is not the JSON envelope
+}
+
+// Build describes routes in an OpenAPI document. Every JSON success body is the
+// models.Response envelope with the route's Response as data, and every error is
+// the envelope with an error code.
+func Build(info Info, routes []Route) *Document {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: g.schemas,
+			Responses: map[string]*Response{
+				"Error": {
+					Description: "The request failed; branch on code, the message is for people",
+					Content: map[string]*MediaType{
+						"application/json":         {Schema: errorSchema()},
+						"application/problem+json": {Schema: problemSchema()},
+					},
+				},
+			},
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearer": {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "An access token from /login, or the account's API key",
+				},
+			},
+		},
+	}
+
+	for _, route := range routes {
+		path, params := convertPath(route.Path)
+		op := &Operation{
+			OperationID: route.OperationID,
+			Summary:     route.Summary,
+			Parameters:  params,
+			Responses:   map[string]*Response{"default": {Ref: "#/components/responses/Error"}},
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		if !route.Public {
+			op.Security = []map[string][]string{{"bearer": {}}}
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:        "X-Workspace-ID",
+				In:          "header",
+				Description: "Act on a shared workspace instead of the personal ledger",
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+		for _, param := range route.Query {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:        param.Name,
+				In:          "query",
+				Description: param.Description,
+				Required:    param.Required,
+				Schema:      paramSchema(param),
+			})
+		}
+
+		switch {
+		case len(route.Form) > 0:
+			form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			for _, param := range route.Form {
+				form.Properties[param.Name] = paramSchema(param)
+				if param.Required {
+					form.Required = append(form.Required, param.Name)
+				}
+			}
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{"multipart/form-data": {Schema: form}}}
+		case route.Request != nil:
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{"application/json": {Schema: g.schemaOf(route.Request)}}}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &Response{Description: http.StatusText(status)}
+		if route.Produces != "" {
+			success.Content = map[string]*MediaType{route.Produces: {Schema: &Schema{Type: "string", Format: "binary"}}}
+		} else {
+			success.Content = map[string]*MediaType{"application/json": {Schema: envelope(g, route.Response)}}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	return doc
+}
+
+// Operations returns the document's operations sorted by path and method, for generators
+func (d *Document) Operations() []OperationRef {
+	var ops []OperationRef
+	for path, methods := range d.Paths {
+		for method, op := range methods {
+			ops = append(ops, OperationRef{Path: path, Method: strings.ToUpper(method), Operation: op})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops
+}
+
+// OperationRef is an operation with the path and method it is served on
+type OperationRef struct {
+	Path      string
+	Method    string
+	Operation *Operation
+}
+
+// convertPath turns /webhooks/:id into /webhooks/{id} and returns its path parameters
+func convertPath(path string) (string, []*Parameter) {
+	var params []*Parameter
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			name := segment[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func paramSchema(param Param) *Schema {
+	switch param.Type {
+	case "integer":
+		return &Schema{Type: "integer", Enum: param.Enum}
+	case "file":
+		return &Schema{Type: "string", Format: "binary"}
+	default:
+		return &Schema{Type: "string", Enum: param.Enum}
+	}
+}
+
+// envelope is models.Response with data typed as response
+func envelope(g *generator, response any) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"success": {Type: "boolean"}},
+		Required:   []string{"success"},
+	}
+	if response != nil {
+		schema.Properties["data"] = g.schemaOf(response)
+	}
+	return schema
+}
+
+func errorSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"success": {Type: "boolean"},
+			"error":   {Type: "string"},
+			"code":    {Type: "string", Description: "Machine readable error code, e.g. INVALID_INPUT"},
+		},
+		Required: []string{"success", "error", "code"},
+	}
+}
+
+func problemSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"type":       {Type: "string"},
+			"title":      {Type: "string"},
+			"status":     {Type: "integer"},
+			"detail":     {Type: "string"},
+			"instance":   {Type: "string"},
+			"code":       {Type: "string"},
+			"request_id": {Type: "string"},
+		},
+		Required: []string{"type", "title", "status", "code"},
+	}
+}
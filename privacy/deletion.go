@@ -0,0 +1,233 @@
+package privacy
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"mate/apierror"
+	"mate/audit"
+	"mate/config"
+	"mate/lifecycle"
+	"mate/logging"
+	"mate/models"
+	"mate/vault"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var logger = logging.Logger("privacy")
+
+// deletionSweepInterval is how often due deletions and expired exports are processed
+const deletionSweepInterval = time.Hour
+
+var (
+	ErrDeletionPending   = apierror.New(409, apierror.CodeDeletionPending, "Account deletion is already scheduled")
+	ErrNoDeletionPending = apierror.New(409, apierror.CodeNoDeletionPending, "No account deletion is scheduled")
+)
+
+// ScheduleDeletion marks the account for deletion once the grace period has passed
+func ScheduleDeletion(user models.User) (time.Time, error) {
+	if user.DeletionScheduledFor != nil {
+		return *user.DeletionScheduledFor, ErrDeletionPending
+	}
+
+	now := time.Now()
+	scheduledFor := now.Add(config.Current.Privacy.AccountDeletionGrace)
+	_, err := config.UpdateOne(context.Background(), "users", bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"deletion_requested_at":  now,
+			"deletion_scheduled_for": scheduledFor,
+		},
+	})
+	return scheduledFor, err
+}
+
+// CancelDeletion keeps the account
+func CancelDeletion(user models.User) error {
+	if user.DeletionScheduledFor == nil {
+		return ErrNoDeletionPending
+	}
+
+	_, err := config.UpdateOne(context.Background(), "users", bson.M{"_id": user.ID}, bson.M{
+		"$unset": bson.M{
+			"deletion_requested_at":  "",
+			"deletion_scheduled_for": "",
+		},
+	})
+	return err
+}
+
+// StartSweeper periodically purges accounts whose grace period has ended and
+// removes expired export archives
+func StartSweeper() {
+	lifecycle.Go(func() {
+		for {
+			if err := PurgeDueAccounts(); err != nil {
+				logger.Error("error purging accounts", "error", err)
+			}
+			if err := RemoveExpiredExports(); err != nil {
+				logger.Error("error removing expired exports", "error", err)
+			}
+			if !lifecycle.Sleep(deletionSweepInterval) {
+				return
+			}
+		}
+	})
+}
+
+// PurgeDueAccounts deletes every account whose deletion date has passed
+func PurgeDueAccounts() error {
+	var users []models.User
+	if err := findAll("users", bson.M{"deletion_scheduled_for": bson.M{"$lte": time.Now()}}, &users); err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := Purge(user); err != nil {
+			logger.Error("error purging account", "user_id", user.ID.Hex(), "error", err)
+		}
+	}
+	return nil
+}
+
+// Purge removes every document linked to the user. Audit entries are kept for
+// the retention period but stripped of personal details. The user document goes
+// last so an interrupted purge is simply retried on the next sweep.
+func Purge(user models.User) error {
+	userID := user.ID.String()
+
+	// Documents keyed by the string form of the user id
+	for _, target := range []struct {
+		collection string
+		field      string
+	}{
+		{"transactions", "userid"},
+		{"webhooks", "user_id"},
+		{"webhook_deliveries", "user_id"},
+	} {
+		if _, err := config.DeleteMany(context.Background(), target.collection, bson.M{target.field: userID}); err != nil {
+			return err
+		}
+	}
+
+	if err := purgeImports(userID); err != nil {
+		return err
+	}
+
+	var exports []models.DataExport
+	if err := findAll("data_exports", bson.M{"user_id": userID}, &exports); err != nil {
+		return err
+	}
+	for _, job := range exports {
+		removeArchive(job)
+	}
+
+	// Documents keyed by the ObjectID
+	for _, collection := range []string{"sessions", "user_tokens"} {
+		if _, err := config.DeleteMany(context.Background(), collection, bson.M{"user_id": user.ID}); err != nil {
+			return err
+		}
+	}
+
+	if err := purgeWorkspaces(user); err != nil {
+		return err
+	}
+
+	// Idempotency keys are stored under the hex ID, or the public user ID on /consume
+	if _, err := config.DeleteMany(context.Background(), "idempotency_keys", bson.M{"user_id": bson.M{"$in": []string{user.ID.Hex(), user.UserID}}}); err != nil {
+		return err
+	}
+
+	// Documents keyed by email
+	if _, err := config.DeleteMany(context.Background(), "login_attempts", bson.M{"email": user.Email}); err != nil {
+		return err
+	}
+	if _, err := config.DeleteMany(context.Background(), "workspace_invites", bson.M{"email": user.Email}); err != nil {
+		return err
+	}
+	if _, err := config.DeleteOne(context.Background(), "login_throttle", bson.M{"_id": "account:" + strings.ToLower(user.Email)}); err != nil {
+		return err
+	}
+
+	if err := anonymizeAuditLog(user); err != nil {
+		return err
+	}
+
+	// Anything encrypted that survived, e.g. in a backup, becomes unreadable
+	if err := vault.ForgetDataKey(userID); err != nil {
+		return err
+	}
+
+	if _, err := config.DeleteOne(context.Background(), "users", bson.M{"_id": user.ID}); err != nil {
+		return err
+	}
+
+	audit.Record(nil, audit.Entry{
+		Owner:      userID,
+		Action:     audit.ActionAccountDeleted,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+	return nil
+}
+
+func purgeImports(userID string) error {
+	var jobs []models.ImportJob
+	if err := findAll("import_jobs", bson.M{"user_id": userID}, &jobs); err != nil {
+		return err
+	}
+
+	jobIDs := make([]primitive.ObjectID, 0, len(jobs))
+	for _, job := range jobs {
+		jobIDs = append(jobIDs, job.ID)
+	}
+	if len(jobIDs) > 0 {
+		if _, err := config.DeleteMany(context.Background(), "import_items", bson.M{"job_id": bson.M{"$in": jobIDs}}); err != nil {
+			return err
+		}
+	}
+	_, err := config.DeleteMany(context.Background(), "import_jobs", bson.M{"user_id": userID})
+	return err
+}
+
+// purgeWorkspaces deletes the workspaces the user owns and leaves the others
+func purgeWorkspaces(user models.User) error {
+	var owned []models.Workspace
+	if err := findAll("workspaces", bson.M{"owner_id": user.ID}, &owned); err != nil {
+		return err
+	}
+	for _, ws := range owned {
+		if _, err := config.DeleteMany(context.Background(), "workspace_invites", bson.M{"workspace_id": ws.ID}); err != nil {
+			return err
+		}
+		if _, err := config.DeleteOne(context.Background(), "workspaces", bson.M{"_id": ws.ID}); err != nil {
+			return err
+		}
+	}
+
+	_, err := config.UpdateMany(context.Background(), "workspaces", bson.M{"members.user_id": user.ID}, bson.M{
+		"$pull": bson.M{"members": bson.M{"user_id": user.ID}},
+	})
+	return err
+}
+
+func anonymizeAuditLog(user models.User) error {
+	userID := user.ID.String()
+	if _, err := config.UpdateMany(context.Background(), "audit_logs", bson.M{"$or": []bson.M{
+		{"owner_id": userID},
+		{"actor_id": userID},
+	}}, bson.M{
+		"$set":   bson.M{"ip": "", "user_agent": ""},
+		"$unset": bson.M{"actor_email": "", "diff": ""},
+	}); err != nil {
+		return err
+	}
+
+	// Failed logins reference the account by email
+	_, err := config.UpdateMany(context.Background(), "audit_logs", bson.M{"target_id": user.Email}, bson.M{
+		"$set": bson.M{"target_id": "", "ip": "", "user_agent": ""},
+	})
+	return err
+}
@@ -0,0 +1,454 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/export"
+	"mate/lifecycle"
+	"mate/models"
+	"mate/vault"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Export statuses
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+)
+
+// exportTTL is how long a finished archive can be downloaded
+const exportTTL = 7 * 24 * time.Hour
+
+var ErrExportNotReady = apierror.New(409, apierror.CodeExportNotReady, "Export is not ready")
+
+var (
+	exportsMu sync.Mutex
+	exporting = map[primitive.ObjectID]bool{}
+)
+
+// Account summarises one origin (e.g. MobileMoney) the user receives messages from
+type Account struct {
+	Origin           string  `json:"origin"`
+	Transactions     int     `json:"transactions"`
+	FirstDate        string  `json:"first_date"`
+	LastDate         string  `json:"last_date"`
+	LatestBalance    float64 `json:"latest_balance"`
+	latestTimestamp  time.Time
+	latestBalanceSet bool
+}
+
+// Profile is the account as written to user.json. It leaves out the API key and the
+// password, TOTP and recovery code secrets.
+type Profile struct {
+	UserID               string     `json:"user_id"`
+	Email                string     `json:"email"`
+	EmailVerified        bool       `json:"email_verified"`
+	Balance              float64    `json:"balance"`
+	Currency             string     `json:"currency"`
+	CreatedAt            time.Time  `json:"created_at"`
+	TOTPEnabled          bool       `json:"totp_enabled"`
+	DisabledAt           *time.Time `json:"disabled_at,omitempty"`
+	DeletionRequestedAt  *time.Time `json:"deletion_requested_at,omitempty"`
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for,omitempty"`
+}
+
+func profileOf(user models.User) Profile {
+	return Profile{
+		UserID:               user.UserID,
+		Email:                user.Email,
+		EmailVerified:        user.EmailVerified,
+		Balance:              user.Balance,
+		Currency:             user.Currency,
+		CreatedAt:            user.CreatedAt,
+		TOTPEnabled:          user.TOTPEnabled,
+		DisabledAt:           user.DisabledAt,
+		DeletionRequestedAt:  user.DeletionRequestedAt,
+		DeletionScheduledFor: user.DeletionScheduledFor,
+	}
+}
+
+// CreateExport queues a full export of the user's data
+func CreateExport(user models.User) (*models.DataExport, error) {
+	now := time.Now()
+	job := &models.DataExport{
+		ID:           primitive.NewObjectID(),
+		UserID:       user.ID.String(),
+		UserObjectID: user.ID,
+		Status:       ExportPending,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(exportTTL),
+	}
+	if err := config.InsertOne(context.Background(), "data_exports", job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// StartExport builds the archive in the background. Starting a running export is a no-op.
+func StartExport(exportID primitive.ObjectID) {
+	exportsMu.Lock()
+	defer exportsMu.Unlock()
+	if exporting[exportID] {
+		return
+	}
+	exporting[exportID] = true
+
+	lifecycle.Go(func() {
+		defer func() {
+			exportsMu.Lock()
+			delete(exporting, exportID)
+			exportsMu.Unlock()
+		}()
+		if err := runExport(exportID); err != nil {
+			logger.Error("export failed", "export_id", exportID.Hex(), "error", err)
+		}
+	})
+}
+
+// ResumePendingExports restarts exports interrupted by a restart
+func ResumePendingExports() error {
+	cursor, err := config.Find(context.Background(), "data_exports", bson.M{"status": bson.M{"$in": []string{ExportPending, ExportRunning}}}, nil)
+	if err != nil {
+		return err
+	}
+
+	var jobs []models.DataExport
+	if err := cursor.All(context.Background(), &jobs); err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		StartExport(job.ID)
+	}
+	return nil
+}
+
+// OpenExport returns the archive of a completed export owned by user
+func OpenExport(user models.User, exportID primitive.ObjectID) (*models.DataExport, error) {
+	result, err := config.FindOne(context.Background(), "data_exports", bson.M{"_id": exportID, "user_id": user.ID.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	var job models.DataExport
+	if err := result.Decode(&job); err != nil {
+		return nil, err
+	}
+	if job.Status != ExportCompleted || time.Now().After(job.ExpiresAt) {
+		return nil, ErrExportNotReady
+	}
+	return &job, nil
+}
+
+// RemoveExpiredExports deletes archives past their download window
+func RemoveExpiredExports() error {
+	cursor, err := config.Find(context.Background(), "data_exports", bson.M{"expires_at": bson.M{"$lte": time.Now()}}, nil)
+	if err != nil {
+		return err
+	}
+
+	var jobs []models.DataExport
+	if err := cursor.All(context.Background(), &jobs); err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		removeArchive(job)
+	}
+	return nil
+}
+
+func removeArchive(job models.DataExport) {
+	if job.Path != "" {
+		if err := os.Remove(job.Path); err != nil && !os.IsNotExist(err) {
+			logger.Error("error removing export archive", "export_id", job.ID.Hex(), "error", err)
+			return
+		}
+	}
+	if _, err := config.DeleteOne(context.Background(), "data_exports", bson.M{"_id": job.ID}); err != nil {
+		logger.Error("error removing export", "export_id", job.ID.Hex(), "error", err)
+	}
+}
+
+func runExport(exportID primitive.ObjectID) error {
+	result, err := config.FindOne(context.Background(), "data_exports", bson.M{"_id": exportID})
+	if err != nil {
+		return err
+	}
+	var job models.DataExport
+	if err := result.Decode(&job); err != nil {
+		return err
+	}
+
+	setExportStatus(job.ID, bson.M{"status": ExportRunning})
+
+	path, size, err := buildArchive(job)
+	if err != nil {
+		setExportStatus(job.ID, bson.M{"status": ExportFailed, "error": err.Error()})
+		return err
+	}
+
+	now := time.Now()
+	setExportStatus(job.ID, bson.M{
+		"status":       ExportCompleted,
+		"path":         path,
+		"size":         size,
+		"completed_at": now,
+		"expires_at":   now.Add(exportTTL),
+	})
+	return nil
+}
+
+func setExportStatus(id primitive.ObjectID, set bson.M) {
+	if _, err := config.UpdateOne(context.Background(), "data_exports", bson.M{"_id": id}, bson.M{"$set": set}); err != nil {
+		logger.Error("error updating export", "export_id", id.Hex(), "error", err)
+	}
+}
+
+// buildArchive writes the ZIP next to its final name and renames it once complete,
+// so a crash never leaves a half-written archive behind a completed export.
+func buildArchive(job models.DataExport) (string, int64, error) {
+	if err := os.MkdirAll(config.Current.Privacy.DataExportDir, 0o700); err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(config.Current.Privacy.DataExportDir, job.ID.Hex()+".zip")
+	tmp := path + ".tmp"
+
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp)
+
+	if err := writeArchive(file, job); err != nil {
+		file.Close()
+		return "", 0, err
+	}
+	if err := file.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
+
+func writeArchive(w io.Writer, job models.DataExport) error {
+	archive := zip.NewWriter(w)
+
+	result, err := config.FindOne(context.Background(), "users", bson.M{"_id": job.UserObjectID})
+	if err != nil {
+		return err
+	}
+	var user models.User
+	if err := result.Decode(&user); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "user.json", profileOf(user)); err != nil {
+		return err
+	}
+
+	accounts, err := writeTransactions(archive, user)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "accounts.json", accounts); err != nil {
+		return err
+	}
+
+	settings, err := collectSettings(user)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "settings.json", settings); err != nil {
+		return err
+	}
+
+	var auditLog []models.AuditLog
+	if err := findAll("audit_logs", bson.M{"$or": []bson.M{
+		{"owner_id": user.ID.String()},
+		{"actor_id": user.ID.String()},
+	}}, &auditLog); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "audit_log.json", auditLog); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// writeTransactions writes every transaction as both JSON and CSV and summarises
+// the accounts they came from along the way
+func writeTransactions(archive *zip.Writer, user models.User) ([]*Account, error) {
+	jsonFile, err := archive.Create("transactions.json")
+	if err != nil {
+		return nil, err
+	}
+	rows := []models.Transaction{}
+	accounts := map[string]*Account{}
+
+	err = eachTransaction(user, func(tx models.Transaction) error {
+		rows = append(rows, tx)
+		summarise(accounts, tx)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(rows); err != nil {
+		return nil, err
+	}
+
+	csvFile, err := archive.Create("transactions.csv")
+	if err != nil {
+		return nil, err
+	}
+	writer, err := export.NewWriter(export.FormatCSV, csvFile, export.Options{Currency: user.Currency})
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Begin(); err != nil {
+		return nil, err
+	}
+	for _, tx := range rows {
+		if err := writer.Write(tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.End(); err != nil {
+		return nil, err
+	}
+
+	list := make([]*Account, 0, len(accounts))
+	for _, account := range accounts {
+		list = append(list, account)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Origin < list[j].Origin })
+	return list, nil
+}
+
+func eachTransaction(user models.User, fn func(models.Transaction) error) error {
+	cursor, err := config.Find(context.Background(), "transactions", bson.M{"userid": user.ID.String()},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var tx models.Transaction
+		if err := cursor.Decode(&tx); err != nil {
+			return err
+		}
+		if err := vault.OpenTransaction(&tx); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func summarise(accounts map[string]*Account, tx models.Transaction) {
+	account, ok := accounts[tx.Origin]
+	if !ok {
+		account = &Account{Origin: tx.Origin, FirstDate: tx.Date}
+		accounts[tx.Origin] = account
+	}
+	account.Transactions++
+	if tx.Date < account.FirstDate || account.FirstDate == "" {
+		account.FirstDate = tx.Date
+	}
+	if tx.Date > account.LastDate {
+		account.LastDate = tx.Date
+	}
+	if !account.latestBalanceSet || tx.Timestamp.After(account.latestTimestamp) {
+		account.LatestBalance = tx.BalanceAfter
+		account.latestTimestamp = tx.Timestamp
+		account.latestBalanceSet = true
+	}
+}
+
+// collectSettings gathers everything the user configured outside their ledger
+func collectSettings(user models.User) (map[string]interface{}, error) {
+	var webhooks []models.Webhook
+	if err := findAll("webhooks", bson.M{"user_id": user.ID.String()}, &webhooks); err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	var workspaces []models.Workspace
+	if err := findAll("workspaces", bson.M{"members.user_id": user.ID}, &workspaces); err != nil {
+		return nil, err
+	}
+
+	var sessions []models.Session
+	if err := findAll("sessions", bson.M{"user_id": user.ID}, &sessions); err != nil {
+		return nil, err
+	}
+
+	var imports []models.ImportJob
+	if err := findAll("import_jobs", bson.M{"user_id": user.ID.String()}, &imports); err != nil {
+		return nil, err
+	}
+
+	var loginAttempts []models.LoginAttempt
+	if err := findAll("login_attempts", bson.M{"email": user.Email}, &loginAttempts); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"currency":           user.Currency,
+		"two_factor_enabled": user.TOTPEnabled,
+		"webhooks":           webhooks,
+		"workspaces":         workspaces,
+		"sessions":           sessions,
+		"imports":            imports,
+		"login_attempts":     loginAttempts,
+	}, nil
+}
+
+func findAll(collection string, filter bson.M, results interface{}) error {
+	cursor, err := config.Find(context.Background(), collection, filter, nil)
+	if err != nil {
+		return err
+	}
+	return cursor.All(context.Background(), results)
+}
+
+func writeJSON(archive *zip.Writer, name string, value interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
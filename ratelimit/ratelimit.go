@@ -0,0 +1,124 @@
+// Package ratelimit caps requests per route, user and IP, and parser model calls per
+// user and day. Counters live in fixed windows in a Store shared by every instance.
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/logging"
+	"mate/metrics"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var logger = logging.Logger("ratelimit")
+
+var (
+	ErrRateLimited   = apierror.New(429, apierror.CodeRateLimited, "Too many requests, try again later")
+	ErrQuotaExceeded = apierror.New(429, apierror.CodeQuotaExceeded, "Daily parsing quota used up, try again tomorrow")
+)
+
+// Limiter counts hits against limits
+type Limiter struct {
+	store Store
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Default is the limiter used by Middleware and LLMCall, configured by Init
+var Default = NewLimiter(NewMemoryStore())
+
+// Init picks the counter store from config
+func Init() {
+	if config.Current.RateLimit.Store == "memory" {
+		Default = NewLimiter(NewMemoryStore())
+		return
+	}
+	store := NewMongoStore()
+	if err := store.EnsureIndexes(); err != nil {
+		logger.Error("error creating rate limit indexes", "error", err)
+	}
+	Default = NewLimiter(store)
+}
+
+// Allow counts a hit against key and, when more than limit were made in the current
+// window, returns false and how long until the window ends
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (time.Duration, bool) {
+	start := time.Now().Truncate(window)
+	return l.allow(ctx, key+":"+strconv.FormatInt(start.Unix(), 10), limit, start.Add(window))
+}
+
+func (l *Limiter) allow(ctx context.Context, key string, limit int, end time.Time) (time.Duration, bool) {
+	count, err := l.store.Increment(ctx, key, end)
+	if err != nil {
+		// Fail open, a broken store must not take the API down
+		logger.ErrorContext(ctx, "error counting request", "error", err)
+		return 0, true
+	}
+	if count > limit {
+		return time.Until(end), false
+	}
+	return 0, true
+}
+
+// Middleware enforces the configured rules counted by (ip or user) for the route with
+// the given operation ID and the rules for every route. IP rules go before
+// authentication, so they also cap attempts with bad credentials; user rules go after
+// it to see the user, and count public routes by their :userId parameter instead.
+func Middleware(operation, by string) fiber.Handler {
+	var rules []config.RateRule
+	for _, rule := range config.Current.RateLimit.Parsed {
+		if rule.By == by && (rule.Route == operation || rule.Route == "*") {
+			rules = append(rules, rule)
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		for _, rule := range rules {
+			subject := c.IP()
+			if rule.By == "user" {
+				if subject = requestUser(c); subject == "" {
+					continue
+				}
+			}
+
+			key := "route:" + rule.Route + ":" + rule.By + ":" + subject
+			if wait, ok := Default.Allow(c.UserContext(), key, rule.Limit, rule.Window); !ok {
+				metrics.RateLimited(rule.Route, rule.By)
+				return ErrRateLimited.After(wait)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// LLMCall counts one parser model call against the user's daily quota, which resets
+// at local midnight
+func LLMCall(ctx context.Context, userID string) error {
+	quota := config.Current.LLM.DailyQuota
+	if quota == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if wait, ok := Default.allow(ctx, "llm:"+userID+":"+day.Format("2006-01-02"), quota, day.AddDate(0, 0, 1)); !ok {
+		metrics.RateLimited("llm", "user")
+		return ErrQuotaExceeded.After(wait)
+	}
+	return nil
+}
+
+func requestUser(c *fiber.Ctx) string {
+	if user, ok := c.Locals("user").(models.User); ok {
+		return user.ID.Hex()
+	}
+	return c.Params("userId")
+}
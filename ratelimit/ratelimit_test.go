@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testUser = models.User{ID: primitive.NewObjectID()}
+
+// newTestApp serves consume and listWebhooks the way routes.Register mounts them:
+// IP rules, then sign-in, then user rules. Client addresses come from X-Real-IP, which
+// app.Test requests are trusted to set.
+func newTestApp(rules []config.RateRule, fiberConfig fiber.Config) *fiber.App {
+	config.Current.RateLimit.Parsed = rules
+	Default = NewLimiter(NewMemoryStore())
+
+	fiberConfig.ErrorHandler = apierror.Handler
+	app := fiber.New(fiberConfig)
+	signIn := func(c *fiber.Ctx) error {
+		if c.Get("X-Test-User") != "" {
+			c.Locals("user", testUser)
+		}
+		return c.Next()
+	}
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) }
+
+	app.Post("/consume/:userId", Middleware("consume", "ip"), signIn, Middleware("consume", "user"), ok)
+	app.Get("/webhooks", Middleware("listWebhooks", "ip"), signIn, Middleware("listWebhooks", "user"), ok)
+	return app
+}
+
+var trustedProxy = fiber.Config{ProxyHeader: "X-Real-IP", EnableTrustedProxyCheck: true, TrustedProxies: []string{"0.0.0.0"}}
+
+type hit struct {
+	method string
+	path   string
+	ip     string
+	user   bool
+	status int
+}
+
+func TestMiddleware(t *testing.T) {
+	consumeByIP := config.RateRule{Route: "consume", By: "ip", Limit: 2, Window: time.Hour}
+	consumeByUser := config.RateRule{Route: "consume", By: "user", Limit: 2, Window: time.Hour}
+	everyRouteByUser := config.RateRule{Route: "*", By: "user", Limit: 2, Window: time.Hour}
+
+	tests := []struct {
+		name  string
+		rules []config.RateRule
+		hits  []hit
+	}{
+		{"ip limit", []config.RateRule{consumeByIP}, []hit{
+			{"POST", "/consume/u1", "192.0.2.1", false, 200},
+			{"POST", "/consume/u2", "192.0.2.1", false, 200},
+			{"POST", "/consume/u3", "192.0.2.1", false, 429},
+		}},
+		{"ip limits are per address", []config.RateRule{consumeByIP}, []hit{
+			{"POST", "/consume/u1", "192.0.2.1", false, 200},
+			{"POST", "/consume/u1", "192.0.2.1", false, 200},
+			{"POST", "/consume/u1", "192.0.2.2", false, 200},
+		}},
+		{"public routes count the userId parameter", []config.RateRule{consumeByUser}, []hit{
+			{"POST", "/consume/u1", "192.0.2.1", false, 200},
+			{"POST", "/consume/u1", "192.0.2.2", false, 200},
+			{"POST", "/consume/u1", "192.0.2.3", false, 429},
+			{"POST", "/consume/u2", "192.0.2.3", false, 200},
+		}},
+		{"signed-in requests count the user", []config.RateRule{consumeByUser}, []hit{
+			{"POST", "/consume/u1", "192.0.2.1", true, 200},
+			{"POST", "/consume/u2", "192.0.2.2", true, 200},
+			{"POST", "/consume/u3", "192.0.2.3", true, 429},
+		}},
+		{"rules only apply to their route", []config.RateRule{consumeByIP}, []hit{
+			{"GET", "/webhooks", "192.0.2.1", true, 200},
+			{"GET", "/webhooks", "192.0.2.1", true, 200},
+			{"GET", "/webhooks", "192.0.2.1", true, 200},
+		}},
+		{"wildcard rules count every route together", []config.RateRule{everyRouteByUser}, []hit{
+			{"GET", "/webhooks", "192.0.2.1", true, 200},
+			{"POST", "/consume/u1", "192.0.2.1", true, 200},
+			{"GET", "/webhooks", "192.0.2.1", true, 429},
+			{"POST", "/consume/u1", "192.0.2.1", true, 429},
+		}},
+		{"user rules skip requests without a user", []config.RateRule{everyRouteByUser}, []hit{
+			{"GET", "/webhooks", "192.0.2.1", false, 200},
+			{"GET", "/webhooks", "192.0.2.1", false, 200},
+			{"GET", "/webhooks", "192.0.2.1", false, 200},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(tt.rules, trustedProxy)
+			for i, h := range tt.hits {
+				if status := send(t, app, h); status != h.status {
+					t.Fatalf("request %d: got status %d, want %d", i, status, h.status)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewareIgnoresUntrustedProxyHeaders(t *testing.T) {
+	rules := []config.RateRule{{Route: "consume", By: "ip", Limit: 1, Window: time.Hour}}
+	app := newTestApp(rules, fiber.Config{ProxyHeader: "X-Real-IP", EnableTrustedProxyCheck: true, TrustedProxies: []string{"10.0.0.1"}})
+
+	// Every request comes from the same untrusted peer, whatever the header claims
+	if status := send(t, app, hit{"POST", "/consume/u1", "192.0.2.1", false, 0}); status != http.StatusOK {
+		t.Fatalf("got status %d, want %d", status, http.StatusOK)
+	}
+	if status := send(t, app, hit{"POST", "/consume/u1", "192.0.2.2", false, 0}); status != http.StatusTooManyRequests {
+		t.Fatalf("got status %d with a spoofed address, want %d", status, http.StatusTooManyRequests)
+	}
+}
+
+func send(t *testing.T, app *fiber.App, h hit) int {
+	t.Helper()
+	req := httptest.NewRequest(h.method, h.path, nil)
+	req.Header.Set("X-Real-IP", h.ip)
+	if h.user {
+		req.Header.Set("X-Test-User", "1")
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mate/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store counts hits per window. Keys name the window, so a counter is never reset,
+// it is only dropped once expired.
+type Store interface {
+	// Increment counts one hit against key and returns the count including it
+	Increment(ctx context.Context, key string, expiresAt time.Time) (int, error)
+}
+
+// MemoryStore keeps counters in process; limits only hold per instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]memoryCounter
+	lastSweep time.Time
+}
+
+type memoryCounter struct {
+	count     int
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]memoryCounter)}
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, expiresAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, counter := range s.counters {
+			if now.After(counter.expiresAt) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	counter := s.counters[key]
+	counter.count++
+	counter.expiresAt = expiresAt
+	s.counters[key] = counter
+	return counter.count, nil
+}
+
+// MongoStore keeps counters in MongoDB so every instance sees the same limits
+type MongoStore struct {
+	collection string
+}
+
+func NewMongoStore() *MongoStore {
+	return &MongoStore{collection: "rate_limits"}
+}
+
+func (s *MongoStore) Increment(ctx context.Context, key string, expiresAt time.Time) (int, error) {
+	result := config.FindOneAndUpsert(ctx, s.collection, bson.M{"_id": key}, bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expires_at": expiresAt},
+	})
+
+	var counter struct {
+		Count int `bson:"count"`
+	}
+	if err := result.Decode(&counter); err != nil {
+		return 0, err
+	}
+	return counter.Count, nil
+}
+
+// EnsureIndexes creates the TTL index that drops expired counters
+func (s *MongoStore) EnsureIndexes() error {
+	_, err := config.Database.Collection(s.collection).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
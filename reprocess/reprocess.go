@@ -0,0 +1,292 @@
+// Package reprocess re-derives stored SMS transactions with the current parsers, so
+// parser fixes reach transactions ingested before them.
+package reprocess
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mate/audit"
+	"mate/config"
+	"mate/ingest"
+	"mate/logging"
+	"mate/models"
+	"mate/notify"
+	"mate/vault"
+	"mate/webhooks"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var logger = logging.Logger("reprocess")
+
+// Unversioned selects transactions parsed before parser versions were recorded
+const Unversioned = "none"
+
+// Options select the transactions to re-parse; empty fields match everything. From
+// and To are YYYY-MM-DD transaction dates, both included.
+type Options struct {
+	UserID        string
+	Origin        string
+	From          string
+	To            string
+	ParserVersion string
+	// DryRun reports what would change without storing anything
+	DryRun bool
+}
+
+// Change is a field the current parsers derive differently
+type Change struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+	// Kept is set when the user corrected the field by hand, so it is not changed
+	Kept bool `json:"kept,omitempty"`
+}
+
+// Result is the outcome for one transaction
+type Result struct {
+	Transaction models.Transaction
+	Changes     []Change
+	Err         error
+}
+
+// Report counts what a run did
+type Report struct {
+	Matched   int `json:"matched"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// pageSize is how many transactions are read at a time
+const pageSize = 100
+
+// Run re-parses the selected transactions one at a time and calls onResult with each
+// outcome. Changed fields are stored unless the user edited them; every transaction
+// that parsed records the parser version, so a rerun with the same selection by
+// version skips it.
+func Run(ctx context.Context, opts Options, onResult func(Result)) (Report, error) {
+	var report Report
+
+	owners := map[string]models.User{}
+	after := primitive.NilObjectID
+	for {
+		page, err := nextPage(ctx, opts, after)
+		if err != nil || len(page) == 0 {
+			return report, err
+		}
+		after = page[len(page)-1].ID
+
+		for _, stored := range page {
+			if err := vault.OpenTransaction(&stored); err != nil {
+				return report, err
+			}
+			report.Matched++
+
+			result := Result{Transaction: stored}
+			owner, err := ownerOf(ctx, stored.UserID, owners)
+			if err == nil {
+				result.Changes, err = reparse(ctx, owner, stored, opts.DryRun)
+			}
+			result.Err = err
+
+			switch {
+			case err != nil:
+				logger.ErrorContext(ctx, "error re-parsing transaction", "transaction_id", stored.ID.Hex(), "error", err)
+				report.Failed++
+			case applied(result.Changes):
+				report.Updated++
+			default:
+				report.Unchanged++
+			}
+			if onResult != nil {
+				onResult(result)
+			}
+		}
+	}
+}
+
+// nextPage reads the selected transactions that follow after in _id order. Parser
+// calls are slow, so no cursor is held open while they run.
+func nextPage(ctx context.Context, opts Options, after primitive.ObjectID) ([]models.Transaction, error) {
+	filter := opts.filter()
+	filter["_id"] = bson.M{"$gt": after}
+	cursor, err := config.Find(ctx, "transactions", filter, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(pageSize))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var page []models.Transaction
+	err = cursor.All(ctx, &page)
+	return page, err
+}
+
+func (opts Options) filter() bson.M {
+	filter := bson.M{"source": "sms", "raw_sms": bson.M{"$nin": []interface{}{nil, ""}}}
+	if opts.UserID != "" {
+		filter["userid"] = opts.UserID
+	}
+	if opts.Origin != "" {
+		filter["origin"] = opts.Origin
+	}
+
+	// Dates are stored as YYYY-MM-DD, so they compare as strings
+	dates := bson.M{}
+	if opts.From != "" {
+		dates["$gte"] = opts.From
+	}
+	if opts.To != "" {
+		dates["$lte"] = opts.To
+	}
+	if len(dates) > 0 {
+		filter["date"] = dates
+	}
+
+	switch opts.ParserVersion {
+	case "":
+	case Unversioned:
+		filter["parser_version"] = bson.M{"$exists": false}
+	default:
+		filter["parser_version"] = opts.ParserVersion
+	}
+	return filter
+}
+
+// reparse compares stored with what the parsers derive now and, unless dryRun,
+// stores the changes the user has not overridden
+func reparse(ctx context.Context, owner models.User, stored models.Transaction, dryRun bool) ([]Change, error) {
+	parsed, err := ingest.Reparse(ctx, owner, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := diff(stored, *parsed)
+	if dryRun {
+		return changes, nil
+	}
+
+	now := time.Now()
+	updated := stored
+	set := bson.M{"parser_version": ingest.ParserVersion, "reparsed_at": now}
+	for _, change := range changes {
+		if !change.Kept {
+			set[change.Field] = change.To
+			setField(&updated, change.Field, change.To)
+		}
+	}
+	updated.ParserVersion, updated.ReparsedAt = ingest.ParserVersion, &now
+
+	if err := vault.SealUpdate(stored.UserID, set); err != nil {
+		return nil, err
+	}
+	if _, err := config.UpdateOne(ctx, "transactions", bson.M{"_id": stored.ID}, bson.M{"$set": set}); err != nil {
+		return nil, err
+	}
+
+	if applied(changes) {
+		audit.Record(nil, audit.Entry{
+			Owner:      stored.UserID,
+			Action:     audit.ActionTransactionReparsed,
+			TargetType: audit.TargetTransaction,
+			TargetID:   stored.ID.Hex(),
+			Diff:       audit.Diff(stored, updated),
+		})
+		notify.Publish(stored.UserID, webhooks.EventTransactionUpdated, updated)
+	}
+	return changes, nil
+}
+
+// diff lists the parsed fields whose value changed, by document field name
+func diff(stored, parsed models.Transaction) []Change {
+	fields := map[string][2]interface{}{
+		"type":          {stored.Type, parsed.Type},
+		"amount":        {stored.Amount, parsed.Amount},
+		"fee":           {stored.Fee, parsed.Fee},
+		"tax":           {stored.Tax, parsed.Tax},
+		"balanceafter":  {stored.BalanceAfter, parsed.BalanceAfter},
+		"sender":        {stored.Sender, parsed.Sender},
+		"transactionid": {stored.TransactionID, parsed.TransactionID},
+		"reference":     {stored.Reference, parsed.Reference},
+	}
+
+	var changes []Change
+	for field, values := range fields {
+		if values[0] != values[1] {
+			changes = append(changes, Change{Field: field, From: values[0], To: values[1], Kept: edited(stored, field)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+func setField(tx *models.Transaction, field string, value interface{}) {
+	switch field {
+	case "type":
+		tx.Type = value.(string)
+	case "amount":
+		tx.Amount = value.(float64)
+	case "fee":
+		tx.Fee = value.(float64)
+	case "tax":
+		tx.Tax = value.(float64)
+	case "balanceafter":
+		tx.BalanceAfter = value.(float64)
+	case "sender":
+		tx.Sender = value.(string)
+	case "transactionid":
+		tx.TransactionID = value.(string)
+	case "reference":
+		tx.Reference = value.(string)
+	}
+}
+
+// applied reports whether any change overrides a stored value
+func applied(changes []Change) bool {
+	for _, change := range changes {
+		if !change.Kept {
+			return true
+		}
+	}
+	return false
+}
+
+func edited(transaction models.Transaction, field string) bool {
+	for _, name := range transaction.EditedFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerOf loads the user a transaction belongs to. Transactions store the owner's
+// ObjectID in its String form, ObjectID("<hex>").
+func ownerOf(ctx context.Context, userID string, cache map[string]models.User) (models.User, error) {
+	if user, ok := cache[userID]; ok {
+		return user, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(strings.TrimSuffix(strings.TrimPrefix(userID, `ObjectID("`), `")`))
+	if err != nil {
+		return models.User{}, fmt.Errorf("invalid owner %q", userID)
+	}
+	result, err := config.FindOne(ctx, "users", bson.M{"_id": id})
+	if err != nil {
+		return models.User{}, fmt.Errorf("owner %s: %w", id.Hex(), err)
+	}
+	var user models.User
+	if err := result.Decode(&user); err != nil {
+		return models.User{}, err
+	}
+	cache[userID] = user
+	return user, nil
+}
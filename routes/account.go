@@ -0,0 +1,170 @@
+package routes
+
+import (
+	"errors"
+	"time"
+
+	"mate/apierror"
+	"mate/audit"
+	"mate/auth"
+	"mate/config"
+	"mate/dto"
+	"mate/logging"
+	"mate/mailer"
+	"mate/models"
+	"mate/privacy"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AccountHandler struct{}
+
+func NewAccountHandler() *AccountHandler {
+	return &AccountHandler{}
+}
+
+// CreateExport starts building a ZIP archive of everything stored about the user
+func (h *AccountHandler) CreateExport(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	job, err := privacy.CreateExport(user)
+	if err != nil {
+		return apierror.Internal(err, "Error creating export")
+	}
+	privacy.StartExport(job.ID)
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionDataExportRequested,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	return c.Status(202).JSON(models.Response{
+		Success: true,
+		Data:    dto.FromDataExport(*job),
+	})
+}
+
+// ListExports returns the user's exports, newest first
+func (h *AccountHandler) ListExports(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(20)
+	cursor, err := config.Find(c.UserContext(), "data_exports", bson.M{"user_id": user.ID.String()}, findOptions)
+	if err != nil {
+		return apierror.Internal(err, "Error fetching exports")
+	}
+	defer cursor.Close(c.Context())
+
+	exports := []models.DataExport{}
+	if err = cursor.All(c.Context(), &exports); err != nil {
+		return apierror.Internal(err, "Error decoding exports")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromDataExports(exports),
+	})
+}
+
+// DownloadExport sends a completed export archive
+func (h *AccountHandler) DownloadExport(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apierror.InvalidID("Invalid export ID")
+	}
+
+	job, err := privacy.OpenExport(user, id)
+	if errors.Is(err, privacy.ErrExportNotReady) {
+		return err
+	}
+	if err != nil {
+		return apierror.New(404, apierror.CodeExportNotFound, "Export not found")
+	}
+
+	return c.Download(job.Path, "mate-export-"+job.CreatedAt.Format("2006-01-02")+".zip")
+}
+
+// RequestDeletion schedules the account for deletion after the grace period.
+// The password, and a second factor when enabled, confirm the request.
+func (h *AccountHandler) RequestDeletion(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var input dto.ReauthRequest
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		return apierror.New(401, apierror.CodeInvalidCredentials, "Invalid credentials")
+	}
+	if user.TOTPEnabled {
+		if err := auth.VerifySecondFactor(user, input.Code, input.RecoveryCode); err != nil {
+			return apierror.New(401, apierror.CodeInvalidCredentials, "Invalid credentials")
+		}
+	}
+
+	scheduledFor, err := privacy.ScheduleDeletion(user)
+	if errors.Is(err, privacy.ErrDeletionPending) {
+		return err
+	}
+	if err != nil {
+		return apierror.Internal(err, "Error scheduling deletion")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionDeletionRequested,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	if err := mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Mate account will be deleted",
+		Body: "We received a request to delete your Mate account.\n\n" +
+			"Your account and all of its data will be permanently deleted on " +
+			scheduledFor.Format("2 January 2006") + ".\n" +
+			"Sign in before then to cancel the deletion.",
+	}); err != nil {
+		logging.FromCtx(c).Error("error sending deletion email", "error", err)
+	}
+
+	return c.Status(202).JSON(models.Response{
+		Success: true,
+		Data: dto.DeletionSchedule{
+			DeletionScheduledFor: scheduledFor.Format(time.RFC3339),
+		},
+	})
+}
+
+// CancelDeletion keeps an account that is scheduled for deletion
+func (h *AccountHandler) CancelDeletion(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	err := privacy.CancelDeletion(user)
+	if errors.Is(err, privacy.ErrNoDeletionPending) {
+		return err
+	}
+	if err != nil {
+		return apierror.Internal(err, "Error cancelling deletion")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionDeletionCancelled,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
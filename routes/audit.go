@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"mate/apierror"
+	"mate/config"
+	"mate/dto"
+	"mate/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditHandler struct{}
+
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+// List returns audit entries about the user or performed by them, newest first
+func (h *AuditHandler) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	userID := user.ID.String()
+
+	filter := bson.M{"$or": []bson.M{
+		{"owner_id": userID},
+		{"actor_id": userID},
+	}}
+	for _, field := range []string{"action", "target_type", "target_id"} {
+		if value := c.Query(field); value != "" {
+			filter[field] = value
+		}
+	}
+
+	createdAt := bson.M{}
+	for param, operator := range map[string]string{"from": "$gte", "to": "$lte"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return apierror.BadRequest("Invalid " + param + " time, use RFC3339")
+		}
+		createdAt[operator] = at
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	// Page backwards with the id of the last entry seen
+	if before := c.Query("before"); before != "" {
+		id, err := primitive.ObjectIDFromHex(before)
+		if err != nil {
+			return apierror.BadRequest("Invalid before cursor")
+		}
+		filter["_id"] = bson.M{"$lt": id}
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 200
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := config.Find(c.UserContext(), "audit_logs", filter, findOptions)
+	if err != nil {
+		return apierror.Internal(err, "Error fetching audit log")
+	}
+	defer cursor.Close(c.Context())
+
+	entries := []models.AuditLog{}
+	if err = cursor.All(c.Context(), &entries); err != nil {
+		return apierror.Internal(err, "Error decoding audit log")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromAuditLogs(entries),
+	})
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/export"
+	"mate/logging"
+	"mate/models"
+	"mate/vault"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Export streams the filtered transactions as CSV, OFX or QIF
+func (h *TransactionHandler) Export(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	format := strings.ToLower(c.Query("format", export.FormatCSV))
+	if !export.IsSupported(format) {
+		return apierror.New(400, apierror.CodeUnsupportedFormat, "Unsupported export format")
+	}
+
+	filter := transactionFilter(c)
+
+	// OFX needs the statement period before the first row is written
+	start, end, err := transactionPeriod(c.UserContext(), filter)
+	if err != nil {
+		return apierror.Internal(err, "Error fetching transactions")
+	}
+
+	cursor, err := config.Find(c.UserContext(), "transactions", filter, options.Find().SetSort(byDate(1)))
+	if err != nil {
+		return apierror.Internal(err, "Error fetching transactions")
+	}
+
+	filename := fmt.Sprintf("mate-transactions-%s.%s", time.Now().Format("20060102"), format)
+	c.Set("Content-Type", export.ContentType(format))
+	c.Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	opts := export.Options{
+		Currency:  user.Currency,
+		AccountID: user.UserID,
+		Start:     start,
+		End:       end,
+	}
+
+	// The request context is released once streaming starts
+	logger := logging.FromCtx(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx := context.Background()
+		defer cursor.Close(ctx)
+
+		writer, err := export.NewWriter(format, w, opts)
+		if err != nil {
+			logger.Error("error creating export writer", "error", err)
+			return
+		}
+		if err := writer.Begin(); err != nil {
+			return
+		}
+
+		for cursor.Next(ctx) {
+			var tx models.Transaction
+			if err := cursor.Decode(&tx); err != nil {
+				logger.Error("error decoding transaction", "error", err)
+				w.Flush()
+				return
+			}
+			if err := vault.OpenTransaction(&tx); err != nil {
+				logger.Error("error decrypting transaction", "error", err)
+				w.Flush()
+				return
+			}
+			if err := writer.Write(tx); err != nil {
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		// Every failure leaves the file unterminated, so a failed read or a row that
+		// cannot be decrypted shows as a broken download rather than a complete-looking one
+		if err := cursor.Err(); err != nil {
+			logger.Error("error reading transactions", "error", err)
+			w.Flush()
+			return
+		}
+
+		if err := writer.End(); err != nil {
+			return
+		}
+		w.Flush()
+	})
+
+	return nil
+}
+
+// byDate orders transactions by the day they took place, then by when they were stored
+func byDate(direction int) bson.D {
+	return bson.D{{Key: "date", Value: direction}, {Key: "timestamp", Value: direction}}
+}
+
+// Helper function to find the dates of the oldest and newest matching transactions
+func transactionPeriod(ctx context.Context, filter bson.M) (time.Time, time.Time, error) {
+	var bounds [2]time.Time
+	for i, direction := range []int{1, -1} {
+		cursor, err := config.Find(ctx, "transactions", filter, options.Find().
+			SetSort(byDate(direction)).
+			SetLimit(1).
+			SetProjection(bson.M{"date": 1, "timestamp": 1}))
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		var transactions []models.Transaction
+		if err := cursor.All(context.Background(), &transactions); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if len(transactions) == 0 {
+			now := time.Now()
+			return now, now, nil
+		}
+		bounds[i] = export.Posted(transactions[0])
+	}
+	return bounds[0], bounds[1], nil
+}
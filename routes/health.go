@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mate/config"
+	"mate/importer"
+	"mate/lifecycle"
+	"mate/utils"
+	"mate/webhooks"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// llmCheckInterval limits how often readiness probes call out to the LLM provider
+const llmCheckInterval = 30 * time.Second
+
+type HealthHandler struct {
+	llmMu        sync.Mutex
+	llmCheckedAt time.Time
+	llmErr       error
+}
+
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{}
+}
+
+type healthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Live reports that the process is up; it never checks dependencies
+func (h *HealthHandler) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Ready reports whether the instance should receive traffic: it is not shutting
+// down, MongoDB answers and the background queue is not backed up. The LLM provider
+// is reported but does not fail readiness, every instance depends on it equally.
+func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 2*time.Second)
+	defer cancel()
+
+	ready := true
+	checks := fiber.Map{}
+
+	if !lifecycle.Ready() {
+		ready = false
+		checks["shutdown"] = healthCheck{Status: "fail", Error: "shutting down"}
+	}
+
+	if err := config.Ping(ctx); err != nil {
+		ready = false
+		checks["mongodb"] = healthCheck{Status: "fail", Error: err.Error()}
+	} else {
+		checks["mongodb"] = healthCheck{Status: "ok"}
+	}
+
+	if err := h.checkLLM(ctx); err != nil {
+		checks["llm"] = healthCheck{Status: "degraded", Error: err.Error()}
+	} else {
+		checks["llm"] = healthCheck{Status: "ok"}
+	}
+
+	if depth, err := queueDepth(ctx); err != nil {
+		checks["queue"] = healthCheck{Status: "fail", Error: err.Error()}
+	} else {
+		queue := fiber.Map{"status": "ok", "depth": depth}
+		if limit := config.Current.Server.MaxQueueDepth; limit > 0 && depth > limit {
+			ready = false
+			queue["status"] = "fail"
+		}
+		checks["queue"] = queue
+	}
+
+	status, code := "ok", fiber.StatusOK
+	if !ready {
+		status, code = "unavailable", fiber.StatusServiceUnavailable
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// queueDepth is the work waiting to be done: import items not yet processed and webhook
+// deliveries still being retried
+func queueDepth(ctx context.Context) (int, error) {
+	items, err := importer.Backlog(ctx)
+	if err != nil {
+		return 0, err
+	}
+	deliveries, err := webhooks.Backlog(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return items + deliveries, nil
+}
+
+// checkLLM pings the LLM provider, reusing the last result for llmCheckInterval
+func (h *HealthHandler) checkLLM(ctx context.Context) error {
+	h.llmMu.Lock()
+	defer h.llmMu.Unlock()
+
+	if time.Since(h.llmCheckedAt) < llmCheckInterval {
+		return h.llmErr
+	}
+	h.llmErr = utils.PingLLM(ctx)
+	h.llmCheckedAt = time.Now()
+	return h.llmErr
+}
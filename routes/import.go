@@ -0,0 +1,199 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"mime/multipart"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/dto"
+	"mate/importer"
+	"mate/ingest"
+	"mate/logging"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ImportHandler struct{}
+
+func NewImportHandler() *ImportHandler {
+	return &ImportHandler{}
+}
+
+// Create accepts an SMS backup (XML/JSON) or bank CSV statement and queues it as a background job
+func (h *ImportHandler) Create(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return apierror.New(400, apierror.CodeInvalidFile, "File is required")
+	}
+
+	format := c.FormValue("format")
+	if format == "" {
+		format = importer.DetectFormat(fileHeader.Filename)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return apierror.New(400, apierror.CodeInvalidFile, "Error reading file")
+	}
+	defer file.Close()
+
+	var job *models.ImportJob
+	switch format {
+	case importer.FormatSMSXML, importer.FormatSMSJSON:
+		job, err = createSMSJob(user, format, fileHeader.Filename, file)
+	case importer.FormatCSV:
+		origin := c.FormValue("origin")
+		if !ingest.IsKnownSender(origin) {
+			return apierror.BadRequest("Invalid origin")
+		}
+		job, err = createStatementJob(user, fileHeader.Filename, origin, file)
+	default:
+		return apierror.New(400, apierror.CodeUnsupportedFormat, "Unsupported import format")
+	}
+
+	if err != nil {
+		logging.FromCtx(c).Error("error importing file", "error", err)
+		return apierror.New(422, apierror.CodeInvalidFile, "Error importing file: "+err.Error())
+	}
+
+	importer.Start(job.ID)
+
+	return c.Status(202).JSON(models.Response{
+		Success: true,
+		Data:    dto.FromImportJob(*job),
+	})
+}
+
+func createSMSJob(user models.User, format, filename string, file multipart.File) (*models.ImportJob, error) {
+	parse := importer.ParseSMSBackupXML
+	if format == importer.FormatSMSJSON {
+		parse = importer.ParseSMSBackupJSON
+	}
+
+	messages, skipped, err := parse(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, errors.New("no messages from known senders")
+	}
+	return importer.CreateSMSJob(user, format, filename, messages, skipped)
+}
+
+func createStatementJob(user models.User, filename, origin string, file multipart.File) (*models.ImportJob, error) {
+	rows, skipped, err := importer.ParseStatementCSV(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no transactions found in statement")
+	}
+	return importer.CreateStatementJob(user, filename, origin, rows, skipped)
+}
+
+func (h *ImportHandler) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	cursor, err := config.Find(c.UserContext(), "import_jobs", bson.M{"user_id": user.ID.String()}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(50))
+	if err != nil {
+		return apierror.Internal(err, "Error fetching imports")
+	}
+	defer cursor.Close(c.Context())
+
+	jobs := []models.ImportJob{}
+	if err = cursor.All(c.Context(), &jobs); err != nil {
+		return apierror.Internal(err, "Error decoding imports")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromImportJobs(jobs),
+	})
+}
+
+// Report returns the job with the reasons every failed item was rejected
+func (h *ImportHandler) Report(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	job, err := findImportJob(c.UserContext(), c.Params("id"), user)
+	if err != nil {
+		return importJobError(c, err)
+	}
+
+	cursor, err := config.Find(c.UserContext(), "import_items", bson.M{"job_id": job.ID, "status": importer.ItemFailed},
+		options.Find().SetSort(bson.D{{Key: "index", Value: 1}}).SetLimit(500))
+	if err != nil {
+		return apierror.Internal(err, "Error fetching import items")
+	}
+	defer cursor.Close(c.Context())
+
+	failures := []models.ImportItem{}
+	if err = cursor.All(c.Context(), &failures); err != nil {
+		return apierror.Internal(err, "Error decoding import items")
+	}
+	for i := range failures {
+		if err := importer.OpenItem(job, &failures[i]); err != nil {
+			logging.FromCtx(c).Error("error decrypting import item", "error", err)
+		}
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromImportReport(*job, failures),
+	})
+}
+
+// Resume restarts an interrupted or failed job from its first unprocessed item
+func (h *ImportHandler) Resume(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	job, err := findImportJob(c.UserContext(), c.Params("id"), user)
+	if err != nil {
+		return importJobError(c, err)
+	}
+
+	if job.Status == importer.JobCompleted {
+		return apierror.New(409, apierror.CodeImportCompleted, "Import already completed")
+	}
+
+	importer.Start(job.ID)
+
+	return c.Status(202).JSON(models.Response{
+		Success: true,
+		Data:    dto.FromImportJob(*job),
+	})
+}
+
+func findImportJob(ctx context.Context, id string, user models.User) (*models.ImportJob, error) {
+	jobID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	result, err := config.FindOne(ctx, "import_jobs", bson.M{"_id": jobID, "user_id": user.ID.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	var job models.ImportJob
+	if err := result.Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func importJobError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return apierror.New(404, apierror.CodeImportNotFound, "Import not found")
+	}
+	return apierror.Internal(err, "Error fetching import")
+}
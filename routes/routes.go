@@ -0,0 +1,296 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"mate/apierror"
+	"mate/audit"
+	"mate/dto"
+	"mate/idempotency"
+	"mate/metrics"
+	"mate/middleware"
+	"mate/openapi"
+	"mate/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiVersion is the version reported in the OpenAPI document
+const apiVersion = "1.0.0"
+
+// route is an endpoint together with its OpenAPI description. Register and Spec both
+// read the same table, so the served document can't drift from the mounted routes.
+// Paths are relative to the version prefix.
+type route struct {
+	openapi.Route
+	handler fiber.Handler
+}
+
+// version is a prefix the route table is mounted under. A deprecated version keeps
+// working, but its responses carry Deprecation, Sunset and Link headers pointing to
+// the same route under Successor.
+type version struct {
+	Prefix     string
+	Deprecated bool
+	Sunset     time.Time // zero when no removal date is set
+	Successor  string
+}
+
+// versions are mounted oldest first and the last one is documented by Spec. To
+// release /v2, append it and mark /v1 deprecated with /v2 as its successor.
+var versions = []version{
+	{Prefix: "/v1"},
+}
+
+// legacyPrefix is where the authenticated routes lived before versioning. The
+// unversioned paths still work but are deprecated in favour of the first version.
+const legacyPrefix = "/api"
+
+// transactionQuery are the filters read by transactionFilter
+var transactionQuery = []openapi.Param{
+	{Name: "type", Description: "credit or debit", Enum: []string{"credit", "debit"}},
+	{Name: "date", Description: "Day of the transaction, YYYY-MM-DD"},
+	{Name: "counterparty", Description: "Exact sender or receiver"},
+}
+
+func table() []route {
+	user := NewUserHandler()
+	session := NewSessionHandler()
+	twoFactor := NewTwoFactorHandler()
+	transaction := NewTransactionHandler()
+	webhook := NewWebhookHandler()
+	stream := NewStreamHandler()
+	imports := NewImportHandler()
+	statements := NewStatementHandler()
+	workspaces := NewWorkspaceHandler()
+	audits := NewAuditHandler()
+	account := NewAccountHandler()
+
+	return []route{
+		// Public routes
+		{openapi.Route{Method: fiber.MethodPost, Path: "/register", OperationID: "register", Summary: "Create an account", Tag: "auth", Public: true, Secret: true,
+			Request: dto.Credentials{}, Response: dto.Registration{}}, user.Register},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/login", OperationID: "login", Summary: "Sign in, or get an MFA token when two-factor is enabled", Tag: "auth", Public: true, Secret: true,
+			Request: dto.Credentials{}, Response: dto.LoginResult{}}, user.Login},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/login/2fa", OperationID: "loginTwoFactor", Summary: "Complete a login with a TOTP or recovery code", Tag: "auth", Public: true, Secret: true,
+			Request: dto.TwoFactorLoginRequest{}, Response: dto.LoginResult{}}, user.LoginTwoFactor},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/auth/refresh", OperationID: "refreshSession", Summary: "Exchange a refresh token for a new token pair", Tag: "auth", Public: true, Secret: true,
+			Request: dto.RefreshRequest{}, Response: dto.Tokens{}}, session.Refresh},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/verify-email", OperationID: "verifyEmail", Summary: "Confirm an email address", Tag: "auth", Public: true,
+			Request: dto.TokenRequest{}}, user.VerifyEmail},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/password/forgot", OperationID: "forgotPassword", Summary: "Send a password reset link", Tag: "auth", Public: true,
+			Request: dto.EmailRequest{}, Response: dto.Message{}}, user.ForgotPassword},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/password/reset", OperationID: "resetPassword", Summary: "Set a new password with a reset token", Tag: "auth", Public: true,
+			Request: dto.ResetPasswordRequest{}}, user.ResetPassword},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/consume/:userId", OperationID: "consume", Summary: "Parse and store a forwarded SMS", Tag: "transactions", Public: true,
+			Request: dto.ConsumeRequest{}, Response: dto.ConsumeResult{}}, transaction.Consume},
+
+		// Sessions and account security
+		{openapi.Route{Method: fiber.MethodGet, Path: "/auth/sessions", OperationID: "listSessions", Summary: "List active sessions", Tag: "auth",
+			Response: []dto.Session{}}, session.List},
+		{openapi.Route{Method: fiber.MethodGet, Path: "/auth/login-attempts", OperationID: "listLoginAttempts", Summary: "List recent login attempts", Tag: "auth",
+			Response: []dto.LoginAttempt{}}, session.LoginAttempts},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/verify-email/resend", OperationID: "resendVerification", Summary: "Send the verification email again", Tag: "auth"}, user.ResendVerification},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/2fa/enroll", OperationID: "enrollTwoFactor", Summary: "Generate a TOTP secret", Tag: "auth", Secret: true,
+			Response: dto.TOTPEnrollment{}}, twoFactor.Enroll},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/2fa/confirm", OperationID: "confirmTwoFactor", Summary: "Enable two-factor with the first code", Tag: "auth", Secret: true,
+			Request: dto.CodeRequest{}, Response: dto.RecoveryCodes{}}, twoFactor.Confirm},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/2fa/disable", OperationID: "disableTwoFactor", Summary: "Turn two-factor off", Tag: "auth",
+			Request: dto.ReauthRequest{}}, twoFactor.Disable},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/2fa/recovery-codes", OperationID: "regenerateRecoveryCodes", Summary: "Replace the recovery codes", Tag: "auth", Secret: true,
+			Request: dto.RecoveryCodesRequest{}, Response: dto.RecoveryCodes{}}, twoFactor.RegenerateRecoveryCodes},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/auth/logout", OperationID: "logout", Summary: "End the current session", Tag: "auth",
+			Request: dto.RefreshRequest{}}, session.Logout},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/auth/logout-all", OperationID: "logoutAll", Summary: "End every session", Tag: "auth",
+			Response: dto.RevokedSessions{}}, session.LogoutAll},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/user/api-key/rotate", OperationID: "rotateAPIKey", Summary: "Replace the API key", Tag: "auth", Secret: true,
+			Response: dto.APIKey{}}, user.RotateAPIKey},
+		{openapi.Route{Method: fiber.MethodGet, Path: "/audit", OperationID: "listAuditLog", Summary: "List audit entries, newest first", Tag: "account",
+			Query: []openapi.Param{
+				{Name: "action", Description: "Only this action, e.g. " + audit.ActionLogin},
+				{Name: "target_type"},
+				{Name: "target_id"},
+				{Name: "from", Description: "RFC3339 time"},
+				{Name: "to", Description: "RFC3339 time"},
+				{Name: "before", Description: "Id of the last entry seen, for paging"},
+				{Name: "limit", Type: "integer", Description: "At most 200, 50 by default"},
+			},
+			Response: []dto.AuditLog{}}, audits.List},
+
+		// Data export and deletion
+		{openapi.Route{Method: fiber.MethodGet, Path: "/account/exports", OperationID: "listExports", Summary: "List data exports", Tag: "account",
+			Response: []dto.DataExport{}}, account.ListExports},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/account/exports", OperationID: "createExport", Summary: "Start a data export", Tag: "account",
+			Response: dto.DataExport{}, Status: fiber.StatusAccepted}, account.CreateExport},
+		{openapi.Route{Method: fiber.MethodGet, Path: "/account/exports/:id/download", OperationID: "downloadExport", Summary: "Download a finished export", Tag: "account",
+			Produces: "application/zip"}, account.DownloadExport},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/account/deletion", OperationID: "requestDeletion", Summary: "Schedule the account for deletion", Tag: "account",
+			Request: dto.ReauthRequest{}, Response: dto.DeletionSchedule{}, Status: fiber.StatusAccepted}, account.RequestDeletion},
+		{openapi.Route{Method: fiber.MethodDelete, Path: "/account/deletion", OperationID: "cancelDeletion", Summary: "Cancel a scheduled deletion", Tag: "account"}, account.CancelDeletion},
+
+		// Workspaces
+		{openapi.Route{Method: fiber.MethodGet, Path: "/workspaces", OperationID: "listWorkspaces", Summary: "List the workspaces the user belongs to", Tag: "workspaces",
+			Response: []dto.Workspace{}}, workspaces.List},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/workspaces", OperationID: "createWorkspace", Summary: "Create a workspace", Tag: "workspaces",
+			Request: dto.WorkspaceRequest{}, Response: dto.Workspace{}}, workspaces.Create},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/workspaces/invites/accept", OperationID: "acceptInvite", Summary: "Join a workspace with an invite token", Tag: "workspaces",
+			Request: dto.TokenRequest{}, Response: dto.InviteAcceptance{}}, workspaces.AcceptInvite},
+		{openapi.Route{Method: fiber.MethodGet, Path: "/workspaces/:id", OperationID: "getWorkspace", Summary: "Get a workspace", Tag: "workspaces",
+			Response: dto.Workspace{}}, workspaces.Get},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/workspaces/:id/invites", OperationID: "inviteMember", Summary: "Invite someone by email", Tag: "workspaces",
+			Request: dto.InviteRequest{}, Response: dto.WorkspaceInvite{}}, workspaces.Invite},
+		{openapi.Route{Method: fiber.MethodPut, Path: "/workspaces/:id/accounts", OperationID: "shareAccounts", Summary: "Choose which accounts the workspace sees", Tag: "workspaces",
+			Request: dto.AccountsRequest{}, Response: dto.SharedAccounts{}}, workspaces.ShareAccounts},
+		{openapi.Route{Method: fiber.MethodPatch, Path: "/workspaces/:id/members/:userId", OperationID: "updateMember", Summary: "Change a member's role", Tag: "workspaces",
+			Request: dto.RoleRequest{}}, workspaces.UpdateMember},
+		{openapi.Route{Method: fiber.MethodDelete, Path: "/workspaces/:id/members/:userId", OperationID: "removeMember", Summary: "Remove a member, or leave the workspace", Tag: "workspaces"}, workspaces.RemoveMember},
+
+		// Transactions
+		{openapi.Route{Method: fiber.MethodGet, Path: "/transaction", OperationID: "getTransactions", Summary: "Transactions with dashboard statistics", Tag: "transactions",
+			Query: transactionQuery, Response: dto.TransactionAnalysis{}}, transaction.GetTransactions},
+		{openapi.Route{Method: fiber.MethodGet, Path: "/transactions/export", OperationID: "exportTransactions", Summary: "Download transactions as CSV, OFX or QIF", Tag: "transactions",
+			Query: append([]openapi.Param{{Name: "format", Enum: []string{"csv", "ofx", "qif"}}}, transactionQuery...), Produces: "application/octet-stream"}, transaction.Export},
+		{openapi.Route{Method: fiber.MethodPatch, Path: "/transaction/:id", OperationID: "updateTransaction", Summary: "Correct fields of a parsed transaction", Tag: "transactions",
+			Request: dto.TransactionUpdate{}, Response: dto.Transaction{}}, transaction.UpdateTransaction},
+		{openapi.Route{Method: fiber.MethodGet, Path: "/stream", OperationID: "streamEvents", Summary: "Server-sent events for transactions, jobs and alerts", Tag: "transactions",
+			Query: []openapi.Param{
+				{Name: "last_event_id", Description: "Resume after this event, like the Last-Event-ID header"},
+				{Name: "access_token", Description: "Session access token, for clients that cannot set headers"},
+			}, Produces: "text/event-stream"}, stream.Events},
+
+		// Imports
+		{openapi.Route{Method: fiber.MethodGet, Path: "/imports", OperationID: "listImports", Summary: "List import jobs", Tag: "imports",
+			Response: []dto.ImportJob{}}, imports.List},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/imports", OperationID: "createImport", Summary: "Queue an SMS backup or CSV statement", Tag: "imports",
+			Form: []openapi.Param{
+				{Name: "file", Type: "file", Required: true},
+				{Name: "format", Description: "Detected from the file name when empty", Enum: []string{"sms_xml", "sms_json", "csv"}},
+				{Name: "origin", Description: "Sender the CSV statement belongs to"},
+			},
+			Response: dto.ImportJob{}, Status: fiber.StatusAccepted}, imports.Create},
+		{openapi.Route{Method: fiber.MethodGet, Path: "/imports/:id", OperationID: "getImport", Summary: "Import job with its failures", Tag: "imports",
+			Response: dto.ImportReport{}}, imports.Report},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/imports/:id/resume", OperationID: "resumeImport", Summary: "Resume an interrupted import", Tag: "imports",
+			Response: dto.ImportJob{}, Status: fiber.StatusAccepted}, imports.Resume},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/statements", OperationID: "importStatement", Summary: "Reconcile a PDF e-statement", Tag: "imports",
+			Form: []openapi.Param{
+				{Name: "file", Type: "file", Required: true},
+				{Name: "origin", Required: true, Description: "Sender the statement belongs to"},
+				{Name: "layout", Description: "Detected from the text when empty"},
+			},
+			Response: dto.StatementReport{}}, statements.Import},
+
+		// Webhooks
+		{openapi.Route{Method: fiber.MethodGet, Path: "/webhooks", OperationID: "listWebhooks", Summary: "List webhooks and the supported events", Tag: "webhooks",
+			Response: dto.WebhookList{}}, webhook.List},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/webhooks", OperationID: "createWebhook", Summary: "Register a webhook; the secret is only returned here", Tag: "webhooks", Secret: true,
+			Request: dto.WebhookRequest{}, Response: dto.Webhook{}}, webhook.Create},
+		{openapi.Route{Method: fiber.MethodDelete, Path: "/webhooks/:id", OperationID: "deleteWebhook", Summary: "Delete a webhook", Tag: "webhooks"}, webhook.Delete},
+		{openapi.Route{Method: fiber.MethodGet, Path: "/webhooks/:id/deliveries", OperationID: "listDeliveries", Summary: "List deliveries of a webhook", Tag: "webhooks",
+			Query: []openapi.Param{{Name: "status", Description: "pending, succeeded or failed"}}, Response: []dto.WebhookDelivery{}}, webhook.Deliveries},
+		{openapi.Route{Method: fiber.MethodPost, Path: "/webhooks/deliveries/:id/redeliver", OperationID: "redeliver", Summary: "Send a delivery again", Tag: "webhooks",
+			Response: dto.WebhookDelivery{}}, webhook.Redeliver},
+	}
+}
+
+// Register mounts every API version, the deprecated unversioned paths and the OpenAPI
+// document on app. Routes that are not public go through the auth and workspace
+// middleware, every route through the rate limits configured for it and mutating
+// routes through the idempotency middleware.
+func Register(app *fiber.App) {
+	authenticate, scope := middleware.Auth(), middleware.Workspace()
+	mount := func(path string, r route, deprecation fiber.Handler) {
+		handlers := []fiber.Handler{ratelimit.Middleware(r.OperationID, "user")}
+		if r.Method != fiber.MethodGet && !r.Secret {
+			handlers = append(handlers, idempotency.Middleware(r.OperationID))
+		}
+		handlers = append(handlers, r.handler)
+		if !r.Public {
+			handlers = append([]fiber.Handler{authenticate, scope}, handlers...)
+		}
+		handlers = append([]fiber.Handler{ratelimit.Middleware(r.OperationID, "ip")}, handlers...)
+		if deprecation != nil {
+			handlers = append([]fiber.Handler{deprecation}, handlers...)
+		}
+		app.Add(r.Method, path, handlers...)
+	}
+
+	app.Get("/openapi.json", serveSpec)
+	for _, r := range table() {
+		for _, v := range versions {
+			var deprecation fiber.Handler
+			if v.Deprecated {
+				deprecation = deprecated(v.Prefix, v.Prefix, v.Successor, v.Sunset)
+			}
+			mount(v.Prefix+r.Path, r, deprecation)
+		}
+
+		oldPrefix := ""
+		if !r.Public {
+			oldPrefix = legacyPrefix
+		}
+		mount(oldPrefix+r.Path, r, deprecated("unversioned", oldPrefix, versions[0].Prefix, time.Time{}))
+	}
+}
+
+// deprecated marks responses of a deprecated route (RFC 8594 and RFC 9745) and counts
+// its use. The successor link swaps prefix for successor in the requested path.
+func deprecated(label, prefix, successor string, sunset time.Time) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set("Deprecation", "true")
+		if !sunset.IsZero() {
+			c.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		if successor != "" {
+			c.Append(fiber.HeaderLink, "<"+successor+strings.TrimPrefix(c.Path(), prefix)+`>; rel="successor-version"`)
+		}
+		metrics.DeprecatedRequest(label, c.Route().Path)
+		return c.Next()
+	}
+}
+
+// Spec describes the routes of the current API version
+func Spec() *openapi.Document {
+	current := versions[len(versions)-1]
+
+	var routes []openapi.Route
+	for _, r := range table() {
+		documented := r.Route
+		documented.Path = current.Prefix + r.Path
+		routes = append(routes, documented)
+	}
+	return openapi.Build(openapi.Info{
+		Title:       "Mate API",
+		Version:     apiVersion,
+		Description: "Track mobile money and bank transactions parsed from SMS.",
+	}, routes)
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+	specErr  error
+)
+
+func serveSpec(c *fiber.Ctx) error {
+	specOnce.Do(func() {
+		specJSON, specErr = json.Marshal(Spec())
+	})
+	if specErr != nil {
+		return specErr
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(specJSON)
+}
+
+// Helper function to parse a JSON body into a request DTO and validate it
+func parseBody(c *fiber.Ctx, input dto.Request) error {
+	if err := c.BodyParser(input); err != nil {
+		return apierror.BadRequest("Invalid input")
+	}
+	return input.Validate()
+}
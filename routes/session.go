@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"errors"
+	"strings"
+
+	"mate/apierror"
+	"mate/audit"
+	"mate/auth"
+	"mate/config"
+	"mate/dto"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SessionHandler struct{}
+
+func NewSessionHandler() *SessionHandler {
+	return &SessionHandler{}
+}
+
+// Refresh exchanges a refresh token for a new token pair
+func (h *SessionHandler) Refresh(c *fiber.Ctx) error {
+	var input dto.RefreshRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	tokens, err := auth.Refresh(input.RefreshToken, c.Get("User-Agent"), c.IP())
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSigningKey) {
+			return apierror.Internal(err, "Error refreshing session")
+		}
+		return apierror.New(401, apierror.CodeSessionExpired, "Invalid or expired refresh token")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromTokens(tokens),
+	})
+}
+
+// Logout ends the current session, or the session of the refresh token in the body
+// when called with an API key
+func (h *SessionHandler) Logout(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	sessionID, ok := c.Locals("session_id").(primitive.ObjectID)
+	if !ok {
+		var input dto.RefreshRequest
+		_ = c.BodyParser(&input)
+
+		sessionHex, _, _ := strings.Cut(input.RefreshToken, ".")
+		id, err := primitive.ObjectIDFromHex(sessionHex)
+		if err != nil {
+			return apierror.New(400, apierror.CodeNoSession, "No session to log out")
+		}
+		sessionID = id
+	}
+
+	// Only the owner may end a session
+	if count, err := config.CountDocuments(c.UserContext(), "sessions", bson.M{"_id": sessionID, "user_id": user.ID}); err != nil || count == 0 {
+		return apierror.New(404, apierror.CodeSessionNotFound, "Session not found")
+	}
+
+	if err := auth.RevokeSession(sessionID); err != nil {
+		return apierror.Internal(err, "Error ending session")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionLogout,
+		TargetType: audit.TargetSession,
+		TargetID:   sessionID.Hex(),
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
+
+// LogoutAll ends every session of the user
+func (h *SessionHandler) LogoutAll(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	revoked, err := auth.RevokeAllSessions(user.ID)
+	if err != nil {
+		return apierror.Internal(err, "Error ending sessions")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionLogoutAll,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data: dto.RevokedSessions{
+			Revoked: revoked,
+		},
+	})
+}
+
+// List returns the user's active sessions
+func (h *SessionHandler) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	filter := bson.M{"user_id": user.ID, "revoked_at": bson.M{"$exists": false}}
+	cursor, err := config.Find(c.UserContext(), "sessions", filter, options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}}))
+	if err != nil {
+		return apierror.Internal(err, "Error fetching sessions")
+	}
+	defer cursor.Close(c.Context())
+
+	sessions := []models.Session{}
+	if err = cursor.All(c.Context(), &sessions); err != nil {
+		return apierror.Internal(err, "Error decoding sessions")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromSessions(sessions),
+	})
+}
+
+// LoginAttempts returns the recent login attempts against the user's email
+func (h *SessionHandler) LoginAttempts(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(100)
+	cursor, err := config.Find(c.UserContext(), "login_attempts", bson.M{"email": user.Email}, findOptions)
+	if err != nil {
+		return apierror.Internal(err, "Error fetching login attempts")
+	}
+	defer cursor.Close(c.Context())
+
+	attempts := []models.LoginAttempt{}
+	if err = cursor.All(c.Context(), &attempts); err != nil {
+		return apierror.Internal(err, "Error decoding login attempts")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromLoginAttempts(attempts),
+	})
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"io"
+
+	"mate/apierror"
+	"mate/dto"
+	"mate/ingest"
+	"mate/logging"
+	"mate/models"
+	"mate/statement"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type StatementHandler struct{}
+
+func NewStatementHandler() *StatementHandler {
+	return &StatementHandler{}
+}
+
+// Import reads a PDF e-statement and reconciles its rows with the SMS-derived transactions
+func (h *StatementHandler) Import(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	origin := c.FormValue("origin")
+	if !ingest.IsKnownSender(origin) {
+		return apierror.BadRequest("Invalid origin")
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return apierror.New(400, apierror.CodeInvalidFile, "File is required")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return apierror.New(400, apierror.CodeInvalidFile, "Error reading file")
+	}
+	defer file.Close()
+
+	pdf, err := io.ReadAll(file)
+	if err != nil {
+		return apierror.New(400, apierror.CodeInvalidFile, "Error reading file")
+	}
+
+	// Extract text locally, statements never leave the server
+	lines, err := statement.ExtractText(pdf)
+	if err != nil {
+		logging.FromCtx(c).Error("error reading statement", "error", err)
+		return apierror.New(422, apierror.CodeInvalidFile, "Error reading statement: "+err.Error())
+	}
+
+	layout := statement.DetectLayout(c.FormValue("layout"), lines)
+	if layout == nil {
+		return apierror.New(400, apierror.CodeUnsupportedFormat, "Unsupported statement layout")
+	}
+
+	rows := layout.Parse(lines)
+	if len(rows) == 0 {
+		return apierror.New(422, apierror.CodeEmptyStatement, "No transactions found in statement")
+	}
+
+	report := statement.Import(c.UserContext(), user, origin, layout.Name(), rows)
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromStatementReport(report),
+	})
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"mate/apierror"
+	"mate/lifecycle"
+	"mate/logging"
+	"mate/models"
+	"mate/stream"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type StreamHandler struct {
+	hub *stream.Hub
+}
+
+func NewStreamHandler() *StreamHandler {
+	return &StreamHandler{hub: stream.Default}
+}
+
+// Events streams transactions, ingestion job updates and alerts as server-sent events.
+// Clients resume by sending the Last-Event-ID header (or last_event_id query parameter).
+func (h *StreamHandler) Events(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	userId := user.ID.String()
+
+	lastEventID := c.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var since uint64
+	if lastEventID != "" {
+		var err error
+		since, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return apierror.BadRequest("Invalid last event id")
+		}
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	replay, events, cancel := h.hub.Subscribe(userId, since)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		// Tell the browser how long to wait before reconnecting
+		fmt.Fprint(w, "retry: 3000\n\n")
+		for _, event := range replay {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case <-lifecycle.Draining().Done():
+				// Let the server shut down; clients reconnect to another instance
+				return
+			}
+
+			// A failed flush means the client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+var streamLogger = logging.Logger("stream")
+
+func writeEvent(w *bufio.Writer, event stream.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		streamLogger.Error("error marshaling event", "event", event.Type, "error", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -1,19 +1,24 @@
 package routes
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"mate/apierror"
+	"mate/audit"
 	"mate/config"
+	"mate/dto"
+	"mate/ingest"
+	"mate/logging"
 	"mate/models"
-	"mate/utils"
+	"mate/notify"
+	"mate/users"
+	"mate/vault"
+	"mate/webhooks"
+	"mate/workspace"
 	"math"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -24,231 +29,82 @@ func NewTransactionHandler() *TransactionHandler {
 }
 
 func (h *TransactionHandler) Consume(c *fiber.Ctx) error {
-	var input struct {
-		Message string `json:"message"`
-		Time    string `json:"time"`
-		Sender  string `json:"sender"`
-	}
+	var input dto.ConsumeRequest
 
 	// Parse request body
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Invalid input",
-		})
-	}
-
-	if input.Sender != "MobileMoney" && input.Sender != "ATMoney" && input.Sender != "Fidelity" {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Invalid sender",
-		})
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	var transaction models.Transaction
-
 	userId := c.Params("userId")
 	filter := bson.M{"userid": userId}
-	user, err := config.FindOne("users", filter)
+	user, err := config.FindOne(c.UserContext(), "users", filter)
 	if err != nil {
-		log.Println(err)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "User not found",
-		})
+		logging.FromCtx(c).Error("error finding user", "error", err)
+		return apierror.New(404, apierror.CodeUserNotFound, "User not found")
 	}
 
 	var userx models.User
 	err = user.Decode(&userx)
 	if err != nil {
-		log.Println(err)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error decoding user",
-		})
+		return apierror.Internal(err, "Error decoding user")
 	}
-
-	transaction.UserID = userx.ID.String()
-
-	// parse sms
-	transactionLLM, err := utils.ExtractEntitiesFromSMS(input.Message)
-	if err != nil {
-		log.Println(err)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error parsing message",
-		})
+	if userx.DisabledAt != nil {
+		return users.ErrDisabled
 	}
 
-	escapedJSON := transactionLLM.Choices[0].Message.Content
-
-	cleanJSON := strings.Replace(escapedJSON, "\\n", "", -1)
-	cleanJSON = strings.Replace(cleanJSON, ".deep array", "", -1)
-
-	if !strings.HasSuffix(cleanJSON, "}") {
-		cleanJSON += "}"
-	}
-
-	// Remove unnecessary text using regex
-	re := regexp.MustCompile(`"receiver":\s*"(.*?)\s*Current Balance:.*?"`)
-	cleanJSON = re.ReplaceAllString(cleanJSON, `"receiver": "$1"`)
-
-	var transactionx models.LLMTransaction
-
-	err = json.Unmarshal([]byte(cleanJSON), &transactionx)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Println(cleanJSON)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error turning LLM response to Struct",
-		})
-	}
-
-	amount, err := utils.ConvertCurrencyToFloat(transactionx.Amount)
-	if err != nil {
-		log.Println(err)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error converting amount to number",
-		})
-	}
-
-	parsedMsg := utils.ParseTransaction(input.Message)
-
-	fmt.Println(parsedMsg)
-
-	transaction.Amount = amount
-	transaction.Sender = transactionx.CounterParty
-	transaction.TransactionID = transactionx.TransactionID
-	transaction.Type = string(parsedMsg.Type)
-	transaction.RawSMS = input.Message
-	transaction.Reference = transactionx.Reference
-	transaction.Timestamp = time.Now()
-	transaction.Origin = input.Sender
-	transaction.Date = input.Time
-
-	transaction.Fee, err = utils.ConvertCurrencyToFloat(transactionx.Fee)
-	if err != nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error converting fee to number",
-		})
-	}
-
-	transaction.Tax, err = utils.ConvertCurrencyToFloat(transactionx.Tax)
-	if err != nil {
-		fmt.Println(transactionx)
-		fmt.Println(err)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error converting tax to number",
-		})
-	}
-
-	transaction.BalanceAfter, err = utils.ConvertCurrencyToFloat(transactionx.Balance)
+	// Parse and store the message
+	transaction, err := ingest.Process(c.UserContext(), userx, ingest.Message{
+		Body:   input.Message,
+		Time:   input.Time,
+		Sender: input.Sender,
+	})
 	if err != nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error converting balance to number",
-		})
+		return err
 	}
 
-	// Check for duplicate transaction
-	existingTransactionFilter := bson.M{"transactionid": transactionx.TransactionID}
-	existingTransaction, err := config.FindOne("transactions", existingTransactionFilter)
-	if err == nil && existingTransaction != nil {
-		fmt.Println(transactionx.TransactionID)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Duplicate transaction",
-		})
-	}
-
-	err = config.InsertOne("transactions", transaction)
-	if err != nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error saving transaction",
-		})
-	}
+	audit.Record(c, audit.Entry{
+		Actor:      &userx,
+		Action:     audit.ActionTransactionCreated,
+		TargetType: audit.TargetTransaction,
+		TargetID:   transaction.ID.Hex(),
+	})
 
 	return c.JSON(models.Response{
 		Success: true,
-		Data: fiber.Map{
-			"message": "Message parsed successfully",
-			"success": true,
+		Data: dto.ConsumeResult{
+			Message: "Message parsed successfully",
+			Success: true,
 		},
 	})
 }
 
 func (h *TransactionHandler) GetTransactions(c *fiber.Ctx) error {
-	user := c.Locals("user").(models.User)
-	userId := user.ID.String()
-
-	// Parse query parameters for type and date
-	transactionType := c.Query("type")
-	date := c.Query("date")
-
 	// Get time periods
 	now := time.Now()
 	today := now.Format("2006-01-02")
 	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
 
 	// Create a filter for the transactions
-	filter := bson.M{"userid": userId}
-	if transactionType != "" {
-		filter["type"] = transactionType
-	}
-	if date != "" {
-		filter["date"] = date
-	}
+	filter := transactionFilter(c)
 
 	// Get all transactions
-	cursor, err := config.Find("transactions", filter, options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}))
+	cursor, err := config.Find(c.UserContext(), "transactions", filter, options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}))
 	if err != nil {
-		log.Println(err)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error fetching transactions",
-		})
+		return apierror.Internal(err, "Error fetching transactions")
 	}
 	defer cursor.Close(c.Context())
 
 	var transactions []models.Transaction
 	if err = cursor.All(c.Context(), &transactions); err != nil {
-		log.Println(err)
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Error decoding transactions",
-		})
-	}
-
-	// Initialize analysis structures
-	type HourlyStats struct {
-		Hour         string  `json:"hour"`
-		Transactions int     `json:"transactions"`
-		Volume       float64 `json:"volume"`
-	}
-
-	type UserStats struct {
-		Name   string  `json:"name"`
-		Amount float64 `json:"value"`
-		Type   string  `json:"type"`
-	}
-
-	type DailyStats struct {
-		Date    string  `json:"date"`
-		Credits float64 `json:"credits"`
-		Debits  float64 `json:"debits"`
-		Balance float64 `json:"balance"`
-		NetFlow float64 `json:"netFlow"`
+		return apierror.Internal(err, "Error decoding transactions")
 	}
+	undecryptable := vault.OpenTransactions(transactions)
 
 	// Initialize analysis maps
-	hourlyStats := make(map[string]*HourlyStats)
-	userStats := make(map[string]*UserStats)
-	dailyStats := make(map[string]*DailyStats)
+	hourlyStats := make(map[string]*dto.HourlyStats)
+	userStats := make(map[string]*dto.UserStats)
+	dailyStats := make(map[string]*dto.DailyStats)
 	origins := make(map[string]int)
 
 	// Initialize calculation variables
@@ -307,7 +163,7 @@ func (h *TransactionHandler) GetTransactions(c *fiber.Ctx) error {
 		// Hourly statistics
 		hour := tx.Timestamp.Format("15:00")
 		if _, exists := hourlyStats[hour]; !exists {
-			hourlyStats[hour] = &HourlyStats{Hour: hour}
+			hourlyStats[hour] = &dto.HourlyStats{Hour: hour}
 		}
 		hourlyStats[hour].Transactions++
 		hourlyStats[hour].Volume += tx.Amount
@@ -318,13 +174,13 @@ func (h *TransactionHandler) GetTransactions(c *fiber.Ctx) error {
 			userKey = tx.Receiver
 		}
 		if _, exists := userStats[userKey]; !exists {
-			userStats[userKey] = &UserStats{Name: userKey, Type: tx.Type}
+			userStats[userKey] = &dto.UserStats{Name: userKey, Type: tx.Type}
 		}
 		userStats[userKey].Amount += tx.Amount
 
 		// Daily statistics
 		if _, exists := dailyStats[tx.Date]; !exists {
-			dailyStats[tx.Date] = &DailyStats{Date: tx.Date}
+			dailyStats[tx.Date] = &dto.DailyStats{Date: tx.Date}
 		}
 		if tx.Type == "credit" {
 			dailyStats[tx.Date].Credits += tx.Amount
@@ -348,76 +204,197 @@ func (h *TransactionHandler) GetTransactions(c *fiber.Ctx) error {
 	balanceChange := calculatePercentageChange(finalBalance, previousBalance)
 
 	// Convert maps to slices for JSON
-	hourlyStatsSlice := make([]HourlyStats, 0, len(hourlyStats))
+	hourlyStatsSlice := make([]dto.HourlyStats, 0, len(hourlyStats))
 	for _, stats := range hourlyStats {
 		hourlyStatsSlice = append(hourlyStatsSlice, *stats)
 	}
 
-	userStatsSlice := make([]UserStats, 0, len(userStats))
+	userStatsSlice := make([]dto.UserStats, 0, len(userStats))
 	for _, stats := range userStats {
 		userStatsSlice = append(userStatsSlice, *stats)
 	}
 
-	dailyStatsSlice := make([]DailyStats, 0, len(dailyStats))
+	dailyStatsSlice := make([]dto.DailyStats, 0, len(dailyStats))
 	for _, stats := range dailyStats {
 		dailyStatsSlice = append(dailyStatsSlice, *stats)
 	}
 
 	// Prepare origin data for charts
-	originData := make([]map[string]interface{}, 0)
+	originData := make([]dto.OriginStats, 0, len(origins))
 	for origin, count := range origins {
-		originData = append(originData, map[string]interface{}{
-			"name":       origin,
-			"value":      count,
-			"percentage": float64(count) / float64(len(transactions)) * 100,
+		originData = append(originData, dto.OriginStats{
+			Name:       origin,
+			Value:      count,
+			Percentage: float64(count) / float64(len(transactions)) * 100,
 		})
 	}
 
 	// Return comprehensive analysis
 	return c.JSON(models.Response{
 		Success: true,
-		Data: fiber.Map{
-			"transactions": transactions,
-			"basicStats": fiber.Map{
-				"totalTransactions": len(transactions),
-				"netFlow": fiber.Map{
-					"amount":           finalBalance,
-					"percentageChange": balanceChange,
-				},
-				"income": fiber.Map{
-					"amount":           totalCredit,
-					"percentageChange": creditChange,
-				},
-				"expense": fiber.Map{
-					"amount":           totalDebit,
-					"percentageChange": debitChange,
-				},
+		Data: dto.TransactionAnalysis{
+			Transactions:  dto.FromTransactions(transactions),
+			Undecryptable: undecryptable,
+			BasicStats: dto.BasicStats{
+				TotalTransactions: len(transactions),
+				NetFlow:           dto.AmountChange{Amount: finalBalance, PercentageChange: balanceChange},
+				Income:            dto.AmountChange{Amount: totalCredit, PercentageChange: creditChange},
+				Expense:           dto.AmountChange{Amount: totalDebit, PercentageChange: debitChange},
 			},
-			"transactionAnalysis": fiber.Map{
-				"averageTransaction": (totalCredit + totalDebit) / float64(len(transactions)),
-				"maxTransaction":     maxAmount,
-				"minTransaction":     minAmount,
-				"maxBalance":         maxBalance,
-				"minBalance":         minBalance,
-				"totalFees":          totalFees,
-				"totalTax":           totalTax,
+			TransactionAnalysis: dto.TransactionStats{
+				AverageTransaction: (totalCredit + totalDebit) / float64(len(transactions)),
+				MaxTransaction:     maxAmount,
+				MinTransaction:     minAmount,
+				MaxBalance:         maxBalance,
+				MinBalance:         minBalance,
+				TotalFees:          totalFees,
+				TotalTax:           totalTax,
 			},
-			"timeAnalysis": fiber.Map{
-				"hourlyStats": hourlyStatsSlice,
-				"dailyStats":  dailyStatsSlice,
+			TimeAnalysis: dto.TimeAnalysis{
+				HourlyStats: hourlyStatsSlice,
+				DailyStats:  dailyStatsSlice,
 			},
-			"userAnalysis": fiber.Map{
-				"userStats":   userStatsSlice,
-				"uniqueUsers": len(userStats),
+			UserAnalysis: dto.UserAnalysis{
+				UserStats:   userStatsSlice,
+				UniqueUsers: len(userStats),
 			},
-			"originAnalysis": fiber.Map{
-				"origins":       originData,
-				"primaryOrigin": getMostFrequentOrigin(origins),
+			OriginAnalysis: dto.OriginAnalysis{
+				Origins:       originData,
+				PrimaryOrigin: getMostFrequentOrigin(origins),
 			},
 		},
 	})
 }
 
+// UpdateTransaction corrects fields of a parsed transaction. Edited fields are
+// remembered so later re-parsing keeps the user's corrections.
+func (h *TransactionHandler) UpdateTransaction(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	scope := requestScope(c)
+	if !workspace.CanEdit(scope.Role) {
+		return apierror.New(403, apierror.CodeInsufficientRole, "Insufficient workspace role")
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apierror.InvalidID("Invalid transaction ID")
+	}
+
+	var input dto.TransactionUpdate
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	filter := scope.TransactionFilter()
+	filter["_id"] = id
+	result, err := config.FindOne(c.UserContext(), "transactions", filter)
+	if err != nil {
+		return apierror.New(404, apierror.CodeTransactionNotFound, "Transaction not found")
+	}
+	var before models.Transaction
+	if err := result.Decode(&before); err == nil {
+		err = vault.OpenTransaction(&before)
+	}
+	if err != nil {
+		return apierror.Internal(err, "Error decoding transaction")
+	}
+
+	after := before
+	set := bson.M{}
+	if input.Type != nil {
+		after.Type, set["type"] = *input.Type, *input.Type
+	}
+	if input.Amount != nil {
+		after.Amount, set["amount"] = *input.Amount, *input.Amount
+	}
+	if input.Fee != nil {
+		after.Fee, set["fee"] = *input.Fee, *input.Fee
+	}
+	if input.Tax != nil {
+		after.Tax, set["tax"] = *input.Tax, *input.Tax
+	}
+	if input.Sender != nil {
+		after.Sender, set["sender"] = *input.Sender, *input.Sender
+	}
+	if input.Receiver != nil {
+		after.Receiver, set["receiver"] = *input.Receiver, *input.Receiver
+	}
+	if input.Reference != nil {
+		after.Reference, set["reference"] = *input.Reference, *input.Reference
+	}
+	if input.Date != nil {
+		after.Date, set["date"] = *input.Date, *input.Date
+	}
+	if len(set) == 0 {
+		return apierror.BadRequest("No fields to update")
+	}
+
+	edited := before.EditedFields
+	for field := range set {
+		if !containsString(edited, field) {
+			edited = append(edited, field)
+		}
+	}
+	after.EditedFields = edited
+	set["edited_fields"] = edited
+
+	if err := vault.SealUpdate(before.UserID, set); err != nil {
+		return apierror.Internal(err, "Error updating transaction")
+	}
+
+	if _, err := config.UpdateOne(c.UserContext(), "transactions", bson.M{"_id": id}, bson.M{"$set": set}); err != nil {
+		return apierror.Internal(err, "Error updating transaction")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Owner:      before.UserID,
+		Action:     audit.ActionTransactionUpdated,
+		TargetType: audit.TargetTransaction,
+		TargetID:   id.Hex(),
+		Diff:       audit.Diff(before, after),
+	})
+	notify.Publish(before.UserID, webhooks.EventTransactionUpdated, after)
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromTransaction(after),
+	})
+}
+
+// Helper function to build the transaction filter for the active scope from the type, date and counterparty query parameters
+func transactionFilter(c *fiber.Ctx) bson.M {
+	filter := requestScope(c).TransactionFilter()
+	if transactionType := c.Query("type"); transactionType != "" {
+		filter["type"] = transactionType
+	}
+	if date := c.Query("date"); date != "" {
+		filter["date"] = date
+	}
+	if counterparty := c.Query("counterparty"); counterparty != "" {
+		filter = bson.M{"$and": []bson.M{filter, vault.CounterpartyFilter(counterparty)}}
+	}
+	return filter
+}
+
+// Helper function to get the ledger scope set by the workspace middleware
+func requestScope(c *fiber.Ctx) workspace.Scope {
+	if scope, ok := c.Locals("scope").(workspace.Scope); ok {
+		return scope
+	}
+	return workspace.Personal(c.Locals("user").(models.User))
+}
+
+// Helper function to check whether a slice contains a value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // Helper function to calculate percentage change
 func calculatePercentageChange(current, previous float64) float64 {
 	if previous == 0 {
@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"time"
+
+	"mate/apierror"
+	"mate/audit"
+	"mate/auth"
+	"mate/config"
+	"mate/dto"
+	"mate/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer        = "Mate"
+	recoveryCodeCount = 10
+)
+
+type TwoFactorHandler struct{}
+
+func NewTwoFactorHandler() *TwoFactorHandler {
+	return &TwoFactorHandler{}
+}
+
+// A two-factor login is finished with a signed token, so without signing keys an
+// enabled second factor would lock the user out
+var errTwoFactorUnavailable = apierror.New(409, apierror.CodeConflict, "Two-factor authentication needs SESSION_SIGNING_KEYS to be configured")
+
+// Enroll generates a new TOTP secret; two-factor is only enabled once Confirm succeeds
+func (h *TwoFactorHandler) Enroll(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	if user.TOTPEnabled {
+		return apierror.New(409, apierror.CodeTwoFactorEnabled, "Two-factor authentication is already enabled")
+	}
+	if !auth.SigningEnabled() {
+		return errTwoFactorUnavailable
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return apierror.Internal(err, "Error generating secret")
+	}
+
+	if _, err := config.UpdateOne(c.UserContext(), "users", bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"totp_pending_secret": secret},
+	}); err != nil {
+		return apierror.Internal(err, "Error saving secret")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data: dto.TOTPEnrollment{
+			Secret:     secret,
+			OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+		},
+	})
+}
+
+// Confirm verifies the first code from the authenticator app, enables two-factor and
+// returns the recovery codes, which are only ever shown here
+func (h *TwoFactorHandler) Confirm(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var input dto.CodeRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	if user.TOTPPendingSecret == "" {
+		return apierror.New(409, apierror.CodeTwoFactorNotEnrolled, "Start enrollment first")
+	}
+	if !auth.SigningEnabled() {
+		return errTwoFactorUnavailable
+	}
+
+	step, ok := auth.ValidateTOTP(user.TOTPPendingSecret, input.Code, time.Now())
+	if !ok {
+		return apierror.New(400, apierror.CodeInvalidTwoFactor, "Invalid two-factor code")
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return apierror.Internal(err, "Error generating recovery codes")
+	}
+
+	if _, err := config.UpdateOne(c.UserContext(), "users", bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"totp_enabled":   true,
+			"totp_secret":    user.TOTPPendingSecret,
+			"totp_last_step": step,
+			"recovery_codes": hashes,
+		},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	}); err != nil {
+		return apierror.Internal(err, "Error enabling two-factor authentication")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionTwoFactorEnabled,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data: dto.RecoveryCodes{
+			RecoveryCodes: codes,
+		},
+	})
+}
+
+// Disable turns two-factor off after re-authenticating with the password and a second factor
+func (h *TwoFactorHandler) Disable(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var input dto.ReauthRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	if err := reauthenticate(user, input.Password, input.Code, input.RecoveryCode); err != nil {
+		return apierror.New(401, apierror.CodeInvalidCredentials, "Invalid credentials")
+	}
+
+	if _, err := config.UpdateOne(c.UserContext(), "users", bson.M{"_id": user.ID}, bson.M{
+		"$set":   bson.M{"totp_enabled": false},
+		"$unset": bson.M{"totp_secret": "", "totp_pending_secret": "", "totp_last_step": "", "recovery_codes": ""},
+	}); err != nil {
+		return apierror.Internal(err, "Error disabling two-factor authentication")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionTwoFactorDisabled,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after re-authentication
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var input dto.RecoveryCodesRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	if err := reauthenticate(user, input.Password, input.Code, ""); err != nil {
+		return apierror.New(401, apierror.CodeInvalidCredentials, "Invalid credentials")
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return apierror.Internal(err, "Error generating recovery codes")
+	}
+
+	if _, err := config.UpdateOne(c.UserContext(), "users", bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"recovery_codes": hashes},
+	}); err != nil {
+		return apierror.Internal(err, "Error saving recovery codes")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionRecoveryCodesRotated,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data: dto.RecoveryCodes{
+			RecoveryCodes: codes,
+		},
+	})
+}
+
+// Helper function to confirm the password and second factor before sensitive changes
+func reauthenticate(user models.User, password, code, recoveryCode string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return err
+	}
+	return auth.VerifySecondFactor(user, code, recoveryCode)
+}
@@ -3,14 +3,23 @@ package routes
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"sync"
 	"time"
 
+	"mate/apierror"
+	"mate/audit"
+	"mate/auth"
 	"mate/config"
+	"mate/dto"
+	"mate/logging"
+	"mate/mailer"
 	"mate/models"
+	"mate/users"
+	"mate/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,142 +30,430 @@ func NewUserHandler() *UserHandler {
 }
 
 func (h *UserHandler) Register(c *fiber.Ctx) error {
-	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+	var input dto.Credentials
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	user, err := users.Create(c.UserContext(), input.Email, input.Password)
+	if err != nil {
+		return err
 	}
 
+	audit.Record(c, audit.Entry{
+		Actor:      user,
+		Action:     audit.ActionUserRegistered,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	// Ask the user to confirm the address, registration succeeds even if the email fails
+	if err := sendVerificationEmail(*user); err != nil {
+		logging.FromCtx(c).Error("error sending verification email", "error", err)
+	}
+
+	// Return success response
+	return c.JSON(models.Response{
+		Success: true,
+		Data: dto.Registration{
+			Email:  user.Email,
+			APIKey: user.ApiKey,
+			UserID: user.UserID,
+		},
+	})
+}
+
+func (h *UserHandler) Login(c *fiber.Ctx) error {
+	var input dto.Credentials
+
 	// Parse request body
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Invalid input",
-		})
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	// Check if email already exists
-	filter := bson.M{"email": input.Email}
-	result, err := config.FindOne("users", filter)
+	email := input.Email
+
+	// Find user by email; unknown emails and wrong passwords get the same answer
+	var user *models.User
+	result, err := config.FindOne(c.UserContext(), "users", bson.M{"email": email})
 	if err == nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Email already exists",
-		})
+		user = &models.User{}
+		if err := result.Decode(user); err != nil {
+			return apierror.Internal(err, "Error retrieving user")
+		}
 	}
-	if result.Err() != nil && result.Err() != mongo.ErrNoDocuments {
-		return c.Status(500).JSON(models.Response{
-			Success: false,
-			Error:   "Error checking existing user",
-		})
+
+	// Refuse while the account or IP is cooling down after failed attempts
+	if refused := throttleLogin(c, email, user); refused != nil {
+		return refused
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return c.Status(500).JSON(models.Response{
-			Success: false,
-			Error:   "Error processing request",
+	if user == nil {
+		// Spend the same time as a real password check so timing doesn't reveal accounts
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(input.Password))
+		return loginFailed(c, email, nil, "unknown email")
+	}
+
+	// Check password
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		return loginFailed(c, email, user, "wrong password")
+	}
+
+	if user.DisabledAt != nil {
+		return users.ErrDisabled
+	}
+
+	// With two-factor enabled the session is only issued by LoginTwoFactor
+	if user.TOTPEnabled {
+		mfaToken, err := auth.IssueMFAToken(*user)
+		if err != nil {
+			return apierror.Internal(err, "Error starting two-factor login")
+		}
+
+		return c.JSON(models.Response{
+			Success: true,
+			Data: dto.LoginResult{
+				MFARequired: true,
+				MFAToken:    mfaToken,
+			},
 		})
 	}
 
-	// Generate API key
-	apiKey, err := generateAPIKey()
+	return loginResponse(c, *user)
+}
+
+// LoginTwoFactor completes a login with a TOTP or recovery code
+func (h *UserHandler) LoginTwoFactor(c *fiber.Ctx) error {
+	var input dto.TwoFactorLoginRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	mfaToken, err := auth.ParseMFAToken(input.MFAToken)
 	if err != nil {
-		return c.Status(500).JSON(models.Response{
-			Success: false,
-			Error:   "Error generating API key",
-		})
+		return apierror.New(401, apierror.CodeSessionExpired, "Invalid or expired login, sign in again")
 	}
 
-	// Generate User Unique ID
-	userID, err := GenerateUniqueID()
+	result, err := config.FindOne(c.UserContext(), "users", bson.M{"_id": mfaToken.UserID})
 	if err != nil {
-		return c.Status(500).JSON(models.Response{
-			Success: false,
-			Error:   "Error generating User Id",
-		})
+		return apierror.New(401, apierror.CodeSessionExpired, "Invalid or expired login, sign in again")
 	}
+	var user models.User
+	if err := result.Decode(&user); err != nil {
+		return apierror.Internal(err, "Error retrieving user")
+	}
+
+	if refused := throttleLogin(c, user.Email, &user); refused != nil {
+		return refused
+	}
+	if user.DisabledAt != nil {
+		return users.ErrDisabled
+	}
+
+	if err := auth.VerifySecondFactor(user, input.Code, input.RecoveryCode); err != nil {
+		auth.Guard.Failure(user.Email, c.IP())
+		recordLoginAttempt(c, user.Email, &user, false, "wrong second factor")
+		return apierror.New(400, apierror.CodeInvalidTwoFactor, "Invalid two-factor code")
+	}
+	if err := mfaToken.Redeem(); err != nil {
+		return apierror.New(401, apierror.CodeSessionExpired, "Invalid or expired login, sign in again")
+	}
+
+	return loginResponse(c, user)
+}
 
-	// Create user
-	user := models.User{
-		Email:     input.Email,
-		Password:  string(hashedPassword),
-		ApiKey:    apiKey,
+// Helper function to refuse a login while the account or IP is throttled. owner is
+// the account registered with email, if any.
+func throttleLogin(c *fiber.Ctx, email string, owner *models.User) error {
+	wait, err := auth.Guard.Check(email, c.IP())
+	if err == nil {
+		return nil
+	}
+
+	recordLoginAttempt(c, email, owner, false, err.Error())
+	return apierror.New(429, apierror.CodeRateLimited, "Too many failed attempts, try again later").After(wait)
+}
+
+// Helper function to count and answer a failed login
+func loginFailed(c *fiber.Ctx, email string, owner *models.User, reason string) error {
+	auth.Guard.Failure(email, c.IP())
+	recordLoginAttempt(c, email, owner, false, reason)
+	return apierror.New(401, apierror.CodeInvalidCredentials, "Invalid credentials")
+}
+
+// Helper function to append to the login audit trail. Failed attempts against an
+// existing account are owned by it, so its user sees them in the audit log.
+func recordLoginAttempt(c *fiber.Ctx, email string, owner *models.User, success bool, reason string) {
+	if err := config.InsertOne(c.UserContext(), "login_attempts", models.LoginAttempt{
+		Email:     email,
+		IP:        c.IP(),
+		UserAgent: c.Get("User-Agent"),
+		Success:   success,
+		Reason:    reason,
 		CreatedAt: time.Now(),
-		UserID:    "mate_" + userID,
+	}); err != nil {
+		logging.FromCtx(c).Error("error recording login attempt", "error", err)
 	}
 
-	// Insert new user into database
-	err = config.InsertOne("users", user)
-	if err != nil {
-		return c.Status(500).JSON(models.Response{
-			Success: false,
-			Error:   "Error creating user",
-		})
+	if !success {
+		entry := audit.Entry{
+			Action:     audit.ActionLoginFailed,
+			TargetType: audit.TargetUser,
+			TargetID:   email,
+		}
+		if owner != nil {
+			entry.Owner = owner.ID.String()
+		}
+		audit.Record(c, entry)
+	}
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// Helper function returning a bcrypt hash to compare against when the user doesn't exist
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("mate-dummy-password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
+
+// Helper function to start a session and return the login payload
+func loginResponse(c *fiber.Ctx, user models.User) error {
+	auth.Guard.Success(user.Email)
+	recordLoginAttempt(c, user.Email, &user, true, "")
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionLogin,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
+	data := dto.LoginResult{
+		Email:  user.Email,
+		UserID: user.UserID,
+	}
+
+	// Start a session, unless sessions are not configured and only API keys are in use
+	tokens, err := auth.StartSession(user, c.Get("User-Agent"), c.IP())
+	if err != nil && !errors.Is(err, auth.ErrNoSigningKey) {
+		return apierror.Internal(err, "Error creating session")
+	}
+	if tokens != nil {
+		data.AccessToken = tokens.AccessToken
+		data.RefreshToken = tokens.RefreshToken
+		data.TokenType = tokens.TokenType
+		data.ExpiresIn = tokens.ExpiresIn
 	}
 
 	// Return success response
 	return c.JSON(models.Response{
 		Success: true,
-		Data: fiber.Map{
-			"email":   user.Email,
-			"api_key": user.ApiKey,
-			"user_id": user.UserID,
-		},
+		Data:    data,
 	})
 }
 
-func (h *UserHandler) Login(c *fiber.Ctx) error {
-	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+func (h *UserHandler) VerifyEmail(c *fiber.Ctx) error {
+	var input dto.TokenRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	userID, err := auth.ConsumeUserToken(input.Token, auth.PurposeVerifyEmail)
+	if err != nil {
+		return apierror.New(400, apierror.CodeInvalidToken, "Invalid or expired token")
+	}
+
+	if _, err := config.UpdateOne(c.UserContext(), "users", bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{"email_verified": true},
+	}); err != nil {
+		return apierror.Internal(err, "Error verifying email")
 	}
 
+	audit.Record(c, audit.Entry{
+		Owner:      userID.String(),
+		Action:     audit.ActionEmailVerified,
+		TargetType: audit.TargetUser,
+		TargetID:   userID.Hex(),
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
+
+func (h *UserHandler) ResendVerification(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	if user.EmailVerified {
+		return apierror.New(409, apierror.CodeEmailVerified, "Email already verified")
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		return apierror.Internal(err, "Error sending verification email")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
+
+func (h *UserHandler) ForgotPassword(c *fiber.Ctx) error {
+	var input dto.EmailRequest
+
 	// Parse request body
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Invalid input",
-		})
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	// Find user by email
-	filter := bson.M{"email": input.Email}
-	result, err := config.FindOne("users", filter)
+	// The response is the same whether or not the account exists
+	response := models.Response{
+		Success: true,
+		Data: dto.Message{
+			Message: "If an account exists for this email, a reset link has been sent",
+		},
+	}
+
+	result, err := config.FindOne(c.UserContext(), "users", bson.M{"email": input.Email})
 	if err != nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "User not found",
-		})
+		return c.JSON(response)
+	}
+	var user models.User
+	if err := result.Decode(&user); err != nil {
+		return c.JSON(response)
 	}
 
-	// Check password
+	token, err := auth.IssueUserToken(user.ID, auth.PurposeResetPassword, passwordResetTTL)
+	if err != nil {
+		logging.FromCtx(c).Error("error issuing password reset token", "error", err)
+		return c.JSON(response)
+	}
+
+	if err := mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Mate password",
+		Body: "Someone asked to reset the password for your Mate account.\n\n" +
+			"Use this link within the next hour to choose a new password:\n" +
+			config.Current.Server.AppURL + "/reset-password?token=" + token + "\n\n" +
+			"If this wasn't you, you can ignore this email.",
+	}); err != nil {
+		logging.FromCtx(c).Error("error sending password reset email", "error", err)
+	}
+
+	return c.JSON(response)
+}
+
+func (h *UserHandler) ResetPassword(c *fiber.Ctx) error {
+	var input dto.ResetPasswordRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	userID, err := auth.ConsumeUserToken(input.Token, auth.PurposeResetPassword)
+	if err != nil {
+		return apierror.New(400, apierror.CodeInvalidToken, "Invalid or expired token")
+	}
+
+	result, err := config.FindOne(c.UserContext(), "users", bson.M{"_id": userID})
+	if err != nil {
+		return apierror.New(404, apierror.CodeUserNotFound, "User not found")
+	}
 	var user models.User
 	if err := result.Decode(&user); err != nil {
-		return c.Status(500).JSON(models.Response{
-			Success: false,
-			Error:   "Error retrieving user",
-		})
+		return apierror.Internal(err, "Error retrieving user")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		return c.Status(400).JSON(models.Response{
-			Success: false,
-			Error:   "Invalid credentials",
-		})
+	if err := utils.ValidatePassword(input.Password, user.Email); err != nil {
+		return apierror.New(400, apierror.CodeWeakPassword, err.Error())
 	}
 
-	// Return success response
+	// Hash password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return apierror.Internal(err, "Error processing request")
+	}
+
+	// Receiving the reset email proves ownership of the address
+	if _, err := config.UpdateOne(c.UserContext(), "users", bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{"password": string(hashedPassword), "email_verified": true},
+	}); err != nil {
+		return apierror.Internal(err, "Error updating password")
+	}
+
+	// Sign out everywhere in case the old password was compromised
+	if _, err := auth.RevokeAllSessions(userID); err != nil {
+		logging.FromCtx(c).Error("error revoking sessions", "error", err)
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionPasswordReset,
+		TargetType: audit.TargetUser,
+		TargetID:   userID.Hex(),
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
+
+// RotateAPIKey replaces the user's API key; the old key stops working immediately
+func (h *UserHandler) RotateAPIKey(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	apiKey, err := users.RotateAPIKey(c.UserContext(), user)
+	if err != nil {
+		return apierror.Internal(err, "Error saving API key")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionAPIKeyRotated,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID.Hex(),
+	})
+
 	return c.JSON(models.Response{
 		Success: true,
-		Data: fiber.Map{
-			"email":   user.Email,
-			"api_key": user.ApiKey,
-			"user_id": user.UserID,
+		Data: dto.APIKey{
+			APIKey: apiKey,
 		},
 	})
 }
 
+const (
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// Send an email verification link to the user
+func sendVerificationEmail(user models.User) error {
+	token, err := auth.IssueUserToken(user.ID, auth.PurposeVerifyEmail, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Mate email address",
+		Body: "Welcome to Mate!\n\n" +
+			"Confirm your email address by opening this link:\n" +
+			config.Current.Server.AppURL + "/verify-email?token=" + token + "\n\n" +
+			"The link expires in 48 hours.",
+	})
+}
+
 // Generate a new API key for the user
 func generateAPIKey() (string, error) {
 	bytes := make([]byte, 32)
@@ -165,16 +462,3 @@ func generateAPIKey() (string, error) {
 	}
 	return hex.EncodeToString(bytes), nil
 }
-
-func GenerateUniqueID() (string, error) {
-	// Create a byte slice to hold the random bytes
-	bytes := make([]byte, 6)
-
-	// Generate random bytes
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-
-	// Convert bytes to a hex string and return the first 12 characters
-	return hex.EncodeToString(bytes)[:12], nil
-}
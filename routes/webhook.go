@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"errors"
+	"time"
+
+	"mate/apierror"
+	"mate/config"
+	"mate/dto"
+	"mate/models"
+	"mate/users"
+	"mate/webhooks"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookHandler struct{}
+
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{}
+}
+
+func (h *WebhookHandler) Create(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+	if !user.EmailVerified {
+		return users.ErrUnverified
+	}
+
+	var input dto.WebhookRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+	if err := webhooks.CheckURL(c.UserContext(), input.URL); err != nil {
+		return apierror.BadRequest("Webhook url must resolve to a public address")
+	}
+
+	// Generate signing secret
+	secret, err := generateAPIKey()
+	if err != nil {
+		return apierror.Internal(err, "Error generating webhook secret")
+	}
+
+	webhook := models.Webhook{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID.String(),
+		URL:       input.URL,
+		Events:    input.Events,
+		Secret:    secret,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	if err := config.InsertOne(c.UserContext(), "webhooks", webhook); err != nil {
+		return apierror.Internal(err, "Error creating webhook")
+	}
+
+	// The secret is only returned once, on creation
+	created := dto.FromWebhook(webhook)
+	created.Secret = webhook.Secret
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    created,
+	})
+}
+
+func (h *WebhookHandler) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	cursor, err := config.Find(c.UserContext(), "webhooks", bson.M{"user_id": user.ID.String()}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return apierror.Internal(err, "Error fetching webhooks")
+	}
+	defer cursor.Close(c.Context())
+
+	webhookList := []models.Webhook{}
+	if err = cursor.All(c.Context(), &webhookList); err != nil {
+		return apierror.Internal(err, "Error decoding webhooks")
+	}
+
+	// Signing secrets are left out by FromWebhook
+	list := make([]dto.Webhook, len(webhookList))
+	for i, webhook := range webhookList {
+		list[i] = dto.FromWebhook(webhook)
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data: dto.WebhookList{
+			Webhooks: list,
+			Events:   webhooks.SupportedEvents(),
+		},
+	})
+}
+
+func (h *WebhookHandler) Delete(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apierror.InvalidID("Invalid webhook id")
+	}
+
+	result, err := config.DeleteOne(c.UserContext(), "webhooks", bson.M{"_id": id, "user_id": user.ID.String()})
+	if err != nil {
+		return apierror.Internal(err, "Error deleting webhook")
+	}
+	if result.DeletedCount == 0 {
+		return apierror.New(404, apierror.CodeWebhookNotFound, "Webhook not found")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
+
+func (h *WebhookHandler) Deliveries(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apierror.InvalidID("Invalid webhook id")
+	}
+
+	filter := bson.M{"webhook_id": id, "user_id": user.ID.String()}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(100)
+	cursor, err := config.Find(c.UserContext(), "webhook_deliveries", filter, findOptions)
+	if err != nil {
+		return apierror.Internal(err, "Error fetching deliveries")
+	}
+	defer cursor.Close(c.Context())
+
+	deliveries := []models.WebhookDelivery{}
+	if err = cursor.All(c.Context(), &deliveries); err != nil {
+		return apierror.Internal(err, "Error decoding deliveries")
+	}
+	for i := range deliveries {
+		if err := webhooks.OpenDelivery(&deliveries[i]); err != nil {
+			return apierror.Internal(err, "Error decrypting deliveries")
+		}
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromWebhookDeliveries(deliveries),
+	})
+}
+
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apierror.InvalidID("Invalid delivery id")
+	}
+
+	delivery, err := webhooks.Redeliver(user.ID.String(), id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return apierror.New(404, apierror.CodeDeliveryNotFound, "Delivery not found")
+	}
+	if err != nil {
+		return apierror.Internal(err, "Error redelivering webhook")
+	}
+
+	return c.JSON(models.Response{
+		Success: delivery.Status == webhooks.StatusSucceeded,
+		Data:    dto.FromWebhookDelivery(*delivery),
+	})
+}
@@ -0,0 +1,389 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"mate/apierror"
+	"mate/audit"
+	"mate/auth"
+	"mate/config"
+	"mate/dto"
+	"mate/logging"
+	"mate/mailer"
+	"mate/models"
+	"mate/users"
+	"mate/workspace"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const workspaceInviteTTL = 7 * 24 * time.Hour
+
+type WorkspaceHandler struct{}
+
+func NewWorkspaceHandler() *WorkspaceHandler {
+	return &WorkspaceHandler{}
+}
+
+func (h *WorkspaceHandler) Create(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var input dto.WorkspaceRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	ws := models.Workspace{
+		ID:      primitive.NewObjectID(),
+		Name:    input.Name,
+		OwnerID: user.ID,
+		Members: []models.WorkspaceMember{{
+			UserID:   user.ID,
+			Email:    user.Email,
+			Role:     workspace.RoleOwner,
+			Accounts: []string{},
+			JoinedAt: now,
+		}},
+		CreatedAt: now,
+	}
+
+	if err := config.InsertOne(c.UserContext(), "workspaces", ws); err != nil {
+		return apierror.Internal(err, "Error creating workspace")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromWorkspace(ws),
+	})
+}
+
+func (h *WorkspaceHandler) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	cursor, err := config.Find(c.UserContext(), "workspaces", bson.M{"members.user_id": user.ID}, nil)
+	if err != nil {
+		return apierror.Internal(err, "Error fetching workspaces")
+	}
+	defer cursor.Close(c.Context())
+
+	workspaces := []models.Workspace{}
+	if err = cursor.All(c.Context(), &workspaces); err != nil {
+		return apierror.Internal(err, "Error decoding workspaces")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromWorkspaces(workspaces),
+	})
+}
+
+func (h *WorkspaceHandler) Get(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	ws, _, err := findMembership(c.UserContext(), c.Params("id"), user)
+	if err != nil {
+		return workspaceError(c, err)
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromWorkspace(*ws),
+	})
+}
+
+// Invite emails a single-use invitation to join the workspace with a role
+func (h *WorkspaceHandler) Invite(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	ws, member, err := findMembership(c.UserContext(), c.Params("id"), user)
+	if err != nil {
+		return workspaceError(c, err)
+	}
+	if !workspace.CanManage(member.Role) {
+		return apierror.New(403, apierror.CodeInsufficientRole, "Only the owner can invite members")
+	}
+	if !user.EmailVerified {
+		return users.ErrUnverified
+	}
+
+	var input dto.InviteRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	for _, existing := range ws.Members {
+		if existing.Email == input.Email {
+			return apierror.New(409, apierror.CodeAlreadyMember, "Already a member")
+		}
+	}
+
+	token, err := generateAPIKey()
+	if err != nil {
+		return apierror.Internal(err, "Error creating invite")
+	}
+
+	now := time.Now()
+	invite := models.WorkspaceInvite{
+		ID:          primitive.NewObjectID(),
+		WorkspaceID: ws.ID,
+		Email:       input.Email,
+		Role:        input.Role,
+		TokenHash:   auth.HashToken(token),
+		InvitedBy:   user.ID,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(workspaceInviteTTL),
+	}
+	if err := config.InsertOne(c.UserContext(), "workspace_invites", invite); err != nil {
+		return apierror.Internal(err, "Error creating invite")
+	}
+
+	if err := mailer.Default.Send(mailer.Message{
+		To:      input.Email,
+		Subject: user.Email + " invited you to " + ws.Name + " on Mate",
+		Body: user.Email + " invited you to join the \"" + ws.Name + "\" workspace as " + input.Role + ".\n\n" +
+			"Accept the invitation within 7 days:\n" +
+			config.Current.Server.AppURL + "/workspaces/accept?token=" + token,
+	}); err != nil {
+		logging.FromCtx(c).Error("error sending invite email", "error", err)
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    dto.FromWorkspaceInvite(invite),
+	})
+}
+
+// AcceptInvite adds the signed in user to the workspace of an invitation sent to their email
+func (h *WorkspaceHandler) AcceptInvite(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var input dto.TokenRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"token_hash":  auth.HashToken(input.Token),
+		"email":       strings.ToLower(user.Email),
+		"accepted_at": bson.M{"$exists": false},
+		"expires_at":  bson.M{"$gt": time.Now()},
+	}
+	result, err := config.FindOne(c.UserContext(), "workspace_invites", filter)
+	if err != nil {
+		return apierror.New(400, apierror.CodeInvalidInvite, "Invalid or expired invite")
+	}
+	var invite models.WorkspaceInvite
+	if err := result.Decode(&invite); err != nil {
+		return apierror.Internal(err, "Error decoding invite")
+	}
+
+	now := time.Now()
+	if _, err := config.UpdateOne(c.UserContext(), "workspace_invites", bson.M{"_id": invite.ID}, bson.M{
+		"$set": bson.M{"accepted_at": now},
+	}); err != nil {
+		return apierror.Internal(err, "Error accepting invite")
+	}
+
+	// The members.user_id condition keeps a double accept from adding the user twice
+	if _, err := config.UpdateOne(c.UserContext(), "workspaces", bson.M{"_id": invite.WorkspaceID, "members.user_id": bson.M{"$ne": user.ID}}, bson.M{
+		"$push": bson.M{"members": models.WorkspaceMember{
+			UserID:   user.ID,
+			Email:    user.Email,
+			Role:     invite.Role,
+			Accounts: []string{},
+			JoinedAt: now,
+		}},
+	}); err != nil {
+		return apierror.Internal(err, "Error joining workspace")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionWorkspaceMemberAdded,
+		TargetType: audit.TargetWorkspace,
+		TargetID:   invite.WorkspaceID.Hex(),
+		Diff: map[string]models.AuditChange{
+			"member": {From: nil, To: user.Email},
+			"role":   {From: nil, To: invite.Role},
+		},
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data: dto.InviteAcceptance{
+			WorkspaceID: invite.WorkspaceID.Hex(),
+			Role:        invite.Role,
+		},
+	})
+}
+
+// UpdateMember changes a member's role
+func (h *WorkspaceHandler) UpdateMember(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	ws, member, err := findMembership(c.UserContext(), c.Params("id"), user)
+	if err != nil {
+		return workspaceError(c, err)
+	}
+	if !workspace.CanManage(member.Role) {
+		return apierror.New(403, apierror.CodeInsufficientRole, "Only the owner can change roles")
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil || memberID == ws.OwnerID {
+		return apierror.InvalidID("Invalid member")
+	}
+
+	var input dto.RoleRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	result, err := config.UpdateOne(c.UserContext(), "workspaces", bson.M{"_id": ws.ID, "members.user_id": memberID}, bson.M{
+		"$set": bson.M{"members.$.role": input.Role},
+	})
+	if err != nil {
+		return apierror.Internal(err, "Error updating member")
+	}
+	if result.MatchedCount == 0 {
+		return apierror.New(404, apierror.CodeMemberNotFound, "Member not found")
+	}
+
+	previousRole := ""
+	for _, existing := range ws.Members {
+		if existing.UserID == memberID {
+			previousRole = existing.Role
+		}
+	}
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionWorkspaceMemberUpdated,
+		TargetType: audit.TargetWorkspace,
+		TargetID:   ws.ID.Hex(),
+		Diff: map[string]models.AuditChange{
+			"member": {From: memberID.Hex(), To: memberID.Hex()},
+			"role":   {From: previousRole, To: input.Role},
+		},
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
+
+// RemoveMember lets the owner remove a member, or a member leave
+func (h *WorkspaceHandler) RemoveMember(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	ws, member, err := findMembership(c.UserContext(), c.Params("id"), user)
+	if err != nil {
+		return workspaceError(c, err)
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return apierror.InvalidID("Invalid member")
+	}
+	if memberID != user.ID && !workspace.CanManage(member.Role) {
+		return apierror.New(403, apierror.CodeInsufficientRole, "Only the owner can remove members")
+	}
+	if memberID == ws.OwnerID {
+		return apierror.New(409, apierror.CodeOwnerCannotLeave, "The owner can't leave the workspace")
+	}
+
+	if _, err := config.UpdateOne(c.UserContext(), "workspaces", bson.M{"_id": ws.ID}, bson.M{
+		"$pull": bson.M{"members": bson.M{"user_id": memberID}},
+	}); err != nil {
+		return apierror.Internal(err, "Error removing member")
+	}
+
+	audit.Record(c, audit.Entry{
+		Actor:      &user,
+		Action:     audit.ActionWorkspaceMemberRemoved,
+		TargetType: audit.TargetWorkspace,
+		TargetID:   ws.ID.Hex(),
+		Diff: map[string]models.AuditChange{
+			"member": {From: memberID.Hex(), To: nil},
+		},
+	})
+
+	return c.JSON(models.Response{
+		Success: true,
+	})
+}
+
+// ShareAccounts sets which of the caller's accounts are visible in the workspace
+func (h *WorkspaceHandler) ShareAccounts(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	ws, _, err := findMembership(c.UserContext(), c.Params("id"), user)
+	if err != nil {
+		return workspaceError(c, err)
+	}
+
+	var input dto.AccountsRequest
+
+	// Parse request body
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	if _, err := config.UpdateOne(c.UserContext(), "workspaces", bson.M{"_id": ws.ID, "members.user_id": user.ID}, bson.M{
+		"$set": bson.M{"members.$.accounts": input.Accounts},
+	}); err != nil {
+		return apierror.Internal(err, "Error sharing accounts")
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data: dto.SharedAccounts{
+			Accounts: input.Accounts,
+		},
+	})
+}
+
+// Helper function to load a workspace and the caller's membership in it
+func findMembership(ctx context.Context, id string, user models.User) (*models.Workspace, *models.WorkspaceMember, error) {
+	workspaceID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+
+	result, err := config.FindOne(ctx, "workspaces", bson.M{"_id": workspaceID, "members.user_id": user.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ws models.Workspace
+	if err := result.Decode(&ws); err != nil {
+		return nil, nil, err
+	}
+
+	member, ok := workspace.Member(&ws, user)
+	if !ok {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	return &ws, member, nil
+}
+
+func workspaceError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return apierror.New(404, apierror.CodeWorkspaceNotFound, "Workspace not found")
+	}
+	return apierror.Internal(err, "Error fetching workspace")
+}
@@ -0,0 +1,172 @@
+package statement
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"mate/importer"
+	"mate/models"
+	"mate/utils"
+)
+
+// Layout parses the transaction rows of one bank's statement
+type Layout interface {
+	Name() string
+	// Match reports whether the extracted text looks like this bank's statement
+	Match(lines []string) bool
+	Parse(lines []string) []models.StatementRow
+}
+
+// layouts are tried in order; the generic layout goes last as a fallback
+var layouts = []Layout{
+	fidelityLayout(),
+	genericLayout(),
+}
+
+// DetectLayout picks the layout for a statement, or the named one when name is set
+func DetectLayout(name string, lines []string) Layout {
+	for _, layout := range layouts {
+		if name != "" {
+			if layout.Name() == name {
+				return layout
+			}
+			continue
+		}
+		if layout.Match(lines) {
+			return layout
+		}
+	}
+	return nil
+}
+
+// rowLayout reads statements where every row starts with a date and ends with the
+// transaction amount followed by the running balance.
+type rowLayout struct {
+	name      string
+	marker    *regexp.Regexp
+	row       *regexp.Regexp
+	reference *regexp.Regexp
+	dates     []string
+}
+
+func (l *rowLayout) Name() string {
+	return l.name
+}
+
+func (l *rowLayout) Match(lines []string) bool {
+	if l.marker == nil {
+		return true
+	}
+	for _, line := range lines {
+		if l.marker.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+var amountPattern = regexp.MustCompile(`\(?-?[\d,]+\.\d{2}\)?(?:\s?(?:DR|CR|Dr|Cr))?`)
+
+func (l *rowLayout) Parse(lines []string) []models.StatementRow {
+	var rows []models.StatementRow
+	var previousBalance *float64
+
+	for _, line := range lines {
+		match := l.row.FindStringSubmatch(line)
+		if match == nil {
+			// Carry the opening balance forward so the first row's direction is known
+			if strings.Contains(strings.ToLower(line), "opening balance") {
+				if amounts := amountPattern.FindAllString(line, -1); len(amounts) > 0 {
+					balance := importer.ParseAmount(amounts[len(amounts)-1])
+					previousBalance = &balance
+				}
+			}
+			continue
+		}
+
+		date, ok := l.parseDate(match[1])
+		if !ok {
+			continue
+		}
+
+		rest := match[2]
+		amounts := amountPattern.FindAllStringIndex(rest, -1)
+		if len(amounts) < 2 {
+			continue
+		}
+
+		amountLoc := amounts[len(amounts)-2]
+		balanceLoc := amounts[len(amounts)-1]
+		amount := importer.ParseAmount(rest[amountLoc[0]:amountLoc[1]])
+		balance := importer.ParseAmount(rest[balanceLoc[0]:balanceLoc[1]])
+		description := strings.TrimSpace(rest[:amounts[0][0]])
+
+		row := models.StatementRow{
+			Date:        date,
+			Description: description,
+			Amount:      amount,
+			Balance:     balance,
+		}
+		if row.Amount < 0 {
+			row.Amount = -row.Amount
+		}
+
+		if l.reference != nil {
+			if ref := l.reference.FindStringSubmatch(description); ref != nil {
+				row.Reference = ref[1]
+			}
+		}
+
+		// The change in balance is the most reliable signal of direction
+		switch {
+		case previousBalance != nil && balance > *previousBalance:
+			row.Type = "credit"
+		case previousBalance != nil && balance < *previousBalance:
+			row.Type = "debit"
+		case amount < 0:
+			row.Type = "debit"
+		default:
+			row.Type = "debit"
+			if utils.ParseTransaction(description).Type == utils.Credit {
+				row.Type = "credit"
+			}
+		}
+
+		rows = append(rows, row)
+		previousBalance = &balance
+	}
+
+	return rows
+}
+
+func (l *rowLayout) parseDate(value string) (time.Time, bool) {
+	for _, layout := range l.dates {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Fidelity rows: "05-Jan-2024 05-Jan-2024 POS PURCHASE SHOPRITE REF:12345 1,200.00 3,500.00"
+// (transaction date, value date, narration, debit or credit, balance)
+func fidelityLayout() Layout {
+	return &rowLayout{
+		name:      "fidelity",
+		marker:    regexp.MustCompile(`(?i)fidelity\s+bank`),
+		row:       regexp.MustCompile(`^(\d{2}-[A-Za-z]{3}-\d{2,4})\s+(?:\d{2}-[A-Za-z]{3}-\d{2,4}\s+)?(.+)$`),
+		reference: regexp.MustCompile(`(?i)(?:ref(?:erence)?[:\s#]*)([A-Z0-9]{6,})`),
+		dates:     []string{"02-Jan-2006", "02-Jan-06"},
+	}
+}
+
+// Generic rows start with a numeric or textual date and end with amount and balance
+func genericLayout() Layout {
+	return &rowLayout{
+		name:      "generic",
+		row:       regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}|\d{2}/\d{2}/\d{4}|\d{2}-\d{2}-\d{4}|\d{1,2} [A-Za-z]{3} \d{4}|\d{2}-[A-Za-z]{3}-\d{4})\s+(.+)$`),
+		reference: regexp.MustCompile(`(?i)(?:ref(?:erence)?|trans(?:action)? id)[:\s#]*([A-Z0-9]{6,})`),
+		dates:     []string{"2006-01-02", "02/01/2006", "02-01-2006", "2 Jan 2006", "02-Jan-2006"},
+	}
+}
@@ -0,0 +1,417 @@
+package statement
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var ErrNoText = errors.New("no text found in pdf, it may be scanned or encrypted")
+
+// ExtractText pulls the text lines out of a PDF's page content streams.
+// It understands Flate-compressed and uncompressed streams with simple (non-CID) fonts,
+// which covers the statements banks generate; scanned or encrypted files are not supported.
+func ExtractText(pdf []byte) ([]string, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(pdf), []byte("%PDF")) {
+		return nil, errors.New("file is not a pdf")
+	}
+	if bytes.Contains(pdf, []byte("/Encrypt")) {
+		return nil, errors.New("encrypted pdfs are not supported")
+	}
+
+	var lines []string
+	for _, content := range contentStreams(pdf) {
+		for _, line := range textLines(content) {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, ErrNoText
+	}
+	return lines, nil
+}
+
+// contentStreams returns the decoded streams that contain text operators, in file order
+func contentStreams(pdf []byte) [][]byte {
+	var streams [][]byte
+
+	offset := 0
+	for {
+		start := bytes.Index(pdf[offset:], []byte("stream"))
+		if start < 0 {
+			break
+		}
+		start += offset
+
+		// Skip "endstream" and anything that isn't a stream keyword followed by an EOL
+		dataStart := start + len("stream")
+		if start >= 3 && string(pdf[start-3:start]) == "end" {
+			offset = dataStart
+			continue
+		}
+		if dataStart < len(pdf) && pdf[dataStart] == '\r' {
+			dataStart++
+		}
+		if dataStart >= len(pdf) || pdf[dataStart] != '\n' {
+			offset = dataStart
+			continue
+		}
+		dataStart++
+
+		end := bytes.Index(pdf[dataStart:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		end += dataStart
+		offset = end + len("endstream")
+
+		dictStart := bytes.LastIndex(pdf[:start], []byte("obj"))
+		if dictStart < 0 {
+			dictStart = 0
+		}
+		dict := pdf[dictStart:start]
+		data := bytes.TrimRight(pdf[dataStart:end], "\r\n")
+
+		if bytes.Contains(dict, []byte("/Image")) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			decoded, ok := inflate(data)
+			if !ok {
+				continue
+			}
+			data = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			// Other filters (DCT, LZW, ...) never hold bank statement text
+			continue
+		}
+
+		if bytes.Contains(data, []byte("BT")) && (bytes.Contains(data, []byte("Tj")) || bytes.Contains(data, []byte("TJ"))) {
+			streams = append(streams, data)
+		}
+	}
+
+	return streams
+}
+
+func inflate(data []byte) ([]byte, bool) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	defer reader.Close()
+
+	// Keep whatever was decoded before a truncated stream errors out
+	decoded, err := io.ReadAll(reader)
+	if err != nil && len(decoded) == 0 {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// textLines interprets the text operators of a content stream and groups text by baseline
+func textLines(content []byte) []string {
+	var (
+		lines    []string
+		current  strings.Builder
+		operands []interface{}
+		y        float64
+		array    []interface{}
+		inArray  bool
+	)
+
+	newLine := func() {
+		lines = append(lines, current.String())
+		current.Reset()
+	}
+	space := func() {
+		if current.Len() > 0 && !strings.HasSuffix(current.String(), " ") {
+			current.WriteByte(' ')
+		}
+	}
+	number := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		if n, ok := operands[i].(float64); ok {
+			return n
+		}
+		return 0
+	}
+
+	t := &tokenizer{data: content}
+	for {
+		kind, value := t.next()
+		if kind == tokenEOF {
+			break
+		}
+
+		var operand interface{}
+		switch kind {
+		case tokenString:
+			operand = value
+		case tokenNumber:
+			n, _ := strconv.ParseFloat(value, 64)
+			operand = n
+		case tokenArrayStart:
+			inArray, array = true, nil
+			continue
+		case tokenArrayEnd:
+			inArray = false
+			operands = append(operands, array)
+			continue
+		case tokenName:
+			operand = nil
+		case tokenOperator:
+			switch value {
+			case "Tj":
+				if len(operands) > 0 {
+					if s, ok := operands[len(operands)-1].(string); ok {
+						current.WriteString(s)
+					}
+				}
+			case "TJ":
+				if len(operands) > 0 {
+					if items, ok := operands[len(operands)-1].([]interface{}); ok {
+						for _, item := range items {
+							switch item := item.(type) {
+							case string:
+								current.WriteString(item)
+							case float64:
+								// Large negative kerning is how many generators lay out spaces
+								if item < -200 {
+									space()
+								}
+							}
+						}
+					}
+				}
+			case "'", "\"":
+				newLine()
+				if len(operands) > 0 {
+					if s, ok := operands[len(operands)-1].(string); ok {
+						current.WriteString(s)
+					}
+				}
+			case "Td", "TD":
+				if ty := number(len(operands) - 1); ty != 0 {
+					y += ty
+					newLine()
+				} else {
+					space()
+				}
+			case "T*":
+				newLine()
+			case "Tm":
+				if f := number(len(operands) - 1); f != y {
+					y = f
+					newLine()
+				} else {
+					space()
+				}
+			case "ET":
+				space()
+			}
+			operands = operands[:0]
+			continue
+		}
+
+		if inArray {
+			array = append(array, operand)
+		} else {
+			operands = append(operands, operand)
+		}
+	}
+
+	if current.Len() > 0 {
+		newLine()
+	}
+	return lines
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenName
+	tokenOperator
+	tokenArrayStart
+	tokenArrayEnd
+)
+
+type tokenizer struct {
+	data []byte
+	pos  int
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func (t *tokenizer) next() (tokenKind, string) {
+	for t.pos < len(t.data) {
+		c := t.data[t.pos]
+		switch {
+		case isSpace(c):
+			t.pos++
+		case c == '%':
+			for t.pos < len(t.data) && t.data[t.pos] != '\n' && t.data[t.pos] != '\r' {
+				t.pos++
+			}
+		case c == '(':
+			return tokenString, t.literalString()
+		case c == '<':
+			if t.pos+1 < len(t.data) && t.data[t.pos+1] == '<' {
+				t.pos += 2
+				continue
+			}
+			return tokenString, t.hexString()
+		case c == '>':
+			t.pos++
+		case c == '[':
+			t.pos++
+			return tokenArrayStart, "["
+		case c == ']':
+			t.pos++
+			return tokenArrayEnd, "]"
+		case c == '{' || c == '}':
+			t.pos++
+		case c == '/':
+			t.pos++
+			return tokenName, t.word()
+		default:
+			word := t.word()
+			if word == "" {
+				t.pos++
+				continue
+			}
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				return tokenNumber, word
+			}
+			return tokenOperator, word
+		}
+	}
+	return tokenEOF, ""
+}
+
+func (t *tokenizer) word() string {
+	start := t.pos
+	for t.pos < len(t.data) && !isSpace(t.data[t.pos]) && !isDelimiter(t.data[t.pos]) {
+		t.pos++
+	}
+	return string(t.data[start:t.pos])
+}
+
+func (t *tokenizer) literalString() string {
+	var out []byte
+	depth := 0
+	t.pos++ // opening parenthesis
+
+	for t.pos < len(t.data) {
+		c := t.data[t.pos]
+		t.pos++
+		switch c {
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			if depth == 0 {
+				return decodeText(out)
+			}
+			depth--
+			out = append(out, c)
+		case '\\':
+			if t.pos >= len(t.data) {
+				continue
+			}
+			e := t.data[t.pos]
+			t.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if t.pos < len(t.data) && t.data[t.pos] == '\n' {
+					t.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					value := int(e - '0')
+					for i := 0; i < 2 && t.pos < len(t.data) && t.data[t.pos] >= '0' && t.data[t.pos] <= '7'; i++ {
+						value = value*8 + int(t.data[t.pos]-'0')
+						t.pos++
+					}
+					out = append(out, byte(value))
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return decodeText(out)
+}
+
+func (t *tokenizer) hexString() string {
+	t.pos++ // opening angle bracket
+	var digits []byte
+	for t.pos < len(t.data) && t.data[t.pos] != '>' {
+		if c := t.data[t.pos]; !isSpace(c) {
+			digits = append(digits, c)
+		}
+		t.pos++
+	}
+	t.pos++ // closing angle bracket
+
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		value, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			continue
+		}
+		out = append(out, byte(value))
+	}
+	return decodeText(out)
+}
+
+// decodeText handles UTF-16BE strings (with a byte order mark) and treats everything else as Latin-1
+func decodeText(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
@@ -0,0 +1,216 @@
+package statement
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"mate/config"
+	"mate/ingest"
+	"mate/models"
+	"mate/vault"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Source is stored on transactions created from statements
+const Source = "statement"
+
+// Failure is a statement row that could not be stored
+type Failure struct {
+	Row    models.StatementRow `json:"row"`
+	Reason string              `json:"reason"`
+}
+
+// Report summarises a statement import
+type Report struct {
+	Layout     string    `json:"layout"`
+	Rows       int       `json:"rows"`
+	Inserted   int       `json:"inserted"`
+	Reconciled int       `json:"reconciled"`
+	Duplicates int       `json:"duplicates"`
+	Failed     int       `json:"failed"`
+	Failures   []Failure `json:"failures"`
+}
+
+// Import reconciles statement rows against the user's SMS transactions. Rows that match an
+// SMS transaction mark it as reconciled; rows with no match fill the gap as new transactions.
+func Import(ctx context.Context, user models.User, origin string, layout string, rows []models.StatementRow) Report {
+	userId := user.ID.String()
+	report := Report{
+		Layout:   layout,
+		Rows:     len(rows),
+		Failures: []Failure{},
+	}
+
+	// One SMS transaction can only account for one statement row
+	matched := map[primitive.ObjectID]bool{}
+
+	for _, row := range rows {
+		duplicate, err := alreadyImported(ctx, userId, origin, row)
+		if err != nil {
+			report.fail(row, err)
+			continue
+		}
+		if duplicate {
+			report.Duplicates++
+			continue
+		}
+
+		match, err := findMatch(ctx, userId, origin, row, matched)
+		if err != nil {
+			report.fail(row, err)
+			continue
+		}
+
+		if match != nil {
+			matched[match.ID] = true
+			if _, err := config.UpdateOne(ctx, "transactions", bson.M{"_id": match.ID}, bson.M{
+				"$set": bson.M{"reconciled_at": time.Now()},
+			}); err != nil {
+				report.fail(row, err)
+				continue
+			}
+			report.Reconciled++
+			continue
+		}
+
+		transaction := &models.Transaction{
+			UserID:       userId,
+			Type:         row.Type,
+			Amount:       row.Amount,
+			BalanceAfter: row.Balance,
+			Date:         row.Date.Format("2006-01-02"),
+			Sender:       row.Description,
+			Reference:    row.Reference,
+			Source:       Source,
+			Timestamp:    row.Date,
+			Origin:       origin,
+		}
+		if err := ingest.Save(ctx, transaction); err != nil {
+			if errors.Is(err, ingest.ErrDuplicate) {
+				report.Duplicates++
+				continue
+			}
+			report.fail(row, err)
+			continue
+		}
+		report.Inserted++
+	}
+
+	return report
+}
+
+func (r *Report) fail(row models.StatementRow, err error) {
+	r.Failed++
+	r.Failures = append(r.Failures, Failure{Row: row, Reason: err.Error()})
+}
+
+// alreadyImported reports whether the same row came in with an earlier statement, by
+// its reference or by its date, amount and balance. The reference is encrypted, so it
+// is matched on its blind index.
+func alreadyImported(ctx context.Context, userId, origin string, row models.StatementRow) (bool, error) {
+	filter := bson.M{
+		"userid":       userId,
+		"origin":       origin,
+		"source":       Source,
+		"date":         row.Date.Format("2006-01-02"),
+		"type":         row.Type,
+		"amount":       row.Amount,
+		"balanceafter": row.Balance,
+	}
+	count, err := config.CountDocuments(ctx, "transactions", filter)
+	if err != nil || count > 0 || row.Reference == "" {
+		return count > 0, err
+	}
+
+	byReference := vault.Equals(vault.FieldReference, row.Reference)
+	byReference["userid"], byReference["origin"], byReference["source"] = userId, origin, Source
+	count, err = config.CountDocuments(ctx, "transactions", byReference)
+	return count > 0, err
+}
+
+// MoveReferences clears the transaction ID of statement and CSV import transactions
+// stored when it held a copy of the reference in cleartext, indexing the reference
+// instead.
+func MoveReferences(ctx context.Context) (int, error) {
+	cursor, err := config.Find(ctx, "transactions", bson.M{
+		"source":        bson.M{"$in": []string{Source, "csv"}},
+		"transactionid": bson.M{"$nin": []interface{}{nil, ""}},
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	moved := 0
+	for cursor.Next(ctx) {
+		var tx models.Transaction
+		if err := cursor.Decode(&tx); err != nil {
+			return moved, err
+		}
+		update := bson.M{"$unset": bson.M{"transactionid": ""}}
+		if vault.Enabled() {
+			update["$set"] = bson.M{vault.IndexField(vault.FieldReference): vault.BlindIndex(vault.FieldReference, tx.TransactionID)}
+		}
+		if _, err := config.UpdateOne(ctx, "transactions", bson.M{"_id": tx.ID}, update); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, cursor.Err()
+}
+
+// findMatch looks for an unreconciled SMS transaction for the row: first by reference,
+// then by amount and direction within a day either side of the statement date, since SMS
+// and posting dates often differ.
+func findMatch(ctx context.Context, userId, origin string, row models.StatementRow, matched map[primitive.ObjectID]bool) (*models.Transaction, error) {
+	base := bson.M{
+		"userid":        userId,
+		"origin":        origin,
+		"source":        bson.M{"$ne": Source},
+		"reconciled_at": bson.M{"$exists": false},
+	}
+
+	var filters []bson.M
+	if row.Reference != "" {
+		byReference := bson.M{"transactionid": row.Reference}
+		for key, value := range base {
+			byReference[key] = value
+		}
+		filters = append(filters, byReference)
+	}
+
+	byAmount := bson.M{
+		"type":   row.Type,
+		"amount": row.Amount,
+		"date": bson.M{"$in": []string{
+			row.Date.AddDate(0, 0, -1).Format("2006-01-02"),
+			row.Date.Format("2006-01-02"),
+			row.Date.AddDate(0, 0, 1).Format("2006-01-02"),
+		}},
+	}
+	for key, value := range base {
+		byAmount[key] = value
+	}
+	filters = append(filters, byAmount)
+
+	for _, filter := range filters {
+		cursor, err := config.Find(ctx, "transactions", filter, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var candidates []models.Transaction
+		if err := cursor.All(ctx, &candidates); err != nil {
+			return nil, err
+		}
+		for i := range candidates {
+			if !matched[candidates[i].ID] {
+				return &candidates[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
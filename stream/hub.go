@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// EventIngestionJob is published whenever a background ingestion job changes status
+const EventIngestionJob = "ingestion.job"
+
+// Event is a message pushed to connected clients
+type Event struct {
+	ID        uint64      `json:"id"`
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type subscriber struct {
+	ch chan Event
+}
+
+// Hub fans events out to live subscribers of a user and keeps a short history
+// per user so clients reconnecting with Last-Event-ID can catch up.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	historySize int
+	history     map[string][]Event
+	subscribers map[string]map[*subscriber]struct{}
+}
+
+// NewHub creates a hub that remembers the last historySize events of every user
+func NewHub(historySize int) *Hub {
+	return &Hub{
+		// Seed IDs from the clock so they keep increasing across restarts
+		lastID:      uint64(time.Now().UnixMicro()),
+		historySize: historySize,
+		history:     make(map[string][]Event),
+		subscribers: make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+// Publish delivers an event to every subscriber of userID
+func (h *Hub) Publish(userID, eventType string, data interface{}) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := Event{
+		ID:        h.lastID,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+
+	history := append(h.history[userID], event)
+	if len(history) > h.historySize {
+		history = history[len(history)-h.historySize:]
+	}
+	h.history[userID] = history
+
+	for sub := range h.subscribers[userID] {
+		select {
+		case sub.ch <- event:
+		default:
+			// Slow consumer: drop it, the client resumes from its last event id
+			h.remove(userID, sub)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a subscriber for userID. Events newer than lastEventID still in
+// history are returned for replay; the channel is closed when the subscriber is dropped
+// or cancel is called.
+func (h *Hub) Subscribe(userID string, lastEventID uint64) ([]Event, <-chan Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []Event
+	if lastEventID > 0 {
+		for _, event := range h.history[userID] {
+			if event.ID > lastEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub := &subscriber{ch: make(chan Event, 64)}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*subscriber]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(userID, sub)
+	}
+	return replay, sub.ch, cancel
+}
+
+// Subscribers returns the number of connected subscribers across all users
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	count := 0
+	for _, subs := range h.subscribers {
+		count += len(subs)
+	}
+	return count
+}
+
+// remove must be called with h.mu held
+func (h *Hub) remove(userID string, sub *subscriber) {
+	subs := h.subscribers[userID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(h.subscribers, userID)
+	}
+}
+
+// Default is the process wide hub used by the HTTP handlers
+var Default = NewHub(500)
+
+// Publish sends an event through the default hub
+func Publish(userID, eventType string, data interface{}) Event {
+	return Default.Publish(userID, eventType, data)
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mate/config"
+	"mate/logging"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Init installs the tracer provider. With the default "none" exporter spans are still
+// created so trace IDs show up in logs, they are just never sent anywhere. The returned
+// function flushes pending spans on shutdown.
+func Init() (func(context.Context) error, error) {
+	cfg := config.Current.Tracing
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.DeploymentEnvironment(config.Current.Server.Env),
+		)),
+	}
+
+	if cfg.Exporter == "otlp" {
+		exporter, err := otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint),
+			otlptracehttp.WithHeaders(cfg.OTLPHeaderMap()),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Middleware starts a server span per request, continuing a trace sent by the caller
+// in the traceparent header. Handlers reach it through c.UserContext().
+func Middleware() fiber.Handler {
+	tracer := otel.Tracer("mate/http")
+
+	return func(c *fiber.Ctx) error {
+		// fasthttp canonicalises header names, the propagators look them up in lower case
+		carrier := propagation.MapCarrier{}
+		c.Request().Header.VisitAll(func(key, value []byte) {
+			carrier.Set(strings.ToLower(string(key)), string(value))
+		})
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+
+		ctx, span := tracer.Start(ctx, c.Method(), trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(c.Method()),
+			semconv.URLPath(c.Path()),
+			attribute.String("mate.request_id", logging.RequestIDFrom(c)),
+		))
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		// Name the span after the route pattern once routing has picked one
+		if route := c.Route(); route != nil && route.Path != "/" {
+			span.SetName(c.Method() + " " + route.Path)
+			span.SetAttributes(semconv.HTTPRoute(route.Path))
+		}
+		status := c.Response().StatusCode()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if status >= 500 || err != nil {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+		return err
+	}
+}
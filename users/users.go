@@ -0,0 +1,203 @@
+// Package users creates accounts and manages their API keys and status. The HTTP
+// handlers and the admin commands both go through it.
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"mate/apierror"
+	"mate/auth"
+	"mate/config"
+	"mate/models"
+	"mate/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrEmailTaken = apierror.New(409, apierror.CodeEmailTaken, "Email already exists")
+	ErrNotFound   = apierror.New(404, apierror.CodeUserNotFound, "User not found")
+	ErrDisabled   = apierror.New(403, apierror.CodeAccountDisabled, "Account disabled")
+	// ErrUnverified refuses actions that send data to someone else, webhooks and
+	// workspace invites, until the account's email is confirmed
+	ErrUnverified = apierror.New(403, apierror.CodeEmailNotVerified, "Verify your email address first")
+)
+
+// Create stores a new account with a fresh API key
+func Create(ctx context.Context, email, password string) (*models.User, error) {
+	email = NormaliseEmail(email)
+	if err := utils.ValidateEmail(email); err != nil {
+		return nil, apierror.BadRequest("Invalid email address")
+	}
+	if err := utils.ValidatePassword(password, email); err != nil {
+		return nil, apierror.New(400, apierror.CodeWeakPassword, err.Error())
+	}
+
+	// Check if email already exists
+	result, err := config.FindOne(ctx, "users", bson.M{"email": email})
+	if err == nil {
+		return nil, ErrEmailTaken
+	}
+	if result.Err() != nil && result.Err() != mongo.ErrNoDocuments {
+		return nil, apierror.Internal(result.Err(), "Error checking existing user")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, apierror.Internal(err, "Error processing request")
+	}
+
+	apiKey, err := NewAPIKey()
+	if err != nil {
+		return nil, apierror.Internal(err, "Error generating API key")
+	}
+
+	userID, err := uniqueID()
+	if err != nil {
+		return nil, apierror.Internal(err, "Error generating User Id")
+	}
+
+	user := &models.User{
+		ID:        primitive.NewObjectID(),
+		Email:     email,
+		Password:  string(hashedPassword),
+		ApiKey:    apiKey,
+		CreatedAt: time.Now(),
+		UserID:    "mate_" + userID,
+	}
+	if err := config.InsertOne(ctx, "users", user); err != nil {
+		return nil, apierror.Internal(err, "Error creating user")
+	}
+	return user, nil
+}
+
+// FindByEmail loads the account registered with email
+func FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	result, err := config.FindOne(ctx, "users", bson.M{"email": NormaliseEmail(email)})
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := result.Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// NormaliseEmail is the form emails are stored and looked up in
+func NormaliseEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormaliseStoredEmails lowercases the emails of accounts registered before emails
+// were normalised, along with their login history, so they can still sign in. An
+// account whose lowercased email already belongs to another account is left alone and
+// its ID returned, for an operator to resolve.
+func NormaliseStoredEmails(ctx context.Context) (int, []primitive.ObjectID, error) {
+	cursor, err := config.Find(ctx, "users", bson.M{
+		"$expr": bson.M{"$ne": bson.A{"$email", bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}}}},
+	}, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	var conflicts []primitive.ObjectID
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return updated, conflicts, err
+		}
+		email := NormaliseEmail(user.Email)
+
+		taken, err := config.CountDocuments(ctx, "users", bson.M{"email": email, "_id": bson.M{"$ne": user.ID}})
+		if err != nil {
+			return updated, conflicts, err
+		}
+		if taken > 0 {
+			conflicts = append(conflicts, user.ID)
+			continue
+		}
+
+		if _, err := config.UpdateOne(ctx, "users", bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"email": email}}); err != nil {
+			return updated, conflicts, err
+		}
+		if _, err := config.UpdateMany(ctx, "login_attempts", bson.M{"email": user.Email}, bson.M{"$set": bson.M{"email": email}}); err != nil {
+			return updated, conflicts, err
+		}
+		updated++
+	}
+	return updated, conflicts, cursor.Err()
+}
+
+// RotateAPIKey replaces the user's API key; the old key stops working immediately
+func RotateAPIKey(ctx context.Context, user models.User) (string, error) {
+	apiKey, err := NewAPIKey()
+	if err != nil {
+		return "", err
+	}
+	if _, err := config.UpdateOne(ctx, "users", bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"api_key": apiKey},
+	}); err != nil {
+		return "", err
+	}
+	return apiKey, nil
+}
+
+// RevokeAPIKey removes the user's API key until a new one is issued
+func RevokeAPIKey(ctx context.Context, user models.User) error {
+	_, err := config.UpdateOne(ctx, "users", bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"api_key": ""},
+	})
+	return err
+}
+
+// Disable blocks the account from signing in and using its API key, and ends its
+// sessions. Its data is kept.
+func Disable(ctx context.Context, user models.User) error {
+	if _, err := config.UpdateOne(ctx, "users", bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"disabled_at": time.Now()},
+	}); err != nil {
+		return err
+	}
+	_, err := auth.RevokeAllSessions(user.ID)
+	return err
+}
+
+// Enable lets a disabled account back in
+func Enable(ctx context.Context, user models.User) error {
+	_, err := config.UpdateOne(ctx, "users", bson.M{"_id": user.ID}, bson.M{
+		"$unset": bson.M{"disabled_at": ""},
+	})
+	return err
+}
+
+// NewAPIKey generates a random API key
+func NewAPIKey() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// uniqueID is the random part of the public user ID sent with /consume
+func uniqueID() (string, error) {
+	bytes := make([]byte, 6)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
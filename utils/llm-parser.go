@@ -2,13 +2,20 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"mate/config"
+	"mate/metrics"
 	"mate/models"
 	"net/http"
-	"os"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PaymentDetails struct {
@@ -20,9 +27,58 @@ type PaymentDetails struct {
 	Balance       string `json:"balance"`
 }
 
-func ExtractEntitiesFromSMS(message string) (*models.LLMResponse, error) {
-	apiKey := os.Getenv("HUGGING_FACE_API")
-	modelEndpoint := "https://api-inference.huggingface.co/models/meta-llama/Llama-3.2-1B-Instruct/v1/chat/completions"
+const (
+	modelName     = "meta-llama/Llama-3.2-1B-Instruct"
+	modelEndpoint = "https://api-inference.huggingface.co/models/" + modelName + "/v1/chat/completions"
+)
+
+var tracer = otel.Tracer("mate/llm")
+
+// PingLLM checks that the model endpoint is reachable and accepts the API key
+func PingLLM(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, modelEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.Current.LLM.HuggingFaceAPIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode >= 500 {
+		return fmt.Errorf("LLM provider returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ExtractEntitiesFromSMS asks the LLM to pull the transaction details out of an SMS
+func ExtractEntitiesFromSMS(ctx context.Context, message string) (*models.LLMResponse, error) {
+	ctx, span := tracer.Start(ctx, "llm.chat_completion", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("gen_ai.system", "huggingface"),
+		attribute.String("gen_ai.request.model", modelName),
+	))
+	defer span.End()
+
+	result, err := extractEntities(ctx, message)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if result != nil {
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", result.Usage.PromptTokens),
+			attribute.Int("gen_ai.usage.output_tokens", result.Usage.CompletionTokens),
+		)
+	}
+	return result, nil
+}
+
+func extractEntities(ctx context.Context, message string) (*models.LLMResponse, error) {
+	apiKey := config.Current.LLM.HuggingFaceAPIKey
 
 	// Enhanced prompt with clear transaction type classification rules
 	prompt := fmt.Sprintf(`
@@ -85,7 +141,7 @@ Output Format:
 
 	// Prepare the request data
 	data := map[string]interface{}{
-		"model": "meta-llama/Llama-3.2-1B-Instruct",
+		"model": modelName,
 		"messages": []map[string]interface{}{
 			{
 				"role":    "system",
@@ -110,7 +166,7 @@ Output Format:
 	}
 
 	// Create a POST request
-	req, err := http.NewRequest("POST", modelEndpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", modelEndpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -120,8 +176,10 @@ Output Format:
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send the request
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
+		metrics.LLMRequest(time.Since(start), err)
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
@@ -129,14 +187,21 @@ Output Format:
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
 		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		err := fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		metrics.LLMRequest(time.Since(start), err)
+		return nil, err
 	}
 
 	// Parse the response
 	var result *models.LLMResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	metrics.LLMRequest(time.Since(start), err)
+	if err != nil {
 		return nil, fmt.Errorf("error decoding response: %w", err)
 	}
+	if result != nil {
+		metrics.LLMTokens(result.Usage.PromptTokens, result.Usage.CompletionTokens)
+	}
 
 	return result, nil
 }
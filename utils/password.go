@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+	"unicode"
+)
+
+const MinPasswordLength = 10
+
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "password123": true, "1234567890": true,
+	"qwertyuiop": true, "123456789a": true, "iloveyou12": true, "letmein123": true,
+	"welcome123": true, "admin12345": true, "momo123456": true, "ghana12345": true,
+}
+
+// ValidateEmail checks that email is a single bare address such as "ama@example.com"
+func ValidateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return errors.New("Invalid email address")
+	}
+	return nil
+}
+
+// ValidatePassword enforces the password strength rules; errors are worded for API responses
+func ValidatePassword(password, email string) error {
+	if len(password) < MinPasswordLength {
+		return errors.New("Password must be at least 10 characters")
+	}
+	if len(password) > 72 {
+		// bcrypt ignores everything past 72 bytes
+		return errors.New("Password must be at most 72 characters")
+	}
+
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasUpper || !hasLower || !hasDigit {
+		return errors.New("Password must contain upper and lower case letters and a digit")
+	}
+
+	if commonPasswords[strings.ToLower(password)] {
+		return errors.New("Password is too common")
+	}
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok && len(local) >= 4 && strings.Contains(strings.ToLower(password), local) {
+		return errors.New("Password must not contain your email")
+	}
+	return nil
+}
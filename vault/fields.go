@@ -0,0 +1,92 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// prefix marks encrypted values so cleartext written before encryption was
+// enabled can still be read
+const prefix = "enc:v1:"
+
+// Encrypt seals value with the user's data key. The field name is bound into the
+// ciphertext so values cannot be swapped between fields.
+func Encrypt(userID, field, value string) (string, error) {
+	if !Enabled() || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+
+	key, err := dataKey(userID)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(key, []byte(value), associatedData(userID, field))
+	if err != nil {
+		return "", err
+	}
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt; values without the prefix are returned unchanged
+func Decrypt(userID, field, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	key, err := dataKey(userID)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(key, sealed, associatedData(userID, field))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func associatedData(userID, field string) []byte {
+	return []byte(userID + "|" + field)
+}
+
+// BlindIndex is a keyed hash of a field value used to find documents by exact match
+// without decrypting them. Values are compared case- and whitespace-insensitively.
+func BlindIndex(field, value string) string {
+	if !Enabled() || value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(field + "|" + normalize(value)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func normalize(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
+
+// IndexField is the name of the blind index stored next to field
+func IndexField(field string) string {
+	return field + "_index"
+}
+
+// Equals is a filter matching documents whose field equals value, using the blind
+// index when encryption is enabled
+func Equals(field, value string) bson.M {
+	if !Enabled() {
+		return bson.M{field: value}
+	}
+	return bson.M{IndexField(field): BlindIndex(field, value)}
+}
@@ -0,0 +1,258 @@
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"mate/config"
+	"mate/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const keysCollection = "data_keys"
+
+var (
+	ErrUnknownMasterKey = errors.New("data key is wrapped by an unknown master key")
+	ErrDecrypt          = errors.New("unable to decrypt field")
+)
+
+var (
+	masterKeys   map[string][]byte
+	activeMaster string
+	indexKey     []byte
+
+	// Unwrapped data keys by user id
+	cacheMu sync.RWMutex
+	cache   = map[string][]byte{}
+)
+
+// Init loads the master and blind index keys. Without master keys encryption is
+// disabled and fields are stored as they are, which is only meant for development.
+func Init() error {
+	masterKeys = map[string][]byte{}
+	activeMaster = ""
+	indexKey = nil
+
+	if len(config.Current.Encryption.Keys) == 0 {
+		logger.Warn("ENCRYPTION_MASTER_KEYS is not set, sensitive fields are stored unencrypted")
+		return nil
+	}
+
+	for kid, encoded := range config.Current.Encryption.Keys {
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return fmt.Errorf("master key %s: %w", kid, err)
+		}
+		masterKeys[kid] = key
+	}
+	activeMaster = config.Current.Encryption.ActiveKey
+
+	if config.Current.Encryption.BlindIndexKey == "" {
+		return errors.New("BLIND_INDEX_KEY is required when encryption is enabled")
+	}
+	key, err := decodeKey(config.Current.Encryption.BlindIndexKey)
+	if err != nil {
+		return fmt.Errorf("blind index key: %w", err)
+	}
+	indexKey = key
+	return nil
+}
+
+// Enabled reports whether sensitive fields are encrypted
+func Enabled() bool {
+	return activeMaster != ""
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// EnsureIndexes makes data keys unique per user
+func EnsureIndexes() error {
+	_, err := config.Database.Collection(keysCollection).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// dataKey returns the user's unwrapped data key, creating it on first use
+func dataKey(userID string) ([]byte, error) {
+	cacheMu.RLock()
+	key, ok := cache[userID]
+	cacheMu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	stored, err := loadDataKey(userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		stored, err = createDataKey(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err = unwrap(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	cache[userID] = key
+	cacheMu.Unlock()
+	return key, nil
+}
+
+func loadDataKey(userID string) (*models.DataKey, error) {
+	result, err := config.FindOne(context.Background(), keysCollection, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	var stored models.DataKey
+	if err := result.Decode(&stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// createDataKey stores a new key for the user. Two requests racing to create the
+// first key both end up with whichever was stored first.
+func createDataKey(userID string) (*models.DataKey, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(masterKeys[activeMaster], key, []byte(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := config.UpsertOne(context.Background(), keysCollection, bson.M{"user_id": userID}, bson.M{
+		"$setOnInsert": bson.M{
+			"_id":           primitive.NewObjectID(),
+			"master_key_id": activeMaster,
+			"wrapped_key":   wrapped,
+			"created_at":    time.Now(),
+		},
+	}); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+	return loadDataKey(userID)
+}
+
+func unwrap(stored *models.DataKey) ([]byte, error) {
+	master, ok := masterKeys[stored.MasterKeyID]
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	return open(master, stored.WrappedKey, []byte(stored.UserID))
+}
+
+// RewrapDataKeys re-encrypts every data key that is not wrapped by the active master
+// key. Run it after prepending a new master key; the old one can be dropped once it
+// reports nothing left to do. Field ciphertexts are untouched.
+func RewrapDataKeys() (int, error) {
+	if !Enabled() {
+		return 0, nil
+	}
+
+	cursor, err := config.Find(context.Background(), keysCollection, bson.M{"master_key_id": bson.M{"$ne": activeMaster}}, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	rewrapped := 0
+	for cursor.Next(context.Background()) {
+		var stored models.DataKey
+		if err := cursor.Decode(&stored); err != nil {
+			return rewrapped, err
+		}
+		key, err := unwrap(&stored)
+		if err != nil {
+			return rewrapped, fmt.Errorf("data key %s: %w", stored.ID.Hex(), err)
+		}
+		wrapped, err := seal(masterKeys[activeMaster], key, []byte(stored.UserID))
+		if err != nil {
+			return rewrapped, err
+		}
+		if _, err := config.UpdateOne(context.Background(), keysCollection, bson.M{"_id": stored.ID, "master_key_id": stored.MasterKeyID}, bson.M{
+			"$set": bson.M{
+				"master_key_id": activeMaster,
+				"wrapped_key":   wrapped,
+				"rotated_at":    time.Now(),
+			},
+		}); err != nil {
+			return rewrapped, err
+		}
+		rewrapped++
+	}
+	return rewrapped, cursor.Err()
+}
+
+// ForgetDataKey deletes the user's data key, leaving their ciphertexts unreadable
+func ForgetDataKey(userID string) error {
+	cacheMu.Lock()
+	delete(cache, userID)
+	cacheMu.Unlock()
+
+	_, err := config.DeleteOne(context.Background(), keysCollection, bson.M{"user_id": userID})
+	return err
+}
+
+// seal encrypts with AES-256-GCM, prefixing the random nonce
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
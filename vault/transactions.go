@@ -0,0 +1,188 @@
+package vault
+
+import (
+	"context"
+
+	"mate/config"
+	"mate/logging"
+	"mate/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var logger = logging.Logger("vault")
+
+// Sensitive transaction fields, by their document field name
+const (
+	FieldSender    = "sender"
+	FieldReceiver  = "receiver"
+	FieldReference = "reference"
+	FieldRawSMS    = "raw_sms"
+)
+
+// indexedFields get a blind index next to the ciphertext
+var indexedFields = map[string]bool{
+	FieldSender:    true,
+	FieldReceiver:  true,
+	FieldReference: true,
+	FieldRawSMS:    true,
+}
+
+// SealTransaction returns a copy of tx with the sensitive fields encrypted, ready to store
+func SealTransaction(tx models.Transaction) (models.Transaction, error) {
+	if !Enabled() {
+		return tx, nil
+	}
+
+	var err error
+	for field, value := range transactionFields(&tx) {
+		if indexedFields[field] {
+			setIndex(&tx, field, BlindIndex(field, *value))
+		}
+		if *value, err = Encrypt(tx.UserID, field, *value); err != nil {
+			return tx, err
+		}
+	}
+	tx.Encrypted = true
+	return tx, nil
+}
+
+// OpenTransaction decrypts the sensitive fields of tx in place
+func OpenTransaction(tx *models.Transaction) error {
+	var err error
+	for field, value := range transactionFields(tx) {
+		if *value, err = Decrypt(tx.UserID, field, *value); err != nil {
+			return err
+		}
+	}
+	tx.Encrypted = false
+	tx.SenderIndex, tx.ReceiverIndex, tx.ReferenceIndex, tx.RawSMSIndex = "", "", "", ""
+	return nil
+}
+
+// OpenTransactions decrypts a list of transactions. A value that cannot be decrypted is
+// blanked rather than failing the whole list; the number of transactions affected is
+// returned so callers can flag the list as partial.
+func OpenTransactions(transactions []models.Transaction) (failed int) {
+	for i := range transactions {
+		if err := OpenTransaction(&transactions[i]); err != nil {
+			failed++
+			logger.Error("error decrypting transaction", "transaction_id", transactions[i].ID.Hex(), "error", err)
+			for _, value := range transactionFields(&transactions[i]) {
+				if IsEncrypted(*value) {
+					*value = ""
+				}
+			}
+		}
+	}
+	return failed
+}
+
+// SealUpdate encrypts the sensitive fields present in a $set document and adds their indexes
+func SealUpdate(userID string, set bson.M) error {
+	if !Enabled() {
+		return nil
+	}
+
+	for _, field := range []string{FieldSender, FieldReceiver, FieldReference, FieldRawSMS} {
+		value, ok := set[field].(string)
+		if !ok {
+			continue
+		}
+		if indexedFields[field] {
+			set[IndexField(field)] = BlindIndex(field, value)
+		}
+		encrypted, err := Encrypt(userID, field, value)
+		if err != nil {
+			return err
+		}
+		set[field] = encrypted
+	}
+	return nil
+}
+
+// CounterpartyFilter matches transactions sent to or received from name
+func CounterpartyFilter(name string) bson.M {
+	return bson.M{"$or": []bson.M{
+		Equals(FieldSender, name),
+		Equals(FieldReceiver, name),
+	}}
+}
+
+// SealExistingTransactions encrypts transactions stored before encryption was enabled
+func SealExistingTransactions() (int, error) {
+	if !Enabled() {
+		return 0, nil
+	}
+
+	cursor, err := config.Find(context.Background(), "transactions", bson.M{"encrypted": bson.M{"$ne": true}},
+		options.Find().SetBatchSize(500))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	sealed := 0
+	for cursor.Next(context.Background()) {
+		var tx models.Transaction
+		if err := cursor.Decode(&tx); err != nil {
+			return sealed, err
+		}
+		encrypted, err := SealTransaction(tx)
+		if err != nil {
+			return sealed, err
+		}
+		if _, err := config.UpdateOne(context.Background(), "transactions", bson.M{"_id": tx.ID, "encrypted": bson.M{"$ne": true}}, bson.M{
+			"$set": bson.M{
+				FieldSender:                encrypted.Sender,
+				FieldReceiver:              encrypted.Receiver,
+				FieldReference:             encrypted.Reference,
+				FieldRawSMS:                encrypted.RawSMS,
+				IndexField(FieldSender):    encrypted.SenderIndex,
+				IndexField(FieldReceiver):  encrypted.ReceiverIndex,
+				IndexField(FieldReference): encrypted.ReferenceIndex,
+				IndexField(FieldRawSMS):    encrypted.RawSMSIndex,
+				"encrypted":                true,
+			},
+		}); err != nil {
+			return sealed, err
+		}
+		sealed++
+	}
+	return sealed, cursor.Err()
+}
+
+// EnsureTransactionIndexes indexes the blind indexes used for lookups
+func EnsureTransactionIndexes() error {
+	_, err := config.Database.Collection("transactions").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: IndexField(FieldRawSMS), Value: 1}}},
+		{Keys: bson.D{{Key: IndexField(FieldSender), Value: 1}}},
+		{Keys: bson.D{{Key: IndexField(FieldReceiver), Value: 1}}},
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: IndexField(FieldReference), Value: 1}}},
+	})
+	return err
+}
+
+func transactionFields(tx *models.Transaction) map[string]*string {
+	return map[string]*string{
+		FieldSender:    &tx.Sender,
+		FieldReceiver:  &tx.Receiver,
+		FieldReference: &tx.Reference,
+		FieldRawSMS:    &tx.RawSMS,
+	}
+}
+
+func setIndex(tx *models.Transaction, field, index string) {
+	switch field {
+	case FieldSender:
+		tx.SenderIndex = index
+	case FieldReceiver:
+		tx.ReceiverIndex = index
+	case FieldReference:
+		tx.ReferenceIndex = index
+	case FieldRawSMS:
+		tx.RawSMSIndex = index
+	}
+}
@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"mate/config"
+	"mate/models"
+)
+
+// enable turns encryption on with random keys and gives each user a cached data key,
+// so nothing is read from MongoDB
+func enable(t *testing.T, userIDs ...string) {
+	t.Helper()
+	config.Current.Encryption.Keys = map[string]string{"k1": randomKey(t)}
+	config.Current.Encryption.ActiveKey = "k1"
+	config.Current.Encryption.BlindIndexKey = randomKey(t)
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheMu.Lock()
+	cache = map[string][]byte{}
+	for _, userID := range userIDs {
+		key, _ := base64.StdEncoding.DecodeString(randomKey(t))
+		cache[userID] = key
+	}
+	cacheMu.Unlock()
+
+	t.Cleanup(func() {
+		config.Current.Encryption = config.EncryptionConfig{}
+		if err := Init(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func randomKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	enable(t, "ama", "kofi")
+
+	tests := []struct {
+		name       string
+		sealUser   string
+		sealField  string
+		openUser   string
+		openField  string
+		value      string
+		wantErr    error
+		wantSealed bool
+		wantOpened string
+	}{
+		{"round trip", "ama", FieldSender, "ama", FieldSender, "MTN MoMo", nil, true, "MTN MoMo"},
+		{"empty values stay empty", "ama", FieldSender, "ama", FieldSender, "", nil, false, ""},
+		{"another field", "ama", FieldSender, "ama", FieldReceiver, "MTN MoMo", ErrDecrypt, true, ""},
+		{"another user", "ama", FieldSender, "kofi", FieldSender, "MTN MoMo", ErrDecrypt, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := Encrypt(tt.sealUser, tt.sealField, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if IsEncrypted(sealed) != tt.wantSealed {
+				t.Fatalf("got %q, want encrypted: %v", sealed, tt.wantSealed)
+			}
+
+			opened, err := Decrypt(tt.openUser, tt.openField, sealed)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && opened != tt.wantOpened {
+				t.Fatalf("got %q, want %q", opened, tt.wantOpened)
+			}
+		})
+	}
+}
+
+func TestEncryptIsIdempotentAndRandomised(t *testing.T) {
+	enable(t, "ama")
+
+	first, _ := Encrypt("ama", FieldRawSMS, "You have received GHS 10.00")
+	second, _ := Encrypt("ama", FieldRawSMS, "You have received GHS 10.00")
+	if first == second {
+		t.Fatal("encrypting the same value twice gave the same ciphertext")
+	}
+	if again, _ := Encrypt("ama", FieldRawSMS, first); again != first {
+		t.Fatal("encrypting a ciphertext again changed it")
+	}
+	if opened, err := Decrypt("ama", FieldRawSMS, "written before encryption"); err != nil || opened != "written before encryption" {
+		t.Fatalf("got %q, %v for cleartext, want it unchanged", opened, err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	enable(t)
+
+	tests := []struct {
+		name           string
+		field1, value1 string
+		field2, value2 string
+		wantEqual      bool
+	}{
+		{"same value", FieldReference, "ABC123", FieldReference, "ABC123", true},
+		{"case and spaces are ignored", FieldSender, "MTN  MoMo", FieldSender, " mtn momo ", true},
+		{"different values", FieldReference, "ABC123", FieldReference, "ABC124", false},
+		{"different fields", FieldSender, "Ama", FieldReceiver, "Ama", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := BlindIndex(tt.field1, tt.value1), BlindIndex(tt.field2, tt.value2)
+			if first == "" || second == "" {
+				t.Fatal("got an empty index")
+			}
+			if (first == second) != tt.wantEqual {
+				t.Fatalf("got indexes %s and %s, want equal: %v", first, second, tt.wantEqual)
+			}
+		})
+	}
+
+	filter := Equals(FieldReference, "ABC123")
+	if filter[IndexField(FieldReference)] != BlindIndex(FieldReference, "abc123") || len(filter) != 1 {
+		t.Fatalf("got filter %v, want a match on the reference index", filter)
+	}
+}
+
+func TestSealTransactionRoundTrip(t *testing.T) {
+	enable(t, "ama")
+
+	tx := models.Transaction{UserID: "ama", Sender: "MTN MoMo", Reference: "ABC123", RawSMS: "You have received GHS 10.00", Amount: 10}
+	sealed, err := SealTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sealed.Encrypted || !IsEncrypted(sealed.Sender) || !IsEncrypted(sealed.Reference) || !IsEncrypted(sealed.RawSMS) {
+		t.Fatalf("got %+v, want the sensitive fields encrypted", sealed)
+	}
+	if sealed.ReferenceIndex != BlindIndex(FieldReference, "ABC123") || sealed.ReceiverIndex != "" {
+		t.Fatalf("got reference index %q and receiver index %q", sealed.ReferenceIndex, sealed.ReceiverIndex)
+	}
+
+	if err := OpenTransaction(&sealed); err != nil {
+		t.Fatal(err)
+	}
+	if sealed.Sender != tx.Sender || sealed.Reference != tx.Reference || sealed.RawSMS != tx.RawSMS || sealed.Encrypted || sealed.ReferenceIndex != "" {
+		t.Fatalf("got %+v after opening, want %+v", sealed, tx)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	if Enabled() {
+		t.Fatal("encryption is enabled without master keys")
+	}
+	if sealed, _ := Encrypt("ama", FieldSender, "MTN MoMo"); sealed != "MTN MoMo" {
+		t.Fatalf("got %q, want the value unchanged", sealed)
+	}
+	if index := BlindIndex(FieldSender, "MTN MoMo"); index != "" {
+		t.Fatalf("got index %q, want none", index)
+	}
+	if filter := Equals(FieldReference, "ABC123"); filter[FieldReference] != "ABC123" {
+		t.Fatalf("got filter %v, want a match on the plain field", filter)
+	}
+}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrInternalAddress is returned for endpoints that resolve to an address inside the
+// network mate runs in. Delivering there would let users probe internal services.
+var ErrInternalAddress = errors.New("webhook URL must resolve to a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which net.IP does not
+// count as private
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+var (
+	allowedMu sync.RWMutex
+	// allowed holds the "ip:port" addresses exempted from the internal address checks
+	allowed = map[string]bool{}
+)
+
+// Allow exempts one internal "ip:port" address from the checks, so webhooks can be
+// delivered to it. It is meant for tests and local development, a Receiver allows its
+// own address until it is closed.
+func Allow(address string) {
+	allowedMu.Lock()
+	defer allowedMu.Unlock()
+	allowed[address] = true
+}
+
+// Disallow reverts Allow
+func Disallow(address string) {
+	allowedMu.Lock()
+	defer allowedMu.Unlock()
+	delete(allowed, address)
+}
+
+// CheckURL resolves the host of a webhook URL and rejects it if any of its addresses
+// is internal
+func CheckURL(ctx context.Context, rawURL string) error {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	port := endpoint.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[endpoint.Scheme]
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, endpoint.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !permitted(addr.IP, port) {
+			return ErrInternalAddress
+		}
+	}
+	return nil
+}
+
+// permitted reports whether ip may be dialled on port
+func permitted(ip net.IP, port string) bool {
+	if !internal(ip) {
+		return true
+	}
+	allowedMu.RLock()
+	defer allowedMu.RUnlock()
+	return allowed[net.JoinHostPort(ip.String(), port)]
+}
+
+func internal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip)
+}
+
+// newClient returns the delivery client. Every connection, including redirects, is
+// checked against the address actually dialled, so a host that resolves differently
+// after CheckURL still cannot reach internal addresses. Proxies are not used, they
+// would hide the address.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !permitted(ip, port) {
+				return ErrInternalAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestInternal(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"8.8.8.8", false},
+		{"100.128.0.1", false},
+		{"2001:4860:4860::8888", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := internal(net.ParseIP(tt.ip)); got != tt.want {
+				t.Fatalf("internal(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	Allow("127.0.0.1:8081")
+	defer Disallow("127.0.0.1:8081")
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{"public address", "https://8.8.8.8/hook", nil},
+		{"loopback", "http://127.0.0.1/hook", ErrInternalAddress},
+		{"loopback IPv6", "http://[::1]:8080/hook", ErrInternalAddress},
+		{"private network", "https://10.0.0.5/hook", ErrInternalAddress},
+		{"cloud metadata", "http://169.254.169.254/latest/meta-data", ErrInternalAddress},
+		{"carrier-grade NAT", "http://100.64.10.1/hook", ErrInternalAddress},
+		{"allowed address", "http://127.0.0.1:8081/hook", nil},
+		{"allowed host on another port", "http://127.0.0.1:8082/hook", ErrInternalAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckURL(context.Background(), tt.url); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckURL(%s) = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"mate/config"
+	"mate/lifecycle"
+	"mate/logging"
+	"mate/models"
+	"mate/vault"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var logger = logging.Logger("webhooks")
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var (
+	// maxAttempts is the number of automatic delivery attempts before giving up
+	maxAttempts = 6
+	// baseBackoff is doubled after every failed attempt (5s, 10s, 20s, ...)
+	baseBackoff = 5 * time.Second
+
+	client = newClient()
+)
+
+type envelope struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatch sends event to every active webhook of userID subscribed to it.
+// Lookup and delivery happen in the background so callers are never blocked.
+func Dispatch(userID, event string, data interface{}) {
+	lifecycle.Go(func() { dispatch(userID, event, data) })
+}
+
+func dispatch(userID, event string, data interface{}) {
+	filter := bson.M{"user_id": userID, "active": true, "events": event}
+	cursor, err := config.Find(context.Background(), "webhooks", filter, nil)
+	if err != nil {
+		logger.Error("error fetching webhooks", "error", err)
+		return
+	}
+
+	var hooks []models.Webhook
+	if err := cursor.All(context.Background(), &hooks); err != nil {
+		logger.Error("error decoding webhooks", "error", err)
+		return
+	}
+
+	for _, hook := range hooks {
+		delivery, err := newDelivery(hook, event, data)
+		if err != nil {
+			logger.Error("error creating delivery", "error", err)
+			continue
+		}
+		hook, delivery := hook, delivery
+		lifecycle.Go(func() { deliver(hook, delivery) })
+	}
+}
+
+func newDelivery(hook models.Webhook, event string, data interface{}) (*models.WebhookDelivery, error) {
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		ID:        primitive.NewObjectID(),
+		WebhookID: hook.ID,
+		UserID:    hook.UserID,
+		Event:     event,
+		Status:    StatusPending,
+		Attempts:  []models.WebhookAttempt{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	payload, err := json.Marshal(envelope{
+		ID:        delivery.ID.Hex(),
+		Event:     event,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling payload: %w", err)
+	}
+	if delivery.Payload, err = sealPayload(hook.UserID, string(payload)); err != nil {
+		return nil, fmt.Errorf("error encrypting payload: %w", err)
+	}
+
+	if err := config.InsertOne(context.Background(), "webhook_deliveries", delivery); err != nil {
+		return nil, fmt.Errorf("error saving delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// deliver retries with exponential backoff until the endpoint accepts the payload.
+// Retries stop on shutdown and the delivery stays pending so it can be redelivered.
+func deliver(hook models.Webhook, delivery *models.WebhookDelivery) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 && !lifecycle.Sleep(baseBackoff<<(attempt-1)) {
+			return
+		}
+
+		if recordAttempt(hook, delivery, send(hook, delivery)) {
+			return
+		}
+	}
+
+	if _, err := config.UpdateOne(context.Background(), "webhook_deliveries", bson.M{"_id": delivery.ID}, bson.M{
+		"$set": bson.M{"status": StatusFailed, "updated_at": time.Now()},
+	}); err != nil {
+		logger.Error("error updating delivery", "error", err)
+	}
+}
+
+// Backlog counts deliveries still being retried. A delivery older than the whole retry
+// schedule was given up on, e.g. by a shutdown, and only comes back through Redeliver.
+func Backlog(ctx context.Context) (int, error) {
+	retryWindow := baseBackoff<<(maxAttempts-1) + time.Duration(maxAttempts)*client.Timeout
+	count, err := config.CountDocuments(ctx, "webhook_deliveries", bson.M{
+		"status":     StatusPending,
+		"created_at": bson.M{"$gt": time.Now().Add(-retryWindow)},
+	})
+	return int(count), err
+}
+
+// Redeliver makes one immediate attempt to resend a stored delivery and returns its updated state
+func Redeliver(userID string, deliveryID primitive.ObjectID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	result, err := config.FindOne(context.Background(), "webhook_deliveries", bson.M{"_id": deliveryID, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Decode(&delivery); err != nil {
+		return nil, err
+	}
+
+	var hook models.Webhook
+	result, err = config.FindOne(context.Background(), "webhooks", bson.M{"_id": delivery.WebhookID, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Decode(&hook); err != nil {
+		return nil, err
+	}
+
+	if err := OpenDelivery(&delivery); err != nil {
+		return nil, err
+	}
+	attempt := send(hook, &delivery)
+	if !recordAttempt(hook, &delivery, attempt) {
+		delivery.Status, delivery.UpdatedAt = StatusFailed, time.Now()
+		if _, err := config.UpdateOne(context.Background(), "webhook_deliveries", bson.M{"_id": delivery.ID}, bson.M{
+			"$set": bson.M{"status": StatusFailed, "updated_at": delivery.UpdatedAt},
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return &delivery, nil
+}
+
+func send(hook models.Webhook, delivery *models.WebhookDelivery) models.WebhookAttempt {
+	start := time.Now()
+	attempt := models.WebhookAttempt{At: start}
+
+	payload, err := vault.Decrypt(delivery.UserID, payloadField, delivery.Payload)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	body := []byte(payload)
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewBuffer(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mate-webhooks/1.0")
+	req.Header.Set("X-Mate-Event", delivery.Event)
+	req.Header.Set("X-Mate-Delivery", delivery.ID.Hex())
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, start, body))
+
+	resp, err := client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+// recordAttempt appends attempt to the delivery log and reports whether it succeeded
+func recordAttempt(hook models.Webhook, delivery *models.WebhookDelivery, attempt models.WebhookAttempt) bool {
+	succeeded := attempt.Error == ""
+
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.UpdatedAt = time.Now()
+	set := bson.M{"updated_at": delivery.UpdatedAt}
+	if succeeded {
+		delivery.Status = StatusSucceeded
+		set["status"] = StatusSucceeded
+	}
+
+	if _, err := config.UpdateOne(context.Background(), "webhook_deliveries", bson.M{"_id": delivery.ID}, bson.M{
+		"$push": bson.M{"attempts": attempt},
+		"$set":  set,
+	}); err != nil {
+		logger.Error("error recording attempt", "webhook_id", hook.ID.Hex(), "error", err)
+	}
+	return succeeded
+}
@@ -0,0 +1,33 @@
+package webhooks
+
+// Events a webhook endpoint can subscribe to
+const (
+	EventTransactionCreated = "transaction.created"
+	EventTransactionUpdated = "transaction.updated"
+	EventAlertRaised        = "alert.raised"
+	EventIngestionFailed    = "ingestion.failed"
+)
+
+var supportedEvents = []string{
+	EventTransactionCreated,
+	EventTransactionUpdated,
+	EventAlertRaised,
+	EventIngestionFailed,
+}
+
+// SupportedEvents returns every event name webhooks can subscribe to
+func SupportedEvents() []string {
+	events := make([]string, len(supportedEvents))
+	copy(events, supportedEvents)
+	return events
+}
+
+// IsSupportedEvent reports whether event is a known event name
+func IsSupportedEvent(event string) bool {
+	for _, e := range supportedEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
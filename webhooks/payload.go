@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+
+	"mate/config"
+	"mate/models"
+	"mate/vault"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// payloadField is the associated data payloads are encrypted under. Payloads carry
+// whole transactions, so they are stored encrypted like the transactions themselves.
+const payloadField = "webhook_payload"
+
+func sealPayload(userID, payload string) (string, error) {
+	return vault.Encrypt(userID, payloadField, payload)
+}
+
+// OpenDelivery decrypts the payload of a stored delivery
+func OpenDelivery(delivery *models.WebhookDelivery) error {
+	payload, err := vault.Decrypt(delivery.UserID, payloadField, delivery.Payload)
+	if err != nil {
+		return err
+	}
+	delivery.Payload = payload
+	return nil
+}
+
+// SealExistingPayloads encrypts delivery payloads stored before they were encrypted
+func SealExistingPayloads() (int, error) {
+	if !vault.Enabled() {
+		return 0, nil
+	}
+
+	cursor, err := config.Find(context.Background(), "webhook_deliveries", bson.M{"payload": bson.M{"$not": bson.M{"$regex": "^enc:"}}},
+		options.Find().SetBatchSize(500))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	sealed := 0
+	for cursor.Next(context.Background()) {
+		var delivery models.WebhookDelivery
+		if err := cursor.Decode(&delivery); err != nil {
+			return sealed, err
+		}
+		if vault.IsEncrypted(delivery.Payload) {
+			continue
+		}
+		payload, err := sealPayload(delivery.UserID, delivery.Payload)
+		if err != nil {
+			return sealed, err
+		}
+		if _, err := config.UpdateOne(context.Background(), "webhook_deliveries", bson.M{"_id": delivery.ID, "payload": delivery.Payload}, bson.M{
+			"$set": bson.M{"payload": payload},
+		}); err != nil {
+			return sealed, err
+		}
+		sealed++
+	}
+	return sealed, cursor.Err()
+}
@@ -0,0 +1,106 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// ReceivedDelivery is a request captured by a Receiver
+type ReceivedDelivery struct {
+	Event      string
+	DeliveryID string
+	Body       []byte
+	Verified   bool
+	ReceivedAt time.Time
+}
+
+// Receiver is a local HTTP endpoint that records webhook deliveries.
+// It is meant for tests and local development: point a webhook at Receiver.URL
+// and inspect what arrived with Deliveries or Wait. Its loopback address is allowed
+// as a webhook target until Close.
+type Receiver struct {
+	URL string
+
+	// StatusCode is returned to the sender, defaulting to 200
+	StatusCode int
+
+	secret     string
+	server     *httptest.Server
+	mu         sync.Mutex
+	deliveries []ReceivedDelivery
+	notify     chan struct{}
+}
+
+// NewReceiver starts a receiver that verifies signatures with secret
+func NewReceiver(secret string) *Receiver {
+	r := &Receiver{
+		StatusCode: http.StatusOK,
+		secret:     secret,
+		notify:     make(chan struct{}, 1),
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.handle))
+	r.URL = r.server.URL
+	Allow(r.server.Listener.Addr().String())
+	return r
+}
+
+func (r *Receiver) handle(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	delivery := ReceivedDelivery{
+		Event:      req.Header.Get("X-Mate-Event"),
+		DeliveryID: req.Header.Get("X-Mate-Delivery"),
+		Body:       body,
+		Verified:   Verify(r.secret, req.Header.Get(SignatureHeader), body, 5*time.Minute) == nil,
+		ReceivedAt: time.Now(),
+	}
+
+	r.mu.Lock()
+	r.deliveries = append(r.deliveries, delivery)
+	status := r.StatusCode
+	r.mu.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+
+	w.WriteHeader(status)
+}
+
+// Deliveries returns a copy of everything received so far
+func (r *Receiver) Deliveries() []ReceivedDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deliveries := make([]ReceivedDelivery, len(r.deliveries))
+	copy(deliveries, r.deliveries)
+	return deliveries
+}
+
+// Wait blocks until at least n deliveries were received or timeout elapses
+func (r *Receiver) Wait(n int, timeout time.Duration) []ReceivedDelivery {
+	deadline := time.After(timeout)
+	for {
+		if deliveries := r.Deliveries(); len(deliveries) >= n {
+			return deliveries
+		}
+		select {
+		case <-r.notify:
+		case <-deadline:
+			return r.Deliveries()
+		}
+	}
+}
+
+// Close shuts the receiver down
+func (r *Receiver) Close() {
+	Disallow(r.server.Listener.Addr().String())
+	r.server.Close()
+}
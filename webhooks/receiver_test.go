@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mate/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSendToReceiver(t *testing.T) {
+	payload := `{"id":"1","event":"transaction.created","data":{"amount":100}}`
+
+	tests := []struct {
+		name           string
+		receiverSecret string
+		receiverStatus int
+		wantVerified   bool
+		wantError      bool
+	}{
+		{"accepted and verified", "secret", http.StatusOK, true, false},
+		{"signed with another secret", "other", http.StatusOK, false, false},
+		{"rejected by the endpoint", "secret", http.StatusInternalServerError, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := NewReceiver(tt.receiverSecret)
+			defer receiver.Close()
+			receiver.StatusCode = tt.receiverStatus
+
+			hook := models.Webhook{ID: primitive.NewObjectID(), URL: receiver.URL + "/hook", Secret: "secret"}
+			delivery := &models.WebhookDelivery{ID: primitive.NewObjectID(), Event: EventTransactionCreated, Payload: payload}
+
+			attempt := send(hook, delivery)
+			if attempt.StatusCode != tt.receiverStatus {
+				t.Fatalf("got status %d, want %d (error %q)", attempt.StatusCode, tt.receiverStatus, attempt.Error)
+			}
+			if (attempt.Error != "") != tt.wantError {
+				t.Fatalf("got attempt error %q, want error: %v", attempt.Error, tt.wantError)
+			}
+
+			deliveries := receiver.Wait(1, time.Second)
+			if len(deliveries) != 1 {
+				t.Fatalf("got %d deliveries, want 1", len(deliveries))
+			}
+			got := deliveries[0]
+			if got.Verified != tt.wantVerified {
+				t.Fatalf("got verified %v, want %v", got.Verified, tt.wantVerified)
+			}
+			if got.Event != EventTransactionCreated || got.DeliveryID != delivery.ID.Hex() || string(got.Body) != payload {
+				t.Fatalf("got delivery %+v, want event %s, id %s and the payload", got, EventTransactionCreated, delivery.ID.Hex())
+			}
+		})
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("delivery reached an internal address")
+	}))
+	defer server.Close()
+
+	hook := models.Webhook{URL: server.URL, Secret: "secret"}
+	attempt := send(hook, &models.WebhookDelivery{ID: primitive.NewObjectID(), Payload: "{}"})
+	if !strings.Contains(attempt.Error, ErrInternalAddress.Error()) {
+		t.Fatalf("got attempt error %q, want %q", attempt.Error, ErrInternalAddress)
+	}
+}
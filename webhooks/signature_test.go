@@ -0,0 +1,53 @@
+package webhooks
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1","event":"transaction.created"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		wantErr   bool
+	}{
+		{"valid signature", "secret", Sign("secret", now, body), body, 5 * time.Minute, false},
+		{"wrong secret", "other", Sign("secret", now, body), body, 5 * time.Minute, true},
+		{"changed body", "secret", Sign("secret", now, body), []byte(`{"id":"2"}`), 5 * time.Minute, true},
+		{"expired timestamp", "secret", Sign("secret", now.Add(-time.Hour), body), body, 5 * time.Minute, true},
+		{"expired timestamp without tolerance", "secret", Sign("secret", now.Add(-time.Hour), body), body, 0, false},
+		{"missing signature", "secret", "t=1700000000", body, 0, true},
+		{"malformed header", "secret", "garbage", body, 0, true},
+		{"empty header", "secret", "", body, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.tolerance)
+			if tt.wantErr && !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("got error %v, want %v", err, ErrInvalidSignature)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("got error %v, want none", err)
+			}
+		})
+	}
+}
+
+func TestSignIsDeterministic(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte("{}")
+	if Sign("secret", at, body) != Sign("secret", at, body) {
+		t.Fatal("signing the same payload twice gave different signatures")
+	}
+	if Sign("secret", at, body) == Sign("secret", at.Add(time.Second), body) {
+		t.Fatal("the signature does not cover the timestamp")
+	}
+}
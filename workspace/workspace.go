@@ -0,0 +1,79 @@
+package workspace
+
+import (
+	"mate/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Member roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// IsValidRole reports whether role can be given to a member. Ownership is never granted
+// through invites or role changes, so only editor and viewer are assignable.
+func IsValidRole(role string) bool {
+	return role == RoleEditor || role == RoleViewer
+}
+
+// CanEdit reports whether role may change shared data
+func CanEdit(role string) bool {
+	return role == RoleOwner || role == RoleEditor
+}
+
+// CanManage reports whether role may invite, remove and re-role members
+func CanManage(role string) bool {
+	return role == RoleOwner
+}
+
+// Scope is the set of ledgers a request may read: either the caller's own
+// or every account shared into the active workspace. Only transactions are shared;
+// imports, statements, webhooks, the live stream and the audit log act on or report
+// about the caller's own account and stay personal.
+type Scope struct {
+	User      models.User
+	Workspace *models.Workspace
+	Role      string
+}
+
+// Personal returns the scope of a user's own ledger
+func Personal(user models.User) Scope {
+	return Scope{User: user, Role: RoleOwner}
+}
+
+// Member returns the membership of user in workspace, if any
+func Member(ws *models.Workspace, user models.User) (*models.WorkspaceMember, bool) {
+	for i := range ws.Members {
+		if ws.Members[i].UserID == user.ID {
+			return &ws.Members[i], true
+		}
+	}
+	return nil, false
+}
+
+// TransactionFilter restricts a transactions query to the scope. Sharing is opt-in:
+// a member contributes only the accounts they listed, and nothing until they do.
+func (s Scope) TransactionFilter() bson.M {
+	if s.Workspace == nil {
+		return bson.M{"userid": s.User.ID.String()}
+	}
+
+	ledgers := make([]bson.M, 0, len(s.Workspace.Members))
+	for _, member := range s.Workspace.Members {
+		if len(member.Accounts) == 0 {
+			continue
+		}
+		ledgers = append(ledgers, bson.M{
+			"userid": member.UserID.String(),
+			"origin": bson.M{"$in": member.Accounts},
+		})
+	}
+	if len(ledgers) == 0 {
+		// $or needs at least one clause; match nothing instead
+		return bson.M{"userid": bson.M{"$in": []string{}}}
+	}
+	return bson.M{"$or": ledgers}
+}